@@ -3,7 +3,6 @@ package simples3
 import (
 	"bytes"
 	"fmt"
-	"strings"
 	"testing"
 	"time"
 )
@@ -26,7 +25,7 @@ func TestBucketAcl(t *testing.T) {
 			CannedACL: "public-read",
 		})
 		if err != nil {
-			if strings.HasPrefix(err.Error(), "status code: 501 Not Implemented") {
+			if IsNotImplemented(err) {
 				t.Skipf("Skipping CannedACL test: backend returned 501 Not Implemented")
 			}
 			t.Fatalf("PutBucketAcl (canned) failed: %v", err)
@@ -122,7 +121,7 @@ func TestObjectAcl(t *testing.T) {
 			CannedACL: "public-read",
 		})
 		if err != nil {
-			if strings.HasPrefix(err.Error(), "status code: 501 Not Implemented") {
+			if IsNotImplemented(err) {
 				t.Skipf("Skipping CannedACL test: backend returned 501 Not Implemented")
 			}
 			t.Fatalf("PutObjectAcl (canned) failed: %v", err)
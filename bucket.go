@@ -5,6 +5,7 @@ package simples3
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/sha256"
 	"encoding/base64"
@@ -46,6 +47,10 @@ type CreateBucketInput struct {
 	// If empty, uses the region from S3 struct.
 	// Note: For us-east-1, no LocationConstraint is needed.
 	Region string
+
+	// Optional: Enable Object Lock, which also enables versioning.
+	// It can't be enabled on existing buckets.
+	ObjectLockEnabledForBucket bool
 }
 
 // CreateBucketOutput is returned by CreateBucket.
@@ -64,11 +69,11 @@ type DeleteBucketInput struct {
 // ListBuckets lists all S3 buckets for the AWS account.
 // It makes a GET request to the S3 service endpoint (not a specific bucket).
 func (s3 *S3) ListBuckets(input ListBucketsInput) (ListBucketsOutput, error) {
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return ListBucketsOutput{}, err
-	}
+	return s3.ListBucketsWithContext(context.Background(), input)
+}
 
+// ListBucketsWithContext is like ListBuckets but uses ctx for the request.
+func (s3 *S3) ListBucketsWithContext(ctx context.Context, input ListBucketsInput) (ListBucketsOutput, error) {
 	// Build endpoint URL - ListBuckets uses the service endpoint (no bucket name)
 	var endpoint string
 	if len(s3.Endpoint) > 0 {
@@ -79,19 +84,13 @@ func (s3 *S3) ListBuckets(input ListBucketsInput) (ListBucketsOutput, error) {
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return ListBucketsOutput{}, err
 	}
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return ListBucketsOutput{}, err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return ListBucketsOutput{}, err
 	}
@@ -109,7 +108,7 @@ func (s3 *S3) ListBuckets(input ListBucketsInput) (ListBucketsOutput, error) {
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		return ListBucketsOutput{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return ListBucketsOutput{}, newResponseError("ListBuckets", "", "", res, body)
 	}
 
 	// Parse XML response
@@ -124,16 +123,16 @@ func (s3 *S3) ListBuckets(input ListBucketsInput) (ListBucketsOutput, error) {
 // CreateBucket creates a new S3 bucket.
 // For regions other than us-east-1, it sends a LocationConstraint in the request body.
 func (s3 *S3) CreateBucket(input CreateBucketInput) (CreateBucketOutput, error) {
+	return s3.CreateBucketWithContext(context.Background(), input)
+}
+
+// CreateBucketWithContext is like CreateBucket but uses ctx for the request.
+func (s3 *S3) CreateBucketWithContext(ctx context.Context, input CreateBucketInput) (CreateBucketOutput, error) {
 	// Validate input
 	if input.Bucket == "" {
 		return CreateBucketOutput{}, fmt.Errorf("bucket name is required")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return CreateBucketOutput{}, err
-	}
-
 	// Determine region to use
 	region := input.Region
 	if region == "" {
@@ -155,19 +154,17 @@ func (s3 *S3) CreateBucket(input CreateBucketInput) (CreateBucketOutput, error)
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest(http.MethodPut, url, body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, body)
 	if err != nil {
 		return CreateBucketOutput{}, err
 	}
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return CreateBucketOutput{}, err
+	if input.ObjectLockEnabledForBucket {
+		req.Header.Set("x-amz-bucket-object-lock-enabled", "true")
 	}
 
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return CreateBucketOutput{}, err
 	}
@@ -186,7 +183,7 @@ func (s3 *S3) CreateBucket(input CreateBucketInput) (CreateBucketOutput, error)
 	// Handle non-success status codes
 	// AWS returns 200 OK or 201 Created on success
 	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
-		return CreateBucketOutput{}, fmt.Errorf("status code: %s: %s", res.Status, string(bodyBytes))
+		return CreateBucketOutput{}, newResponseError("CreateBucket", input.Bucket, "", res, bodyBytes)
 	}
 
 	// Extract location from response header
@@ -199,33 +196,27 @@ func (s3 *S3) CreateBucket(input CreateBucketInput) (CreateBucketOutput, error)
 // The bucket must be empty (no objects) before it can be deleted.
 // Returns an error if the bucket is not empty or does not exist.
 func (s3 *S3) DeleteBucket(input DeleteBucketInput) error {
+	return s3.DeleteBucketWithContext(context.Background(), input)
+}
+
+// DeleteBucketWithContext is like DeleteBucket but uses ctx for the request.
+func (s3 *S3) DeleteBucketWithContext(ctx context.Context, input DeleteBucketInput) error {
 	// Validate input
 	if input.Bucket == "" {
 		return fmt.Errorf("bucket name is required")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return err
-	}
-
 	// Build endpoint URL
 	url := s3.getURL(input.Bucket)
 
 	// Create HTTP request
-	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return err
 	}
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return err
 	}
@@ -244,7 +235,7 @@ func (s3 *S3) DeleteBucket(input DeleteBucketInput) error {
 	// Handle non-success status codes
 	// AWS returns 204 No Content on successful deletion
 	if res.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("status code: %s: %s", res.Status, string(bodyBytes))
+		return newResponseError("DeleteBucket", input.Bucket, "", res, bodyBytes)
 	}
 
 	return nil
@@ -285,6 +276,11 @@ type versioningConfigurationXML struct {
 
 // PutBucketVersioning sets the versioning configuration for a bucket.
 func (s3 *S3) PutBucketVersioning(input PutBucketVersioningInput) error {
+	return s3.PutBucketVersioningWithContext(context.Background(), input)
+}
+
+// PutBucketVersioningWithContext is like PutBucketVersioning but uses ctx for the request.
+func (s3 *S3) PutBucketVersioningWithContext(ctx context.Context, input PutBucketVersioningInput) error {
 	// Validate input
 	if input.Bucket == "" {
 		return fmt.Errorf("bucket name is required")
@@ -293,11 +289,6 @@ func (s3 *S3) PutBucketVersioning(input PutBucketVersioningInput) error {
 		return fmt.Errorf("status must be 'Enabled' or 'Suspended'")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return err
-	}
-
 	// Build XML request body
 	config := versioningConfigurationXML{
 		XMLNS:     "http://s3.amazonaws.com/doc/2006-03-01/",
@@ -323,7 +314,7 @@ func (s3 *S3) PutBucketVersioning(input PutBucketVersioningInput) error {
 	parsedURL.RawQuery = "versioning"
 
 	// Create PUT request
-	req, err := http.NewRequest(http.MethodPut, parsedURL.String(), bytes.NewReader(xmlBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, parsedURL.String(), bytes.NewReader(xmlBody))
 	if err != nil {
 		return err
 	}
@@ -339,14 +330,8 @@ func (s3 *S3) PutBucketVersioning(input PutBucketVersioningInput) error {
 	req.Header.Set("x-amz-content-sha256", fmt.Sprintf("%x", h.Sum(nil)))
 	req.Header.Set("Host", req.URL.Host)
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return err
 	}
@@ -364,7 +349,7 @@ func (s3 *S3) PutBucketVersioning(input PutBucketVersioningInput) error {
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return newResponseError("PutBucketVersioning", input.Bucket, "", res, body)
 	}
 
 	return nil
@@ -372,16 +357,16 @@ func (s3 *S3) PutBucketVersioning(input PutBucketVersioningInput) error {
 
 // GetBucketVersioning gets the versioning configuration for a bucket.
 func (s3 *S3) GetBucketVersioning(bucket string) (GetBucketVersioningOutput, error) {
+	return s3.GetBucketVersioningWithContext(context.Background(), bucket)
+}
+
+// GetBucketVersioningWithContext is like GetBucketVersioning but uses ctx for the request.
+func (s3 *S3) GetBucketVersioningWithContext(ctx context.Context, bucket string) (GetBucketVersioningOutput, error) {
 	// Validate input
 	if bucket == "" {
 		return GetBucketVersioningOutput{}, fmt.Errorf("bucket name is required")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return GetBucketVersioningOutput{}, err
-	}
-
 	// Build URL with ?versioning query parameter
 	baseURL := s3.getURL(bucket)
 	parsedURL, err := url.Parse(baseURL)
@@ -391,19 +376,13 @@ func (s3 *S3) GetBucketVersioning(bucket string) (GetBucketVersioningOutput, err
 	parsedURL.RawQuery = "versioning"
 
 	// Create GET request
-	req, err := http.NewRequest(http.MethodGet, parsedURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
 	if err != nil {
 		return GetBucketVersioningOutput{}, err
 	}
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return GetBucketVersioningOutput{}, err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return GetBucketVersioningOutput{}, err
 	}
@@ -421,7 +400,7 @@ func (s3 *S3) GetBucketVersioning(bucket string) (GetBucketVersioningOutput, err
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		return GetBucketVersioningOutput{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return GetBucketVersioningOutput{}, newResponseError("GetBucketVersioning", bucket, "", res, body)
 	}
 
 	// Parse XML response
@@ -484,6 +463,11 @@ type PutBucketAclInput struct {
 // PutBucketAcl sets the Access Control List (ACL) for a bucket.
 // You can either use a CannedACL OR provide a full AccessControlPolicy.
 func (s3 *S3) PutBucketAcl(input PutBucketAclInput) error {
+	return s3.PutBucketAclWithContext(context.Background(), input)
+}
+
+// PutBucketAclWithContext is like PutBucketAcl but uses ctx for the request.
+func (s3 *S3) PutBucketAclWithContext(ctx context.Context, input PutBucketAclInput) error {
 	// Validate input
 	if input.Bucket == "" {
 		return fmt.Errorf("bucket name is required")
@@ -492,11 +476,6 @@ func (s3 *S3) PutBucketAcl(input PutBucketAclInput) error {
 		return fmt.Errorf("either CannedACL or AccessControlPolicy must be provided")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return err
-	}
-
 	// Build URL with ?acl query parameter
 	baseURL := s3.getURL(input.Bucket)
 	parsedURL, err := url.Parse(baseURL)
@@ -535,7 +514,7 @@ func (s3 *S3) PutBucketAcl(input PutBucketAclInput) error {
 		h.Write(xmlBody)
 		sha256Hash = fmt.Sprintf("%x", h.Sum(nil))
 
-		req, err = http.NewRequest(http.MethodPut, parsedURL.String(), bodyReader)
+		req, err = http.NewRequestWithContext(ctx, http.MethodPut, parsedURL.String(), bodyReader)
 		if err != nil {
 			return err
 		}
@@ -546,7 +525,7 @@ func (s3 *S3) PutBucketAcl(input PutBucketAclInput) error {
 
 	} else {
 		// Use Canned ACL via header
-		req, err = http.NewRequest(http.MethodPut, parsedURL.String(), nil)
+		req, err = http.NewRequestWithContext(ctx, http.MethodPut, parsedURL.String(), nil)
 		if err != nil {
 			return err
 		}
@@ -559,14 +538,8 @@ func (s3 *S3) PutBucketAcl(input PutBucketAclInput) error {
 
 	req.Header.Set("Host", req.URL.Host)
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return err
 	}
@@ -584,7 +557,7 @@ func (s3 *S3) PutBucketAcl(input PutBucketAclInput) error {
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return newResponseError("PutBucketAcl", input.Bucket, "", res, body)
 	}
 
 	return nil
@@ -592,16 +565,16 @@ func (s3 *S3) PutBucketAcl(input PutBucketAclInput) error {
 
 // GetBucketAcl gets the Access Control List (ACL) for a bucket.
 func (s3 *S3) GetBucketAcl(bucket string) (AccessControlPolicy, error) {
+	return s3.GetBucketAclWithContext(context.Background(), bucket)
+}
+
+// GetBucketAclWithContext is like GetBucketAcl but uses ctx for the request.
+func (s3 *S3) GetBucketAclWithContext(ctx context.Context, bucket string) (AccessControlPolicy, error) {
 	// Validate input
 	if bucket == "" {
 		return AccessControlPolicy{}, fmt.Errorf("bucket name is required")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return AccessControlPolicy{}, err
-	}
-
 	// Build URL with ?acl query parameter
 	baseURL := s3.getURL(bucket)
 	parsedURL, err := url.Parse(baseURL)
@@ -611,19 +584,13 @@ func (s3 *S3) GetBucketAcl(bucket string) (AccessControlPolicy, error) {
 	parsedURL.RawQuery = "acl"
 
 	// Create GET request
-	req, err := http.NewRequest(http.MethodGet, parsedURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
 	if err != nil {
 		return AccessControlPolicy{}, err
 	}
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return AccessControlPolicy{}, err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return AccessControlPolicy{}, err
 	}
@@ -641,7 +608,7 @@ func (s3 *S3) GetBucketAcl(bucket string) (AccessControlPolicy, error) {
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		return AccessControlPolicy{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return AccessControlPolicy{}, newResponseError("GetBucketAcl", bucket, "", res, body)
 	}
 
 	// Parse XML response
@@ -728,6 +695,11 @@ type PutBucketLifecycleInput struct {
 
 // PutBucketLifecycle sets the lifecycle configuration for a bucket.
 func (s3 *S3) PutBucketLifecycle(input PutBucketLifecycleInput) error {
+	return s3.PutBucketLifecycleWithContext(context.Background(), input)
+}
+
+// PutBucketLifecycleWithContext is like PutBucketLifecycle but uses ctx for the request.
+func (s3 *S3) PutBucketLifecycleWithContext(ctx context.Context, input PutBucketLifecycleInput) error {
 	// Validate input
 	if input.Bucket == "" {
 		return fmt.Errorf("bucket name is required")
@@ -736,11 +708,6 @@ func (s3 *S3) PutBucketLifecycle(input PutBucketLifecycleInput) error {
 		return fmt.Errorf("lifecycle configuration with at least one rule is required")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return err
-	}
-
 	// Build URL with ?lifecycle query parameter
 	baseURL := s3.getURL(input.Bucket)
 	parsedURL, err := url.Parse(baseURL)
@@ -767,7 +734,7 @@ func (s3 *S3) PutBucketLifecycle(input PutBucketLifecycleInput) error {
 	sha256Hash := fmt.Sprintf("%x", h.Sum(nil))
 
 	// Create PUT request
-	req, err := http.NewRequest(http.MethodPut, parsedURL.String(), bytes.NewReader(xmlBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, parsedURL.String(), bytes.NewReader(xmlBody))
 	if err != nil {
 		return err
 	}
@@ -778,14 +745,8 @@ func (s3 *S3) PutBucketLifecycle(input PutBucketLifecycleInput) error {
 	req.Header.Set("x-amz-content-sha256", sha256Hash)
 	req.Header.Set("Host", req.URL.Host)
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return err
 	}
@@ -803,7 +764,7 @@ func (s3 *S3) PutBucketLifecycle(input PutBucketLifecycleInput) error {
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return newResponseError("PutBucketLifecycle", input.Bucket, "", res, body)
 	}
 
 	return nil
@@ -811,16 +772,16 @@ func (s3 *S3) PutBucketLifecycle(input PutBucketLifecycleInput) error {
 
 // GetBucketLifecycle gets the lifecycle configuration for a bucket.
 func (s3 *S3) GetBucketLifecycle(bucket string) (LifecycleConfiguration, error) {
+	return s3.GetBucketLifecycleWithContext(context.Background(), bucket)
+}
+
+// GetBucketLifecycleWithContext is like GetBucketLifecycle but uses ctx for the request.
+func (s3 *S3) GetBucketLifecycleWithContext(ctx context.Context, bucket string) (LifecycleConfiguration, error) {
 	// Validate input
 	if bucket == "" {
 		return LifecycleConfiguration{}, fmt.Errorf("bucket name is required")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return LifecycleConfiguration{}, err
-	}
-
 	// Build URL with ?lifecycle query parameter
 	baseURL := s3.getURL(bucket)
 	parsedURL, err := url.Parse(baseURL)
@@ -830,19 +791,13 @@ func (s3 *S3) GetBucketLifecycle(bucket string) (LifecycleConfiguration, error)
 	parsedURL.RawQuery = "lifecycle"
 
 	// Create GET request
-	req, err := http.NewRequest(http.MethodGet, parsedURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
 	if err != nil {
 		return LifecycleConfiguration{}, err
 	}
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return LifecycleConfiguration{}, err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return LifecycleConfiguration{}, err
 	}
@@ -860,14 +815,9 @@ func (s3 *S3) GetBucketLifecycle(bucket string) (LifecycleConfiguration, error)
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		// S3 returns 404 if no lifecycle configuration exists
-		if res.StatusCode == http.StatusNotFound {
-			// Check if it's actually "NoSuchLifecycleConfiguration"
-			if strings.Contains(string(body), "NoSuchLifecycleConfiguration") {
-				return LifecycleConfiguration{}, fmt.Errorf("no lifecycle configuration found")
-			}
-		}
-		return LifecycleConfiguration{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		// S3 returns 404 NoSuchLifecycleConfiguration if no lifecycle
+		// configuration exists, which IsNotFound reports as true.
+		return LifecycleConfiguration{}, newResponseError("GetBucketLifecycle", bucket, "", res, body)
 	}
 
 	// Parse XML response
@@ -881,6 +831,11 @@ func (s3 *S3) GetBucketLifecycle(bucket string) (LifecycleConfiguration, error)
 
 // DeleteBucketLifecycle deletes the lifecycle configuration for a bucket.
 func (s3 *S3) DeleteBucketLifecycle(input DeleteBucketInput) error {
+	return s3.DeleteBucketLifecycleWithContext(context.Background(), input)
+}
+
+// DeleteBucketLifecycleWithContext is like DeleteBucketLifecycle but uses ctx for the request.
+func (s3 *S3) DeleteBucketLifecycleWithContext(ctx context.Context, input DeleteBucketInput) error {
 	// Reuse DeleteBucketInput since it just needs the bucket name
 
 	// Validate input
@@ -888,11 +843,6 @@ func (s3 *S3) DeleteBucketLifecycle(input DeleteBucketInput) error {
 		return fmt.Errorf("bucket name is required")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return err
-	}
-
 	// Build URL with ?lifecycle query parameter
 	baseURL := s3.getURL(input.Bucket)
 	parsedURL, err := url.Parse(baseURL)
@@ -902,19 +852,13 @@ func (s3 *S3) DeleteBucketLifecycle(input DeleteBucketInput) error {
 	parsedURL.RawQuery = "lifecycle"
 
 	// Create DELETE request
-	req, err := http.NewRequest(http.MethodDelete, parsedURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, parsedURL.String(), nil)
 	if err != nil {
 		return err
 	}
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return err
 	}
@@ -933,7 +877,7 @@ func (s3 *S3) DeleteBucketLifecycle(input DeleteBucketInput) error {
 	// Handle non-success status codes
 	// AWS returns 204 No Content on successful deletion
 	if res.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return newResponseError("DeleteBucketLifecycle", input.Bucket, "", res, body)
 	}
 
 	return nil
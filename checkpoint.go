@@ -0,0 +1,153 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MultipartCheckpoint is the state of a multipart upload, saved by
+// FileUploadMultipart so that the upload can be resumed.
+type MultipartCheckpoint struct {
+	Bucket    string `json:"bucket"`
+	ObjectKey string `json:"key"`
+	UploadID  string `json:"upload_id"`
+
+	// PartSize is the size of the parts. Uploads of unknown size
+	// (Size is -1) grow it as the upload progresses.
+	PartSize int64 `json:"part_size"`
+	Size     int64 `json:"size"`
+
+	ChecksumAlgorithm string `json:"checksum_algorithm,omitempty"`
+	ChecksumType      string `json:"checksum_type,omitempty"`
+
+	// Parts are the parts uploaded so far, in no particular order.
+	Parts []Part `json:"parts"`
+}
+
+// CheckpointStore persists the checkpoint of a single multipart
+// upload. Save is called after every uploaded part, and Delete once
+// the upload is complete.
+type CheckpointStore interface {
+	// Load returns the saved checkpoint, or nil if there is none.
+	Load() (*MultipartCheckpoint, error)
+	Save(cp *MultipartCheckpoint) error
+	Delete() error
+}
+
+// FileCheckpointStore stores a checkpoint as a JSON file at Path.
+type FileCheckpointStore struct {
+	Path string
+}
+
+// Load reads the checkpoint file, if it exists.
+func (f FileCheckpointStore) Load() (*MultipartCheckpoint, error) {
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cp MultipartCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("error reading checkpoint %s: %w", f.Path, err)
+	}
+	return &cp, nil
+}
+
+// Save writes the checkpoint file. The file is replaced atomically,
+// so that it is never left half-written.
+func (f FileCheckpointStore) Save(cp *MultipartCheckpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// Delete removes the checkpoint file.
+func (f FileCheckpointStore) Delete() error {
+	err := os.Remove(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// resumeCheckpoint loads the checkpoint saved in store and checks it
+// against the parts S3 holds for the upload. Only the parts S3 has,
+// with the same ETag, are kept. It returns nil if there is no upload
+// to resume: no checkpoint, one for another upload than want (another
+// object, size or checksum), or an upload that no longer exists.
+func (s3 *S3) resumeCheckpoint(ctx context.Context, store CheckpointStore, want MultipartCheckpoint) (*MultipartCheckpoint, error) {
+	cp, err := store.Load()
+	if err != nil || cp == nil {
+		return nil, err
+	}
+
+	if cp.Bucket != want.Bucket || cp.ObjectKey != want.ObjectKey || cp.Size != want.Size ||
+		cp.ChecksumAlgorithm != want.ChecksumAlgorithm || cp.ChecksumType != want.ChecksumType || cp.UploadID == "" {
+		// The upload is of something else, and can't be resumed.
+		if cp.UploadID != "" {
+			s3.abortUpload(ctx, cp.Bucket, cp.ObjectKey, cp.UploadID)
+		}
+		return nil, nil
+	}
+
+	uploaded := map[int]Part{}
+	marker := 0
+	for {
+		out, err := s3.ListPartsWithContext(ctx, ListPartsInput{
+			Bucket:           cp.Bucket,
+			ObjectKey:        cp.ObjectKey,
+			UploadID:         cp.UploadID,
+			PartNumberMarker: marker,
+		})
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, part := range out.Parts {
+			uploaded[part.PartNumber] = part
+		}
+		if !out.IsTruncated {
+			break
+		}
+		marker = out.NextPartNumberMarker
+	}
+
+	parts := cp.Parts[:0]
+	for _, part := range cp.Parts {
+		if p, ok := uploaded[part.PartNumber]; ok && strings.Trim(p.ETag, `"`) == strings.Trim(part.ETag, `"`) {
+			parts = append(parts, p)
+		}
+	}
+	cp.Parts = parts
+	return cp, nil
+}
@@ -0,0 +1,136 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Checksum algorithms that can be set as ChecksumAlgorithm. The checksum
+// of the data is computed while it is uploaded, and checked by S3.
+const (
+	ChecksumCRC32     = "CRC32"
+	ChecksumCRC32C    = "CRC32C"
+	ChecksumCRC64NVME = "CRC64NVME"
+	ChecksumSHA1      = "SHA1"
+	ChecksumSHA256    = "SHA256"
+)
+
+// Checksum types of a multipart upload. The checksum of a COMPOSITE
+// upload is the checksum of the checksums of its parts, followed by
+// the number of parts (e.g. "xxxx-3"). A FULL_OBJECT checksum is the
+// checksum of the whole object, and is supported by the CRC algorithms.
+const (
+	ChecksumTypeComposite  = "COMPOSITE"
+	ChecksumTypeFullObject = "FULL_OBJECT"
+)
+
+// ErrChecksumMismatch is returned (wrapped) when reading a downloaded
+// object whose checksum doesn't match the one returned by S3.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// crc64NVMETable is the table of the CRC-64/NVME polynomial
+// 0xad93d23594c93659, in the reversed form hash/crc64 uses.
+var crc64NVMETable = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+
+// checksumHeader returns the header holding checksums computed using
+// algorithm, e.g. "x-amz-checksum-crc32".
+func checksumHeader(algorithm string) string {
+	return "x-amz-checksum-" + strings.ToLower(algorithm)
+}
+
+// newChecksum returns a hash computing checksums using algorithm.
+func newChecksum(algorithm string) (hash.Hash, error) {
+	newHash, ok := trailerChecksums[checksumHeader(algorithm)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+	return newHash(), nil
+}
+
+// encodeChecksum returns the checksum computed by h, encoded the way
+// S3 expects it.
+func encodeChecksum(h hash.Hash) string {
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// checksums holds the checksums of an object or part found in S3 XML
+// documents. Only the one of the algorithm used is set.
+type checksums struct {
+	CRC32     string `xml:"ChecksumCRC32,omitempty"`
+	CRC32C    string `xml:"ChecksumCRC32C,omitempty"`
+	CRC64NVME string `xml:"ChecksumCRC64NVME,omitempty"`
+	SHA1      string `xml:"ChecksumSHA1,omitempty"`
+	SHA256    string `xml:"ChecksumSHA256,omitempty"`
+}
+
+// newChecksums returns checksums holding value as the checksum computed
+// using algorithm.
+func newChecksums(algorithm, value string) checksums {
+	var c checksums
+	switch algorithm {
+	case ChecksumCRC32:
+		c.CRC32 = value
+	case ChecksumCRC32C:
+		c.CRC32C = value
+	case ChecksumCRC64NVME:
+		c.CRC64NVME = value
+	case ChecksumSHA1:
+		c.SHA1 = value
+	case ChecksumSHA256:
+		c.SHA256 = value
+	}
+	return c
+}
+
+// value returns the checksum held by c, if any.
+func (c checksums) value() string {
+	for _, v := range []string{c.CRC32, c.CRC32C, c.CRC64NVME, c.SHA1, c.SHA256} {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// responseChecksum returns the checksum found in the headers of a
+// response, along with its algorithm.
+func responseChecksum(h http.Header) (algorithm, value string) {
+	for _, algorithm := range []string{ChecksumCRC32, ChecksumCRC32C, ChecksumCRC64NVME, ChecksumSHA1, ChecksumSHA256} {
+		if v := h.Get(checksumHeader(algorithm)); v != "" {
+			return algorithm, v
+		}
+	}
+	return "", ""
+}
+
+// checksumReader computes the checksum of the body it reads, and fails
+// at the end of the body if it doesn't match the expected one.
+type checksumReader struct {
+	body     io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		if sum := encodeChecksum(r.hash); sum != r.expected {
+			return n, fmt.Errorf("%w: got %s, want %s", ErrChecksumMismatch, sum, r.expected)
+		}
+	}
+	return n, err
+}
+
+func (r *checksumReader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,211 @@
+package simples3
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/iotest"
+)
+
+func TestChecksumAlgorithms(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		want      uint64
+	}{
+		{ChecksumCRC32, 0xcbf43926},
+		{ChecksumCRC32C, 0xe3069283},
+		{ChecksumCRC64NVME, 0xae8b14860a799888},
+	}
+	for _, tt := range tests {
+		h, err := newChecksum(tt.algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(h, "123456789")
+
+		var got uint64
+		for _, b := range h.Sum(nil) {
+			got = got<<8 | uint64(b)
+		}
+		if got != tt.want {
+			t.Errorf("%s(123456789) = %#x, want %#x", tt.algorithm, got, tt.want)
+		}
+	}
+
+	if _, err := newChecksum("MD5"); err == nil {
+		t.Errorf("expected unsupported algorithm to be rejected")
+	}
+}
+
+func TestFilePut_Checksum(t *testing.T) {
+	var header, trailer string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := verifySigV4(r, "SuperSecretKey")
+		if err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		header, trailer = r.Header.Get("x-amz-checksum-crc64nvme"), r.Header.Get("x-amz-trailer")
+
+		h, _ := newChecksum(ChecksumCRC64NVME)
+		h.Write(body)
+		if header != "" && header != encodeChecksum(h) {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `<Error><Code>BadDigest</Code></Error>`)
+			return
+		}
+		w.Header().Set("x-amz-checksum-crc64nvme", encodeChecksum(h))
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	h, _ := newChecksum(ChecksumCRC64NVME)
+	h.Write(content)
+	want := encodeChecksum(h)
+
+	tests := []struct {
+		name    string
+		input   UploadInput
+		trailer bool
+	}{
+		{"Body", UploadInput{Body: bytes.NewReader(content)}, false},
+		{"Reader", UploadInput{Reader: iotest.HalfReader(bytes.NewReader(content)), ContentLength: int64(len(content))}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.Bucket, tt.input.ObjectKey = "bucket", "key"
+			tt.input.ChecksumAlgorithm = ChecksumCRC64NVME
+			out, err := s3.FilePut(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.Checksum != want {
+				t.Errorf("Checksum = %q, want %q", out.Checksum, want)
+			}
+			if tt.trailer && trailer != "x-amz-checksum-crc64nvme" {
+				t.Errorf("expected the checksum in the trailer, got %q", trailer)
+			}
+			if !tt.trailer && header != want {
+				t.Errorf("expected the checksum in the headers, got %q", header)
+			}
+		})
+	}
+
+	_, err := s3.FilePut(UploadInput{
+		Bucket:            "bucket",
+		ObjectKey:         "key",
+		Body:              bytes.NewReader(content),
+		ChecksumAlgorithm: "MD5",
+	})
+	if err == nil {
+		t.Errorf("expected unsupported algorithm to be rejected")
+	}
+}
+
+func TestFileUploadMultipart_Checksum(t *testing.T) {
+	content := make([]byte, 2*MinPartSize+1234)
+	rand.Read(content)
+
+	for _, checksumType := range []string{ChecksumTypeComposite, ChecksumTypeFullObject} {
+		t.Run(checksumType, func(t *testing.T) {
+			stub := newMultipartStub(t)
+			s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+			s3.SetEndpoint(stub.URL)
+
+			_, err := s3.FileUploadMultipart(MultipartUploadInput{
+				Bucket:            "bucket",
+				ObjectKey:         "key",
+				Body:              bytes.NewReader(content),
+				Concurrency:       2,
+				ChecksumAlgorithm: ChecksumCRC32C,
+				ChecksumType:      checksumType,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(stub.completeParts) != 3 {
+				t.Fatalf("completed %d parts, want 3", len(stub.completeParts))
+			}
+			for _, p := range stub.completeParts {
+				if p.CRC32C == "" || p.CRC32C != stub.checksums[p.PartNumber] {
+					t.Errorf("part %d: checksum %q, want %q", p.PartNumber, p.CRC32C, stub.checksums[p.PartNumber])
+				}
+			}
+
+			h, _ := newChecksum(ChecksumCRC32C)
+			h.Write(content)
+			full := stub.complete.Header.Get("x-amz-checksum-crc32c")
+			if got := stub.complete.Header.Get("x-amz-checksum-type"); got != checksumType {
+				t.Errorf("checksum type %q, want %q", got, checksumType)
+			}
+			if checksumType == ChecksumTypeFullObject && full != encodeChecksum(h) {
+				t.Errorf("full object checksum %q, want %q", full, encodeChecksum(h))
+			}
+			if checksumType == ChecksumTypeComposite && full != "" {
+				t.Errorf("unexpected full object checksum %q", full)
+			}
+		})
+	}
+}
+
+func TestGetObject_ValidateChecksum(t *testing.T) {
+	content := []byte("123456789")
+	var checksum, checksumType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-amz-checksum-mode") == "ENABLED" {
+			w.Header().Set("x-amz-checksum-crc32", checksum)
+			w.Header().Set("x-amz-checksum-type", checksumType)
+		}
+		w.Write(content)
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, 0xcbf43926)
+	valid := base64.StdEncoding.EncodeToString(sum)
+	binary.BigEndian.PutUint32(sum, 0xdeadbeef)
+	corrupted := base64.StdEncoding.EncodeToString(sum)
+
+	tests := []struct {
+		name         string
+		checksum     string
+		checksumType string
+		mismatch     bool
+	}{
+		{"Valid", valid, ChecksumTypeFullObject, false},
+		{"Corrupted", corrupted, ChecksumTypeFullObject, true},
+		{"Composite", corrupted + "-3", ChecksumTypeComposite, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checksum, checksumType = tt.checksum, tt.checksumType
+			out, err := s3.GetObject(DownloadInput{Bucket: "bucket", ObjectKey: "key", ValidateChecksum: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer out.Body.Close()
+
+			if out.ChecksumAlgorithm != ChecksumCRC32 || out.Checksum != tt.checksum {
+				t.Errorf("unexpected checksum %s %q", out.ChecksumAlgorithm, out.Checksum)
+			}
+			_, err = io.ReadAll(out.Body)
+			if errors.Is(err, ErrChecksumMismatch) != tt.mismatch {
+				t.Errorf("unexpected error reading the body: %v", err)
+			}
+		})
+	}
+}
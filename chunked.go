@@ -0,0 +1,216 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Payloads sent using aws-chunked encoding, each chunk being signed.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+const (
+	streamingPayload        = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingPayloadTrailer = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+
+	// streamingChunkSize is the size of the chunks the payload is split
+	// in. All chunks but the last one must be at least 8 KiB.
+	streamingChunkSize = 64 * 1024
+
+	chunkSignaturePrefix   = ";chunk-signature="
+	trailerSignaturePrefix = "x-amz-trailer-signature:"
+	chunkSignatureLen      = sha256.Size * 2
+)
+
+// trailerChecksums are the checksum headers that can be sent in the
+// trailer of a streaming payload, with the hash computing them.
+var trailerChecksums = map[string]func() hash.Hash{
+	"x-amz-checksum-crc32":     func() hash.Hash { return crc32.NewIEEE() },
+	"x-amz-checksum-crc32c":    func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+	"x-amz-checksum-crc64nvme": func() hash.Hash { return crc64.New(crc64NVMETable) },
+	"x-amz-checksum-sha1":      sha1.New,
+	"x-amz-checksum-sha256":    sha256.New,
+}
+
+// setStreamingPayload prepares req, whose body holds size bytes, to be
+// sent using aws-chunked encoding with every chunk signed, so that
+// bodies of any size can be signed without being read twice.
+// If trailer is the name of a checksum header (e.g. "x-amz-checksum-crc32"),
+// the checksum of the payload is sent in a signed trailer.
+// The body itself is wrapped when the request is signed.
+func setStreamingPayload(req *http.Request, size int64, trailer string) error {
+	payload := streamingPayload
+	if trailer != "" {
+		newHash, ok := trailerChecksums[trailer]
+		if !ok {
+			return fmt.Errorf("unsupported trailer checksum %q", trailer)
+		}
+		payload = streamingPayloadTrailer
+		req.Header.Set("x-amz-trailer", trailer)
+		req.ContentLength = chunkedContentLength(size, trailer, newHash().Size())
+	} else {
+		req.ContentLength = chunkedContentLength(size, "", 0)
+	}
+
+	encoding := "aws-chunked"
+	if ce := req.Header.Get("Content-Encoding"); ce != "" {
+		encoding += "," + ce
+	}
+	req.Header.Set("Content-Encoding", encoding)
+	req.Header.Set("Content-Length", strconv.FormatInt(req.ContentLength, 10))
+	req.Header.Set("x-amz-decoded-content-length", strconv.FormatInt(size, 10))
+	req.Header.Set("x-amz-content-sha256", payload)
+	return nil
+}
+
+// isStreamingPayload reports whether req is sent using aws-chunked
+// encoding with signed chunks.
+func isStreamingPayload(req *http.Request) bool {
+	return strings.HasPrefix(req.Header.Get("x-amz-content-sha256"), streamingPayload)
+}
+
+// chunkedContentLength returns the length of a payload of size bytes
+// once encoded, with a trailer holding a checksum of sumSize bytes.
+func chunkedContentLength(size int64, trailer string, sumSize int) int64 {
+	chunkLen := func(n int64) int64 {
+		return int64(len(strconv.FormatInt(n, 16))+len(chunkSignaturePrefix)+chunkSignatureLen+2) + n + 2
+	}
+
+	total := size / streamingChunkSize * chunkLen(streamingChunkSize)
+	if rem := size % streamingChunkSize; rem > 0 {
+		total += chunkLen(rem)
+	}
+	total += chunkLen(0)
+
+	if trailer != "" {
+		// The last chunk is followed by the trailer instead of a blank line.
+		total -= 2
+		total += int64(len(trailer) + 1 + base64.StdEncoding.EncodedLen(sumSize) + 2)
+		total += int64(len(trailerSignaturePrefix) + chunkSignatureLen + 2)
+		total += 2
+	}
+	return total
+}
+
+// chunkedReader encodes the payload read from body using aws-chunked
+// encoding, signing each chunk with the signature of the previous one,
+// starting with the signature of the request itself.
+type chunkedReader struct {
+	body io.ReadCloser
+
+	key     []byte
+	date    string
+	scope   string
+	prevSig string
+
+	trailer  string
+	checksum hash.Hash
+
+	buf  []byte
+	out  bytes.Buffer
+	done bool
+}
+
+// newChunkedReader returns a reader encoding body, for a request signed
+// at t using key within scope, whose signature is seed.
+func newChunkedReader(body io.ReadCloser, key []byte, t time.Time, scope, seed, trailer string) *chunkedReader {
+	c := &chunkedReader{
+		body:    body,
+		key:     key,
+		date:    t.Format(amzDateISO8601TimeFormat),
+		scope:   scope,
+		prevSig: seed,
+		trailer: trailer,
+		buf:     make([]byte, streamingChunkSize),
+	}
+	if newHash, ok := trailerChecksums[trailer]; ok {
+		c.checksum = newHash()
+	}
+	return c
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for c.out.Len() == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	return c.out.Read(p)
+}
+
+func (c *chunkedReader) Close() error {
+	if c.body == nil {
+		return nil
+	}
+	return c.body.Close()
+}
+
+// nextChunk reads the next chunk of the body and encodes it in c.out.
+// Once the body is exhausted, it encodes the final, empty chunk and
+// the trailer.
+func (c *chunkedReader) nextChunk() error {
+	var n int
+	if c.body != nil {
+		var err error
+		n, err = io.ReadFull(c.body, c.buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+	}
+
+	data := c.buf[:n]
+	if c.checksum != nil {
+		c.checksum.Write(data)
+	}
+
+	sig := c.sign("AWS4-HMAC-SHA256-PAYLOAD", emptyPayloadHash, data)
+	fmt.Fprintf(&c.out, "%x%s%s\r\n", n, chunkSignaturePrefix, sig)
+
+	if n > 0 {
+		c.out.Write(data)
+		c.out.WriteString("\r\n")
+		return nil
+	}
+
+	c.done = true
+	if c.checksum != nil {
+		trailer := c.trailer + ":" + base64.StdEncoding.EncodeToString(c.checksum.Sum(nil))
+		c.out.WriteString(trailer + "\r\n")
+
+		sig := c.sign("AWS4-HMAC-SHA256-TRAILER", "", []byte(trailer+"\n"))
+		c.out.WriteString(trailerSignaturePrefix + sig + "\r\n")
+	}
+	c.out.WriteString("\r\n")
+	return nil
+}
+
+// sign returns the signature of a chunk (or of the trailer), which
+// chains it to the previous one.
+func (c *chunkedReader) sign(algorithm, headersHash string, data []byte) string {
+	dataHash := sha256.Sum256(data)
+
+	parts := []string{algorithm, c.date, c.scope, c.prevSig}
+	if headersHash != "" {
+		parts = append(parts, headersHash)
+	}
+	parts = append(parts, hex.EncodeToString(dataHash[:]))
+
+	c.prevSig = hex.EncodeToString(makeHMac(c.key, []byte(strings.Join(parts, "\n"))))
+	return c.prevSig
+}
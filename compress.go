@@ -0,0 +1,186 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// CompressionGzip compresses objects using gzip. It is the compression
+// supported out of the box; others, such as zstd, can be added using
+// RegisterCompressor.
+const CompressionGzip = "gzip"
+
+// compressionMetaKey is the metadata key recording the compression of
+// an object uploaded with Compression, so that it is decompressed when
+// it is downloaded. It is prefixed so as not to clash with metadata of
+// the application.
+const compressionMetaKey = "simples3-compression"
+
+// Compressor compresses and decompresses objects with a content encoding.
+type Compressor interface {
+	// Encoding is the Content-Encoding of the compressed data, e.g.
+	// "gzip", and the value of Compression selecting it.
+	Encoding() string
+	// NewWriter returns a writer compressing the data written to w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader decompressing the data read from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{CompressionGzip: gzipCompressor{}}
+)
+
+// RegisterCompressor makes c available as Compression for uploads, and
+// to download the objects compressed with it, replacing any compressor
+// already registered for its encoding.
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Encoding()] = c
+}
+
+// compressorFor returns the compressor registered for encoding.
+func compressorFor(encoding string) (Compressor, error) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	c, ok := compressors[encoding]
+	if !ok {
+		return nil, fmt.Errorf("unsupported compression %q", encoding)
+	}
+	return c, nil
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Encoding() string { return CompressionGzip }
+
+func (gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// contentEncoding returns the Content-Encoding of an upload with the
+// given ContentEncoding and Compression, which can't be different.
+func contentEncoding(encoding, compression string) (string, error) {
+	switch {
+	case compression == "":
+		return encoding, nil
+	case encoding != "" && encoding != compression:
+		return "", fmt.Errorf("content encoding %q doesn't match compression %q", encoding, compression)
+	}
+	return compression, nil
+}
+
+// withCompression returns a copy of metadata recording encoding.
+func withCompression(metadata map[string]string, encoding string) map[string]string {
+	out := maps.Clone(metadata)
+	if out == nil {
+		out = map[string]string{}
+	}
+	out[compressionMetaKey] = encoding
+	return out
+}
+
+// objectCompression returns the compression recorded in the metadata
+// of an object, if any. A compression without a registered compressor
+// is ignored, and the object is downloaded as it is stored.
+func objectCompression(h http.Header) string {
+	encoding := h.Get(AMZMetaPrefix + compressionMetaKey)
+	if encoding == "" {
+		return ""
+	}
+	if _, err := compressorFor(encoding); err != nil {
+		return ""
+	}
+	return encoding
+}
+
+// compressBody compresses the data read from r in memory, as the size
+// of the compressed body must be known to upload it in a single request.
+func compressBody(c Compressor, r io.Reader) (*bytes.Reader, error) {
+	var buf bytes.Buffer
+	zw, err := c.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(zw, r); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(buf.Bytes()), nil
+}
+
+// compressReader returns a reader of the data read from r, compressed
+// as it is read. Closing it stops the compression.
+func compressReader(c Compressor, r io.Reader) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	zw, err := c.NewWriter(pw)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		_, err := io.Copy(zw, r)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+// decompressReader decompresses a response body, and closes the body
+// along with the decompressor.
+type decompressReader struct {
+	io.ReadCloser
+	body io.Closer
+}
+
+func newDecompressReader(c Compressor, body io.ReadCloser) (io.ReadCloser, error) {
+	zr, err := c.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+	return &decompressReader{ReadCloser: zr, body: body}, nil
+}
+
+func (r *decompressReader) Close() error {
+	err := r.ReadCloser.Close()
+	if bodyErr := r.body.Close(); err == nil {
+		err = bodyErr
+	}
+	return err
+}
+
+// decompressFile decompresses the file at src into w, from its start.
+func decompressFile(c Compressor, src string, w io.Writer) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := c.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	_, err = io.Copy(w, zr)
+	return err
+}
@@ -0,0 +1,223 @@
+package simples3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// renamedGzip is gzip under another encoding, which the transport
+// doesn't decompress by itself.
+type renamedGzip struct{ gzipCompressor }
+
+func (renamedGzip) Encoding() string { return "x-test-gzip" }
+
+func init() {
+	RegisterCompressor(renamedGzip{})
+}
+
+func gunzip(t *testing.T, data []byte) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestFilePut_Compression(t *testing.T) {
+	store := newObjectStore(t)
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(store.URL)
+
+	content := []byte(strings.Repeat(`{"level":"info","msg":"request served"}`+"\n", 5000))
+
+	tests := []struct {
+		name        string
+		input       UploadInput
+		compression string
+	}{
+		{"Body", UploadInput{Body: bytes.NewReader(content)}, CompressionGzip},
+		{"Reader", UploadInput{Reader: iotest.HalfReader(bytes.NewReader(content)), ContentLength: int64(len(content))}, CompressionGzip},
+		{"Registered", UploadInput{Body: bytes.NewReader(content)}, "x-test-gzip"},
+		{"ReaderWithoutLength", UploadInput{Reader: iotest.HalfReader(bytes.NewReader(content))}, CompressionGzip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.Bucket, tt.input.ObjectKey = "bucket", "key"
+			tt.input.Compression = tt.compression
+			tt.input.ChecksumAlgorithm = ChecksumCRC32
+			if _, err := s3.FilePut(tt.input); err != nil {
+				t.Fatal(err)
+			}
+
+			stored := store.objects["/bucket/key"]
+			if len(stored) >= len(content) {
+				t.Errorf("stored %d bytes for %d bytes of content", len(stored), len(content))
+			}
+			if !bytes.Equal(gunzip(t, stored), content) {
+				t.Errorf("stored object doesn't decompress to the content")
+			}
+			if got := store.headers["/bucket/key"].Get("Content-Encoding"); got != tt.compression {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.compression)
+			}
+
+			for _, validate := range []bool{false, true} {
+				res, err := s3.GetObject(DownloadInput{Bucket: "bucket", ObjectKey: "key", ValidateChecksum: validate})
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := io.ReadAll(res.Body)
+				res.Body.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, content) {
+					t.Errorf("downloaded %d bytes, which don't match the content", len(got))
+				}
+				if res.Compression != tt.compression {
+					t.Errorf("Compression = %q, want %q", res.Compression, tt.compression)
+				}
+			}
+		})
+	}
+
+	t.Run("Range", func(t *testing.T) {
+		res, err := s3.GetObject(DownloadInput{Bucket: "bucket", ObjectKey: "key", Range: "bytes=0-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if got, _ := io.ReadAll(res.Body); !bytes.Equal(got, []byte{0x1f, 0x8b}) {
+			t.Errorf("expected the range of the compressed data, got %x", got)
+		}
+	})
+
+	t.Run("ForeignMetadata", func(t *testing.T) {
+		// Objects with metadata of their own, or uploaded with a
+		// compression that isn't registered, are downloaded as stored.
+		for _, key := range []string{"compression", compressionMetaKey} {
+			h := http.Header{}
+			h.Set(AMZMetaPrefix+key, "zstd")
+			store.put("/bucket/plain", []byte("plain content"), h)
+
+			res, err := s3.GetObject(DownloadInput{Bucket: "bucket", ObjectKey: "plain"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil || string(got) != "plain content" || res.Compression != "" {
+				t.Errorf("%s: got %q, %v, compression %q", key, got, err, res.Compression)
+			}
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := s3.FilePut(UploadInput{Bucket: "bucket", ObjectKey: "key", Body: bytes.NewReader(content), Compression: "br"})
+		if err == nil {
+			t.Errorf("expected an unsupported compression to be rejected")
+		}
+	})
+}
+
+func TestFileUploadMultipart_Compression(t *testing.T) {
+	content := make([]byte, 2*MinPartSize+1234)
+	rand.Read(content)
+
+	stub := newMultipartStub(t)
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(stub.URL)
+
+	_, err := s3.FileUploadMultipart(MultipartUploadInput{
+		Bucket:      "bucket",
+		ObjectKey:   "key",
+		Body:        bytes.NewReader(content),
+		Concurrency: 2,
+		Compression: CompressionGzip,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(gunzip(t, stub.completed), content) {
+		t.Errorf("uploaded object doesn't decompress to the content")
+	}
+	if got := stub.initiate.Header.Get("Content-Encoding"); got != CompressionGzip {
+		t.Errorf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := objectCompression(stub.initiate.Header); got != CompressionGzip {
+		t.Errorf("compression metadata = %q, want gzip", got)
+	}
+
+	_, err = s3.FileUploadMultipart(MultipartUploadInput{
+		Bucket:      "bucket",
+		ObjectKey:   "key",
+		Body:        bytes.NewReader(content),
+		Compression: CompressionGzip,
+		Checkpoint:  FileCheckpointStore{Path: filepath.Join(t.TempDir(), "checkpoint.json")},
+	})
+	if err == nil {
+		t.Errorf("expected resumable uploads to be rejected")
+	}
+}
+
+func TestFileDownloadParallel_Compression(t *testing.T) {
+	store := newObjectStore(t)
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(store.URL)
+
+	content := []byte(strings.Repeat("timestamp,level,message\n", 10000))
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(content)
+	zw.Close()
+
+	h := http.Header{}
+	h.Set("Content-Encoding", CompressionGzip)
+	h.Set(AMZMetaPrefix+compressionMetaKey, CompressionGzip)
+	store.put("/bucket/key", buf.Bytes(), h)
+
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		download func(path string) error
+	}{
+		{"Parallel", func(path string) error {
+			_, err := s3.FileDownloadParallel(ParallelDownloadInput{
+				Bucket: "bucket", ObjectKey: "key", PartSize: 100, Concurrency: 4,
+			}, path)
+			return err
+		}},
+		{"Resumable", func(path string) error {
+			_, err := s3.FileDownloadResumable(ResumableDownloadInput{Bucket: "bucket", ObjectKey: "key"}, path)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := tt.download(path); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("downloaded %d bytes, which don't match the %d of the content", len(got), len(content))
+			}
+		})
+	}
+}
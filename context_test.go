@@ -0,0 +1,77 @@
+package simples3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithContext_Cancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	t.Run("FileDownload", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := s3.FileDownloadWithContext(ctx, DownloadInput{
+			Bucket:    "bucket",
+			ObjectKey: "key",
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("FileDownloadWithContext() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := s3.ListWithContext(ctx, ListInput{Bucket: "bucket"})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ListWithContext() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestUploadPartWithRetry_ContextCancelled(t *testing.T) {
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := s3.uploadPartWithRetry(ctx, UploadPartInput{
+		Bucket:     "bucket",
+		ObjectKey:  "key",
+		UploadID:   "upload-id",
+		PartNumber: 1,
+		Body:       bytes.NewReader([]byte("data")),
+		Size:       4,
+	}, 10)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("uploadPartWithRetry() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if calls >= 10 {
+		t.Errorf("expected retries to stop after the context expired, got %d calls", calls)
+	}
+}
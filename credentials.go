@@ -0,0 +1,341 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNoCredentials is returned (wrapped) by a CredentialsProvider
+// that could not find any credentials to provide.
+var ErrNoCredentials = errors.New("no credentials found")
+
+// Credentials are the AWS credentials used to sign requests.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Expires is the time after which the credentials are no
+	// longer valid. The zero value means they never expire.
+	Expires time.Time
+}
+
+// expired reports whether the credentials have expired.
+func (c Credentials) expired() bool {
+	return !c.Expires.IsZero() && !time.Now().Before(c.Expires)
+}
+
+// CredentialsProvider supplies the credentials used to sign requests.
+// The client calls Retrieve the first time credentials are needed and
+// again whenever the credentials it got last are about to expire
+// (see SetCredentialsRefreshWindow).
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// NewWithProvider returns an instance of S3 which gets its
+// credentials from p.
+func NewWithProvider(region string, p CredentialsProvider) *S3 {
+	return New(region, "", "").SetCredentialsProvider(p)
+}
+
+// SetCredentialsProvider sets the provider the client gets its
+// credentials from. Credentials set earlier are discarded.
+func (s3 *S3) SetCredentialsProvider(p CredentialsProvider) *S3 {
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+
+	s3.provider = p
+	s3.refresh = nil
+	s3.AccessKey, s3.SecretKey, s3.Token = "", "", ""
+	s3.expiry = time.Time{}
+	return s3
+}
+
+// credentials returns the credentials to sign a request with,
+// retrieving them from the client's provider first if needed.
+// The credentials are read as a whole under the lock, so that a
+// request is never signed with a half-updated set.
+func (s3 *S3) credentials(ctx context.Context) (Credentials, error) {
+	if err := s3.renewIAMToken(ctx); err != nil {
+		return Credentials{}, err
+	}
+
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+
+	return Credentials{
+		AccessKeyID:     s3.AccessKey,
+		SecretAccessKey: s3.SecretKey,
+		SessionToken:    s3.Token,
+		Expires:         s3.expiry,
+	}, nil
+}
+
+// StaticProvider provides a fixed set of credentials.
+type StaticProvider struct {
+	Credentials
+}
+
+// Retrieve returns the static credentials.
+func (p StaticProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	if p.AccessKeyID == "" || p.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("static credentials: %w", ErrNoCredentials)
+	}
+	return p.Credentials, nil
+}
+
+// EnvProvider provides credentials from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+// AWS_ACCESS_KEY and AWS_SECRET_KEY are accepted as fallbacks.
+type EnvProvider struct{}
+
+// Retrieve reads the credentials from the environment.
+func (EnvProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     firstEnv("AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY"),
+		SecretAccessKey: firstEnv("AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("environment: %w", ErrNoCredentials)
+	}
+	return creds, nil
+}
+
+// firstEnv returns the value of the first non-empty environment
+// variable out of keys.
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// SharedCredentialsProvider provides credentials from the shared
+// credentials file (~/.aws/credentials) and config file (~/.aws/config)
+// used by the AWS CLI and SDKs. The credentials file takes precedence.
+type SharedCredentialsProvider struct {
+	// Filename is the path to the credentials file. Defaults to
+	// $AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials.
+	Filename string
+
+	// ConfigFilename is the path to the config file. Defaults to
+	// $AWS_CONFIG_FILE or ~/.aws/config.
+	ConfigFilename string
+
+	// Profile is the profile to use. Defaults to $AWS_PROFILE or "default".
+	Profile string
+}
+
+// Retrieve reads the credentials of the profile from the shared files.
+func (p SharedCredentialsProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	profile := p.Profile
+	if profile == "" {
+		profile = firstEnv("AWS_PROFILE", "AWS_DEFAULT_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+
+	credsFile := p.Filename
+	if credsFile == "" {
+		credsFile = sharedFilename("AWS_SHARED_CREDENTIALS_FILE", "credentials")
+	}
+	configFile := p.ConfigFilename
+	if configFile == "" {
+		configFile = sharedFilename("AWS_CONFIG_FILE", "config")
+	}
+
+	// Profiles in the config file, except for the default one,
+	// are named "profile <name>".
+	configSection := profile
+	if profile != "default" {
+		configSection = "profile " + profile
+	}
+
+	for _, f := range []struct{ name, section string }{
+		{credsFile, profile},
+		{configFile, configSection},
+	} {
+		if f.name == "" {
+			continue
+		}
+
+		sections, err := loadSharedFile(f.name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Credentials{}, err
+		}
+
+		s := sections[f.section]
+		creds := Credentials{
+			AccessKeyID:     s["aws_access_key_id"],
+			SecretAccessKey: s["aws_secret_access_key"],
+			SessionToken:    s["aws_session_token"],
+		}
+		if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+			return creds, nil
+		}
+	}
+
+	return Credentials{}, fmt.Errorf("shared credentials profile %q: %w", profile, ErrNoCredentials)
+}
+
+// sharedFilename returns the path to a shared AWS file, taken from
+// the environment variable env or found in ~/.aws otherwise.
+func sharedFilename(env, name string) string {
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", name)
+}
+
+// loadSharedFile parses an INI formatted shared credentials or config
+// file into a map of section name to keys and values.
+func loadSharedFile(name string) (map[string]map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		sections = map[string]map[string]string{}
+		current  map[string]string
+	)
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			name := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			if sections[name] == nil {
+				sections[name] = map[string]string{}
+			}
+			current = sections[name]
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			continue
+		}
+		current[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", name, err)
+	}
+
+	return sections, nil
+}
+
+// IMDSProvider provides the credentials of the IAM role attached to
+// the EC2 instance, fetched from the instance metadata service.
+// It is disabled if AWS_EC2_METADATA_DISABLED is set to "true".
+type IMDSProvider struct {
+	// Client is used for the metadata requests. Defaults to a
+	// client with a timeout of 3 seconds.
+	Client *http.Client
+
+	// Endpoint is the base URL of the metadata service.
+	// Defaults to http://169.254.169.254/latest.
+	Endpoint string
+}
+
+// Retrieve fetches the role's credentials from the metadata service.
+func (p IMDSProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return Credentials{}, fmt.Errorf("instance metadata disabled: %w", ErrNoCredentials)
+	}
+
+	cl := p.Client
+	if cl == nil {
+		cl = &http.Client{
+			// Set a timeout of 3 seconds for AWS IAM Calls.
+			Timeout: time.Second * 3, //nolint:gomnd
+		}
+	}
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = metadataBaseURL
+	}
+
+	iamResp, err := fetchIAMData(ctx, cl, endpoint)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("error fetching IAM data: %w", err)
+	}
+
+	return Credentials{
+		AccessKeyID:     iamResp.AccessKeyID,
+		SecretAccessKey: iamResp.SecretAccessKey,
+		SessionToken:    iamResp.Token,
+		Expires:         iamResp.Expiration,
+	}, nil
+}
+
+// ChainProvider tries each of its Providers in order and returns
+// the credentials of the first one that succeeds.
+type ChainProvider struct {
+	Providers []CredentialsProvider
+}
+
+// DefaultCredentialsChain returns the chain used to look up credentials
+// the same way the AWS CLI does: environment variables, then the shared
+// credentials and config files, then a web identity token (as set up by
+// EKS), then the ECS/EKS container credentials endpoint, then the EC2
+// instance metadata service.
+func DefaultCredentialsChain() *ChainProvider {
+	return &ChainProvider{
+		Providers: []CredentialsProvider{
+			EnvProvider{},
+			SharedCredentialsProvider{},
+			&WebIdentityProvider{},
+			ContainerProvider{},
+			IMDSProvider{},
+		},
+	}
+}
+
+// Retrieve returns the credentials of the first provider that succeeds,
+// or an error wrapping the errors of all providers.
+func (p *ChainProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	var errs []error
+	for _, provider := range p.Providers {
+		creds, err := provider.Retrieve(ctx)
+		if err == nil {
+			return creds, nil
+		}
+		if ctx.Err() != nil {
+			return Credentials{}, ctx.Err()
+		}
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		return Credentials{}, ErrNoCredentials
+	}
+	return Credentials{}, fmt.Errorf("no provider in chain returned credentials: %w", errors.Join(errs...))
+}
@@ -0,0 +1,357 @@
+package simples3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingProvider returns creds and counts how often it is called.
+type countingProvider struct {
+	creds Credentials
+	calls atomic.Int32
+}
+
+func (p *countingProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	p.calls.Add(1)
+	return p.creds, nil
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_ACCESS_KEY", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SECRET_KEY", "")
+
+	if _, err := (EnvProvider{}).Retrieve(context.Background()); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("expected ErrNoCredentials, got %v", err)
+	}
+
+	t.Setenv("AWS_ACCESS_KEY", "legacy")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "token")
+
+	creds, err := (EnvProvider{}).Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "legacy" || creds.SecretAccessKey != "secret" || creds.SessionToken != "token" {
+		t.Errorf("unexpected credentials: %+v", creds)
+	}
+}
+
+func TestSharedCredentialsProvider(t *testing.T) {
+	dir := t.TempDir()
+	credsFile := filepath.Join(dir, "credentials")
+	configFile := filepath.Join(dir, "config")
+
+	os.WriteFile(credsFile, []byte(`
+# comment
+[default]
+aws_access_key_id = default-key
+aws_secret_access_key = default-secret
+
+[dev]
+aws_access_key_id=dev-key
+aws_secret_access_key=dev-secret
+aws_session_token=dev-token
+`), 0o600)
+	os.WriteFile(configFile, []byte(`
+[default]
+region = us-east-1
+
+[profile  ci]
+aws_access_key_id = ci-key
+aws_secret_access_key = ci-secret
+`), 0o600)
+
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_DEFAULT_PROFILE", "")
+
+	tests := []struct {
+		profile string
+		want    Credentials
+		wantErr bool
+	}{
+		{"", Credentials{AccessKeyID: "default-key", SecretAccessKey: "default-secret"}, false},
+		{"dev", Credentials{AccessKeyID: "dev-key", SecretAccessKey: "dev-secret", SessionToken: "dev-token"}, false},
+		{"ci", Credentials{AccessKeyID: "ci-key", SecretAccessKey: "ci-secret"}, false},
+		{"missing", Credentials{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			p := SharedCredentialsProvider{Filename: credsFile, ConfigFilename: configFile, Profile: tt.profile}
+			got, err := p.Retrieve(context.Background())
+			if tt.wantErr {
+				if !errors.Is(err, ErrNoCredentials) {
+					t.Fatalf("expected ErrNoCredentials, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("AWS_PROFILE", func(t *testing.T) {
+		t.Setenv("AWS_PROFILE", "dev")
+		got, err := SharedCredentialsProvider{Filename: credsFile, ConfigFilename: configFile}.Retrieve(context.Background())
+		if err != nil || got.AccessKeyID != "dev-key" {
+			t.Errorf("got %+v, %v", got, err)
+		}
+	})
+}
+
+func TestChainProvider(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_ACCESS_KEY", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SECRET_KEY", "")
+
+	static := StaticProvider{Credentials{AccessKeyID: "static", SecretAccessKey: "secret"}}
+	chain := &ChainProvider{Providers: []CredentialsProvider{EnvProvider{}, static}}
+
+	creds, err := chain.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "static" {
+		t.Errorf("expected credentials from the static provider, got %+v", creds)
+	}
+
+	chain = &ChainProvider{Providers: []CredentialsProvider{EnvProvider{}, StaticProvider{}}}
+	if _, err := chain.Retrieve(context.Background()); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+}
+
+func TestIMDSProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case imdsTokenURI:
+			w.Write([]byte("imds-token"))
+		case securityCredentialsURI:
+			w.Write([]byte("role"))
+		case securityCredentialsURI + "role":
+			w.Write([]byte(`{"AccessKeyId":"imds-key","SecretAccessKey":"imds-secret","Token":"imds-token","Expiration":"2030-01-01T00:00:00Z"}`))
+		}
+	}))
+	defer ts.Close()
+
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+	creds, err := IMDSProvider{Endpoint: ts.URL}.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "imds-key" || creds.SessionToken != "imds-token" || creds.Expires.Year() != 2030 {
+		t.Errorf("unexpected credentials: %+v", creds)
+	}
+
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	if _, err := (IMDSProvider{Endpoint: ts.URL}).Retrieve(context.Background()); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+}
+
+func TestNewWithProvider(t *testing.T) {
+	var auth, token string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		token = r.Header.Get("X-Amz-Security-Token")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	p := &countingProvider{creds: Credentials{
+		AccessKeyID:     "provided-key",
+		SecretAccessKey: "provided-secret",
+		SessionToken:    "provided-token",
+		Expires:         time.Now().Add(time.Hour),
+	}}
+	s3 := NewWithProvider("us-east-1", p)
+	s3.SetEndpoint(ts.URL)
+
+	for i := 0; i < 2; i++ {
+		if err := s3.FileDelete(DeleteInput{Bucket: "bucket", ObjectKey: "key"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !strings.Contains(auth, "Credential=provided-key/") || token != "provided-token" {
+		t.Errorf("request not signed with provided credentials: %q, %q", auth, token)
+	}
+	if n := p.calls.Load(); n != 1 {
+		t.Errorf("expected credentials to be retrieved once, got %d", n)
+	}
+
+	u := s3.GeneratePresignedURL(PresignedInput{Bucket: "bucket", ObjectKey: "key", Method: "GET", ExpirySeconds: 60})
+	if !strings.Contains(u, "provided-key") || !strings.Contains(u, "X-Amz-Security-Token=provided-token") {
+		t.Errorf("presigned URL not using provided credentials: %s", u)
+	}
+
+	policies, err := s3.CreateUploadPolicies(UploadConfig{BucketName: "bucket", ObjectKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(policies.Form["X-Amz-Credential"], "provided-key/") ||
+		policies.Form["X-Amz-Security-Token"] != "provided-token" {
+		t.Errorf("upload policy not using provided credentials: %v", policies.Form)
+	}
+
+	// Expired credentials are retrieved again.
+	p.creds.Expires = time.Now().Add(-time.Minute)
+	s3.SetCredentialsProvider(p)
+	s3.FileDelete(DeleteInput{Bucket: "bucket", ObjectKey: "key"})
+	s3.FileDelete(DeleteInput{Bucket: "bucket", ObjectKey: "key"})
+	if n := p.calls.Load(); n != 3 {
+		t.Errorf("expected expired credentials to be retrieved on every request, got %d calls", n)
+	}
+}
+
+// slowProvider blocks in Retrieve until release is closed or ctx is
+// done, and fails if err is set.
+type slowProvider struct {
+	countingProvider
+	release chan struct{}
+	err     error
+}
+
+func (p *slowProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return Credentials{}, ctx.Err()
+	}
+	creds, _ := p.countingProvider.Retrieve(ctx)
+	return creds, p.err
+}
+
+func TestCredentialsRefresh(t *testing.T) {
+	t.Run("SingleFlight", func(t *testing.T) {
+		p := &slowProvider{release: make(chan struct{})}
+		p.creds = Credentials{AccessKeyID: "key", SecretAccessKey: "secret", Expires: time.Now().Add(time.Hour)}
+		s3 := NewWithProvider("us-east-1", p)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				creds, err := s3.credentials(context.Background())
+				if err != nil || creds.AccessKeyID != "key" {
+					t.Errorf("got %+v, %v", creds, err)
+				}
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(p.release)
+		wg.Wait()
+
+		if n := p.calls.Load(); n != 1 {
+			t.Errorf("expected a single retrieval, got %d", n)
+		}
+	})
+
+	t.Run("CancelledLeader", func(t *testing.T) {
+		p := &slowProvider{release: make(chan struct{})}
+		p.creds = Credentials{AccessKeyID: "key", SecretAccessKey: "secret", Expires: time.Now().Add(time.Hour)}
+		s3 := NewWithProvider("us-east-1", p)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		leader := make(chan error, 1)
+		go func() {
+			_, err := s3.credentials(ctx)
+			leader <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		follower := make(chan error, 1)
+		go func() {
+			creds, err := s3.credentials(context.Background())
+			if err == nil && creds.AccessKeyID != "key" {
+				err = errors.New("unexpected credentials " + creds.AccessKeyID)
+			}
+			follower <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		// The follower retrieves the credentials once the leader is gone.
+		cancel()
+		if err := <-leader; !errors.Is(err, context.Canceled) {
+			t.Errorf("leader: expected the cancellation, got %v", err)
+		}
+		close(p.release)
+		if err := <-follower; err != nil {
+			t.Errorf("follower: %v", err)
+		}
+		if n := p.calls.Load(); n != 1 {
+			t.Errorf("expected a single retrieval, got %d", n)
+		}
+	})
+
+	t.Run("Window", func(t *testing.T) {
+		p := &countingProvider{creds: Credentials{AccessKeyID: "key", SecretAccessKey: "secret", Expires: time.Now().Add(2 * time.Minute)}}
+		s3 := NewWithProvider("us-east-1", p)
+
+		// Within the default window, credentials are refreshed on every use.
+		s3.credentials(context.Background())
+		s3.credentials(context.Background())
+		if n := p.calls.Load(); n != 2 {
+			t.Errorf("expected credentials within the window to be refreshed, got %d calls", n)
+		}
+
+		s3.SetCredentialsRefreshWindow(time.Minute)
+		s3.credentials(context.Background())
+		if n := p.calls.Load(); n != 2 {
+			t.Errorf("expected credentials outside the window to be reused, got %d calls", n)
+		}
+	})
+
+	t.Run("FailedRefresh", func(t *testing.T) {
+		p := &slowProvider{release: make(chan struct{})}
+		close(p.release)
+		p.creds = Credentials{AccessKeyID: "key", SecretAccessKey: "secret", Expires: time.Now().Add(2 * time.Minute)}
+		s3 := NewWithProvider("us-east-1", p)
+		if _, err := s3.credentials(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		// Credentials that are still valid are used if the refresh fails.
+		p.err = errors.New("provider down")
+		creds, err := s3.credentials(context.Background())
+		if err != nil || creds.AccessKeyID != "key" {
+			t.Errorf("got %+v, %v", creds, err)
+		}
+
+		s3.SetIAMData(IAMResponse{AccessKeyID: "key", SecretAccessKey: "secret", Expiration: time.Now().Add(-time.Minute)})
+		if _, err := s3.credentials(context.Background()); err == nil {
+			t.Errorf("expected an error once the credentials expired")
+		}
+	})
+
+	t.Run("Refresher", func(t *testing.T) {
+		p := &countingProvider{creds: Credentials{AccessKeyID: "key", SecretAccessKey: "secret", Expires: time.Now().Add(time.Hour)}}
+		s3 := NewWithProvider("us-east-1", p)
+		s3.SetCredentialsRefreshWindow(time.Hour - time.Second)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		s3.StartCredentialsRefresher(ctx)
+
+		time.Sleep(1500 * time.Millisecond)
+		if n := p.calls.Load(); n < 2 {
+			t.Errorf("expected the refresher to refresh credentials, got %d calls", n)
+		}
+	})
+}
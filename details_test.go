@@ -0,0 +1,101 @@
+package simples3
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFileDetails_Typed(t *testing.T) {
+	var gotReq *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifySigV4(r, "SuperSecretKey"); err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		gotReq = r
+
+		h := w.Header()
+		h.Set("Last-Modified", "Wed, 02 Jan 2030 03:04:05 GMT")
+		h.Set("x-amz-version-id", "v1")
+		if r.URL.Query().Get("versionId") == "deleted" {
+			h.Set("x-amz-delete-marker", "true")
+			h.Set("x-amz-version-id", "deleted")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		h.Set("Content-Length", "1234")
+		h.Set("x-amz-checksum-crc32c", "yZRlqg==")
+		h.Set("x-amz-checksum-type", ChecksumTypeFullObject)
+		h.Set("x-amz-object-lock-mode", ObjectLockModeGovernance)
+		h.Set("x-amz-object-lock-retain-until-date", "2031-01-01T00:00:00.000Z")
+		h.Set("x-amz-object-lock-legal-hold", ObjectLockLegalHoldOn)
+		h.Set("x-amz-replication-status", "COMPLETED")
+		h.Set("x-amz-mp-parts-count", "3")
+		h.Set("x-amz-expiration", `expiry-date="Fri, 23 Dec 2030 00:00:00 GMT", rule-id="logs"`)
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	details, err := s3.FileDetails(DetailsInput{
+		Bucket:          "bucket",
+		ObjectKey:       "key",
+		PartNumber:      1,
+		IncludeChecksum: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotReq.URL.Query().Get("partNumber") != "1" || gotReq.Header.Get("x-amz-checksum-mode") != "ENABLED" {
+		t.Errorf("unexpected request %s %v", gotReq.URL, gotReq.Header)
+	}
+
+	if details.Size != 1234 || details.ContentLength != "1234" {
+		t.Errorf("Size = %d, ContentLength = %q", details.Size, details.ContentLength)
+	}
+	if want := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC); !details.LastModifiedTime.Equal(want) {
+		t.Errorf("LastModifiedTime = %v, want %v", details.LastModifiedTime, want)
+	}
+	if details.DateTime.IsZero() {
+		t.Errorf("DateTime not parsed from %q", details.Date)
+	}
+	if details.VersionId != "v1" || details.DeleteMarker {
+		t.Errorf("VersionId = %q, DeleteMarker = %v", details.VersionId, details.DeleteMarker)
+	}
+	if details.ChecksumAlgorithm != ChecksumCRC32C || details.Checksum != "yZRlqg==" || details.ChecksumType != ChecksumTypeFullObject {
+		t.Errorf("unexpected checksum %s %s %s", details.ChecksumAlgorithm, details.Checksum, details.ChecksumType)
+	}
+	if details.ObjectLockMode != ObjectLockModeGovernance ||
+		!details.ObjectLockRetainUntilDate.Equal(time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)) ||
+		details.ObjectLockLegalHoldStatus != ObjectLockLegalHoldOn {
+		t.Errorf("unexpected object lock %s %v %s", details.ObjectLockMode, details.ObjectLockRetainUntilDate, details.ObjectLockLegalHoldStatus)
+	}
+	if details.ReplicationStatus != "COMPLETED" || details.PartsCount != 3 {
+		t.Errorf("ReplicationStatus = %q, PartsCount = %d", details.ReplicationStatus, details.PartsCount)
+	}
+
+	// Headers without a field stay available.
+	if details.ExtraHeaders["X-Amz-Expiration"] == "" || details.Headers.Get("x-amz-expiration") == "" {
+		t.Errorf("raw headers missing: %v", details.Headers)
+	}
+
+	t.Run("DeleteMarker", func(t *testing.T) {
+		details, err := s3.FileDetails(DetailsInput{Bucket: "bucket", ObjectKey: "key", VersionId: "deleted"})
+		if err == nil {
+			t.Fatal("expected an error for a delete marker")
+		}
+		if !details.DeleteMarker || details.VersionId != "deleted" || details.LastModifiedTime.IsZero() {
+			t.Errorf("unexpected details %+v", details)
+		}
+		if !IsDeleteMarker(err) {
+			t.Errorf("expected a delete marker error, got %v", err)
+		}
+		if e, _ := asS3Error(err); e.VersionId != "deleted" || e.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("unexpected error %+v", e)
+		}
+	})
+}
@@ -0,0 +1,526 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ParallelDownloadInput contains parameters for a concurrent ranged download
+type ParallelDownloadInput struct {
+	Bucket      string       // Required: bucket name
+	ObjectKey   string       // Required: object key
+	VersionId   string       // Optional: version ID of the object to download
+	PartSize    int64        // Optional: size of the ranges fetched, default 5MB
+	Concurrency int          // Optional: default 1 (sequential)
+	MaxRetries  int          // Optional: retries per range, default 3
+	OnProgress  ProgressFunc // Optional: progress callback
+
+	// Optional: 32 byte key the object is encrypted with (SSE-C)
+	SSECustomerKey []byte
+}
+
+// DownloadToWriterAt downloads an object into w, fetching ranges of
+// PartSize bytes concurrently. A range whose download fails or is cut
+// short is retried from the last byte received. Every range is fetched
+// with If-Match set to the ETag of the object, so the download fails
+// with a precondition error if the object changes in the meantime.
+// It returns the details of the object downloaded.
+//
+// Objects uploaded with Compression are downloaded to a temporary file
+// first, and decompressed into w.
+func (s3 *S3) DownloadToWriterAt(input ParallelDownloadInput, w io.WriterAt) (DetailsResponse, error) {
+	return s3.DownloadToWriterAtWithContext(context.Background(), input, w)
+}
+
+// DownloadToWriterAtWithContext is like DownloadToWriterAt but uses ctx for the requests.
+func (s3 *S3) DownloadToWriterAtWithContext(ctx context.Context, input ParallelDownloadInput, w io.WriterAt) (DetailsResponse, error) {
+	if input.Bucket == "" {
+		return DetailsResponse{}, fmt.Errorf("bucket name is required")
+	}
+	if input.ObjectKey == "" {
+		return DetailsResponse{}, fmt.Errorf("object key is required")
+	}
+
+	partSize := input.PartSize
+	if partSize == 0 {
+		partSize = DefaultPartSize
+	}
+	if partSize < 0 {
+		return DetailsResponse{}, fmt.Errorf("part size must be greater than 0")
+	}
+
+	concurrency := input.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	details, err := s3.FileDetailsWithContext(ctx, DetailsInput{
+		Bucket:         input.Bucket,
+		ObjectKey:      input.ObjectKey,
+		VersionId:      input.VersionId,
+		SSECustomerKey: input.SSECustomerKey,
+	})
+	if err != nil {
+		return DetailsResponse{}, err
+	}
+	size := details.Size
+	if size < 0 {
+		return DetailsResponse{}, fmt.Errorf("invalid content length %q", details.ContentLength)
+	}
+
+	encoding := objectCompression(details.Headers)
+	if encoding == "" {
+		if err := s3.downloadRanges(ctx, input, details.Etag, size, partSize, concurrency, w); err != nil {
+			return DetailsResponse{}, err
+		}
+		return details, nil
+	}
+
+	c, err := compressorFor(encoding)
+	if err != nil {
+		return DetailsResponse{}, err
+	}
+	tmp, err := os.CreateTemp("", "simples3-download-*")
+	if err != nil {
+		return DetailsResponse{}, err
+	}
+	defer os.Remove(tmp.Name())
+	err = s3.downloadRanges(ctx, input, details.Etag, size, partSize, concurrency, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return DetailsResponse{}, err
+	}
+	if err := decompressFile(c, tmp.Name(), io.NewOffsetWriter(w, 0)); err != nil {
+		return DetailsResponse{}, fmt.Errorf("error decompressing %s/%s: %w", input.Bucket, input.ObjectKey, err)
+	}
+	return details, nil
+}
+
+// downloadRanges downloads the size bytes of the object of input, which
+// must have the given ETag, into w, fetching ranges of partSize bytes
+// with concurrency workers.
+func (s3 *S3) downloadRanges(ctx context.Context, input ParallelDownloadInput, etag string, size, partSize int64, concurrency int, w io.WriterAt) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		totalParts = int((size + partSize - 1) / partSize)
+		partNums   = make(chan int)
+		wg         sync.WaitGroup
+
+		mu              sync.Mutex
+		downloadedBytes int64
+		downloadErr     error
+		startTime       = time.Now()
+	)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for partNum := range partNums {
+				first := int64(partNum-1) * partSize
+				last := min(first+partSize, size) - 1
+				err := s3.downloadRange(ctx, input, etag, first, last, w)
+
+				mu.Lock()
+				if err != nil {
+					if downloadErr == nil {
+						downloadErr = err
+					}
+					mu.Unlock()
+					cancel()
+					continue
+				}
+
+				downloadedBytes += last - first + 1
+
+				// Call progress callback
+				if input.OnProgress != nil {
+					elapsed := time.Since(startTime).Seconds()
+					bytesPerSecond := int64(0)
+					if elapsed > 0 {
+						bytesPerSecond = int64(float64(downloadedBytes) / elapsed)
+					}
+
+					input.OnProgress(ProgressInfo{
+						TotalBytes:     size,
+						UploadedBytes:  downloadedBytes,
+						CurrentPart:    partNum,
+						TotalParts:     totalParts,
+						BytesPerSecond: bytesPerSecond,
+					})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for partNum := 1; partNum <= totalParts; partNum++ {
+		select {
+		case partNums <- partNum:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(partNums)
+	wg.Wait()
+
+	if downloadErr != nil {
+		return downloadErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if downloadedBytes != size {
+		return fmt.Errorf("downloaded %d bytes, expected %d", downloadedBytes, size)
+	}
+
+	return nil
+}
+
+// FileDownloadParallel downloads an object to the file at path, which
+// is created or truncated, like DownloadToWriterAt. The file is removed
+// if the download fails.
+func (s3 *S3) FileDownloadParallel(input ParallelDownloadInput, path string) (DetailsResponse, error) {
+	return s3.FileDownloadParallelWithContext(context.Background(), input, path)
+}
+
+// FileDownloadParallelWithContext is like FileDownloadParallel but uses ctx for the requests.
+func (s3 *S3) FileDownloadParallelWithContext(ctx context.Context, input ParallelDownloadInput, path string) (DetailsResponse, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return DetailsResponse{}, err
+	}
+
+	details, err := s3.DownloadToWriterAtWithContext(ctx, input, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return DetailsResponse{}, err
+	}
+
+	return details, nil
+}
+
+// downloadRange downloads bytes first to last (inclusive) of the object
+// of input, which must have the given ETag, into w at the same offset.
+// Failed attempts are retried, resuming from the last byte written.
+func (s3 *S3) downloadRange(ctx context.Context, input ParallelDownloadInput, etag string, first, last int64, w io.WriterAt) error {
+	maxRetries := input.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = DefaultMaxRetries
+	}
+
+	policy := s3.getRetryPolicy(ctx)
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	// Every attempt is a single request, which s3.do must not retry
+	// itself: the next attempt resumes from the last byte written.
+	once := withRetryPolicy(ctx, RetryPolicy{MaxAttempts: 1})
+	for attempt := 1; ; attempt++ {
+		n, err := s3.copyRange(once, input, etag, first, last, w)
+		first += n
+		if err == nil {
+			return nil
+		}
+		if attempt > maxRetries || !retryable(err) {
+			return err
+		}
+		if err := sleepWithContext(ctx, policy.backoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// copyRange makes a single ranged GET request for bytes first to last
+// of the object and copies the body into w. It returns the number of
+// bytes written.
+func (s3 *S3) copyRange(ctx context.Context, input ParallelDownloadInput, etag string, first, last int64, w io.WriterAt) (int64, error) {
+	res, err := s3.GetObjectWithContext(ctx, DownloadInput{
+		Bucket:    input.Bucket,
+		ObjectKey: input.ObjectKey,
+		VersionId: input.VersionId,
+		Range:     fmt.Sprintf("bytes=%d-%d", first, last),
+		IfMatch:   etag,
+
+		SSECustomerKey: input.SSECustomerKey,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("unexpected response to ranged GET: %d", res.StatusCode)
+	}
+	if etag != "" && res.ETag != "" && res.ETag != etag {
+		return 0, fmt.Errorf("object changed during download: ETag %s, expected %s", res.ETag, etag)
+	}
+
+	want := last - first + 1
+	n, err := io.Copy(io.NewOffsetWriter(w, first), io.LimitReader(res.Body, want))
+	if err == nil && n < want {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// ErrObjectChanged is returned (wrapped) by FileDownloadResumable when
+// the object changed since the download started.
+var ErrObjectChanged = errors.New("object changed during download")
+
+// ResumableDownloadInput contains parameters for a resumable download
+type ResumableDownloadInput struct {
+	Bucket     string       // Required: bucket name
+	ObjectKey  string       // Required: object key
+	VersionId  string       // Optional: version ID of the object to download
+	MaxRetries int          // Optional: retries after a failed request, default 3
+	OnProgress ProgressFunc // Optional: progress callback, called as data is written
+
+	// Optional: 32 byte key the object is encrypted with (SSE-C)
+	SSECustomerKey []byte
+}
+
+// FileDownloadResumable downloads an object to the file at path, so that
+// an interrupted download continues where it stopped instead of starting
+// over. The data is written to path+".part", and the ETag of the object
+// to path+".part.etag". When the connection drops, and when it is called
+// again after a failure (or a crash), the download resumes with a ranged
+// GET from the end of the partial file, made with If-Match set to the
+// saved ETag. Once the download is complete, the partial file is renamed
+// to path, or decompressed into it for objects uploaded with Compression.
+//
+// If the object changed since the download started, the partial file is
+// removed and an error wrapping ErrObjectChanged is returned: calling it
+// again starts the download from the beginning.
+func (s3 *S3) FileDownloadResumable(input ResumableDownloadInput, path string) (DetailsResponse, error) {
+	return s3.FileDownloadResumableWithContext(context.Background(), input, path)
+}
+
+// FileDownloadResumableWithContext is like FileDownloadResumable but uses ctx for the requests.
+func (s3 *S3) FileDownloadResumableWithContext(ctx context.Context, input ResumableDownloadInput, path string) (DetailsResponse, error) {
+	if input.Bucket == "" {
+		return DetailsResponse{}, fmt.Errorf("bucket name is required")
+	}
+	if input.ObjectKey == "" {
+		return DetailsResponse{}, fmt.Errorf("object key is required")
+	}
+
+	var (
+		partPath = path + ".part"
+		etagPath = partPath + ".etag"
+	)
+
+	details, err := s3.FileDetailsWithContext(ctx, DetailsInput{
+		Bucket:         input.Bucket,
+		ObjectKey:      input.ObjectKey,
+		VersionId:      input.VersionId,
+		SSECustomerKey: input.SSECustomerKey,
+	})
+	if err != nil {
+		return DetailsResponse{}, err
+	}
+	size := details.Size
+	if size < 0 {
+		return DetailsResponse{}, fmt.Errorf("invalid content length %q", details.ContentLength)
+	}
+
+	// discard removes the partial download of an object that changed.
+	discard := func(err error) (DetailsResponse, error) {
+		os.Remove(partPath)
+		os.Remove(etagPath)
+		return DetailsResponse{}, fmt.Errorf("%w: %w", ErrObjectChanged, err)
+	}
+
+	// Continue the partial download, if it is of the same object.
+	var offset int64
+	saved, err := os.ReadFile(etagPath)
+	switch {
+	case err == nil:
+		if string(saved) != details.Etag {
+			return discard(fmt.Errorf("ETag %s, expected %s", details.Etag, saved))
+		}
+		if fi, err := os.Stat(partPath); err == nil {
+			offset = fi.Size()
+		}
+	case errors.Is(err, os.ErrNotExist):
+		if err := os.WriteFile(etagPath, []byte(details.Etag), 0o644); err != nil {
+			return DetailsResponse{}, err
+		}
+	default:
+		return DetailsResponse{}, err
+	}
+	if offset > size {
+		return discard(fmt.Errorf("partial file is larger than the object"))
+	}
+
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return DetailsResponse{}, err
+	}
+	defer f.Close()
+
+	// Drop anything past the bytes written, and append from there.
+	if err := f.Truncate(offset); err != nil {
+		return DetailsResponse{}, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return DetailsResponse{}, err
+	}
+
+	maxRetries := input.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	policy := s3.getRetryPolicy(ctx)
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	w := &progressWriter{
+		w:          f,
+		written:    offset,
+		resumed:    offset,
+		total:      size,
+		onProgress: input.OnProgress,
+		startTime:  time.Now(),
+	}
+	// Every attempt is a single request, which s3.do must not retry
+	// itself: the next attempt resumes from the last byte written.
+	once := withRetryPolicy(ctx, RetryPolicy{MaxAttempts: 1})
+	for attempt := 1; w.written < size; attempt++ {
+		err := s3.downloadFrom(once, input, details.Etag, w)
+		if IsPreconditionFailed(err) {
+			f.Close()
+			return discard(err)
+		}
+		if err == nil {
+			break
+		}
+		if attempt > maxRetries || !retryable(err) {
+			return DetailsResponse{}, err
+		}
+		if err := sleepWithContext(ctx, policy.backoff(attempt)); err != nil {
+			return DetailsResponse{}, err
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return DetailsResponse{}, err
+	}
+	if w.written != size {
+		return DetailsResponse{}, fmt.Errorf("downloaded %d bytes, expected %d", w.written, size)
+	}
+	if encoding := objectCompression(details.Headers); encoding != "" {
+		if err := decompressTo(encoding, partPath, path); err != nil {
+			return DetailsResponse{}, fmt.Errorf("error decompressing %s/%s: %w", input.Bucket, input.ObjectKey, err)
+		}
+		os.Remove(partPath)
+	} else if err := os.Rename(partPath, path); err != nil {
+		return DetailsResponse{}, err
+	}
+	os.Remove(etagPath)
+
+	return details, nil
+}
+
+// decompressTo decompresses the file at src, compressed with encoding,
+// into the file at path.
+func decompressTo(encoding, src, path string) error {
+	c, err := compressorFor(encoding)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = decompressFile(c, src, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// downloadFrom makes a single GET request for the object of input,
+// which must have the given ETag, from byte w.written to the end,
+// and copies the body into w.
+func (s3 *S3) downloadFrom(ctx context.Context, input ResumableDownloadInput, etag string, w *progressWriter) error {
+	res, err := s3.GetObjectWithContext(ctx, DownloadInput{
+		Bucket:    input.Bucket,
+		ObjectKey: input.ObjectKey,
+		VersionId: input.VersionId,
+		Range:     fmt.Sprintf("bytes=%d-", w.written),
+		IfMatch:   etag,
+
+		SSECustomerKey: input.SSECustomerKey,
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("unexpected response to ranged GET: %d", res.StatusCode)
+	}
+
+	want := w.total - w.written
+	n, err := io.Copy(w, io.LimitReader(res.Body, want))
+	if err == nil && n < want {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// progressWriter counts the bytes written to w, calling onProgress
+// after every write.
+type progressWriter struct {
+	w          io.Writer
+	written    int64
+	resumed    int64 // bytes written before startTime
+	total      int64
+	onProgress ProgressFunc
+	startTime  time.Time
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+
+	if p.onProgress != nil && n > 0 {
+		elapsed := time.Since(p.startTime).Seconds()
+		bytesPerSecond := int64(0)
+		if elapsed > 0 {
+			bytesPerSecond = int64(float64(p.written-p.resumed) / elapsed)
+		}
+
+		p.onProgress(ProgressInfo{
+			TotalBytes:     p.total,
+			UploadedBytes:  p.written,
+			BytesPerSecond: bytesPerSecond,
+		})
+	}
+	return n, err
+}
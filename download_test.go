@@ -0,0 +1,361 @@
+package simples3
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newObjectStub returns a server serving content, with ranges and
+// conditional requests, as the object "bucket/key" with the ETag etag returns.
+// Each GET for a range starting at cutAt has its response cut short
+// the first time.
+func newObjectStub(t *testing.T, content []byte, etag func() string, cutAt int64) *httptest.Server {
+	var (
+		mu  sync.Mutex
+		cut bool
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifySigV4(r, "SuperSecretKey"); err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/bucket/key" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+
+		w.Header().Set("X-Amz-Meta-Owner", "alice")
+		if ct := r.URL.Query().Get("response-content-type"); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		}
+
+		mu.Lock()
+		w.Header().Set("ETag", etag())
+		cutNow := !cut && r.Method == http.MethodGet && strings.HasPrefix(r.Header.Get("Range"), fmt.Sprintf("bytes=%d-", cutAt))
+		if cutNow {
+			cut = true
+		}
+		mu.Unlock()
+
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			return
+		}
+		if cutNow {
+			// Send the headers and half of the range, then drop the connection.
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", cutAt, len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[cutAt : cutAt+1000])
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestDownloadToWriterAt(t *testing.T) {
+	content := make([]byte, 3*MinPartSize+1234)
+	rand.Read(content)
+	var changed atomic.Bool
+	etag := func() string {
+		if changed.Load() {
+			return `"v2"`
+		}
+		return `"v1"`
+	}
+
+	ts := newObjectStub(t, content, etag, MinPartSize)
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+	s3.SetRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	path := filepath.Join(t.TempDir(), "download")
+	var (
+		mu   sync.Mutex
+		last ProgressInfo
+	)
+	details, err := s3.FileDownloadParallel(ParallelDownloadInput{
+		Bucket:      "bucket",
+		ObjectKey:   "key",
+		PartSize:    MinPartSize,
+		Concurrency: 3,
+		OnProgress: func(info ProgressInfo) {
+			mu.Lock()
+			last = info
+			mu.Unlock()
+		},
+	}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Etag != `"v1"` {
+		t.Errorf("unexpected details: %+v", details)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("downloaded %d bytes, want %d", len(got), len(content))
+	}
+	if last.UploadedBytes != int64(len(content)) || last.TotalBytes != int64(len(content)) || last.TotalParts != 4 {
+		t.Errorf("unexpected progress: %+v", last)
+	}
+
+	t.Run("Changed", func(t *testing.T) {
+		s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+		s3.SetEndpoint(ts.URL)
+
+		// The object changes after the HEAD request.
+		_, err := s3.DownloadToWriterAt(ParallelDownloadInput{
+			Bucket:     "bucket",
+			ObjectKey:  "key",
+			PartSize:   MinPartSize,
+			OnProgress: func(ProgressInfo) { changed.Store(true) },
+		}, &writerAt{})
+		if !IsPreconditionFailed(err) {
+			t.Errorf("expected a precondition failure, got %v", err)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing")
+		_, err := s3.FileDownloadParallel(ParallelDownloadInput{Bucket: "bucket", ObjectKey: "missing"}, path)
+		if !IsNotFound(err) {
+			t.Errorf("expected not found, got %v", err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected the file to be removed, got %v", err)
+		}
+	})
+}
+
+func TestGetObject(t *testing.T) {
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	ts := newObjectStub(t, content, func() string { return `"v1"` }, -1)
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	tests := []struct {
+		name   string
+		input  DownloadInput
+		status int
+		body   string
+		check  func(t *testing.T, res DownloadResponse)
+	}{
+		{
+			name:   "Full",
+			input:  DownloadInput{ResponseContentType: "text/plain"},
+			status: http.StatusOK,
+			body:   string(content),
+			check: func(t *testing.T, res DownloadResponse) {
+				if res.ContentType != "text/plain" || res.ETag != `"v1"` || res.AmzMeta["X-Amz-Meta-Owner"] != "alice" {
+					t.Errorf("unexpected response: %+v", res)
+				}
+			},
+		},
+		{
+			name:   "Range",
+			input:  DownloadInput{Range: "bytes=10-19", IfMatch: `"v1"`},
+			status: http.StatusPartialContent,
+			body:   "abcdefghij",
+			check: func(t *testing.T, res DownloadResponse) {
+				if res.ContentRange != fmt.Sprintf("bytes 10-19/%d", len(content)) || res.ContentLength != 10 {
+					t.Errorf("unexpected range: %q, %d bytes", res.ContentRange, res.ContentLength)
+				}
+			},
+		},
+		{
+			name:   "NotModified",
+			input:  DownloadInput{IfNoneMatch: `"v1"`},
+			status: http.StatusNotModified,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.Bucket, tt.input.ObjectKey = "bucket", "key"
+			res, err := s3.GetObject(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.status || string(body) != tt.body {
+				t.Errorf("got %d %q, want %d %q", res.StatusCode, body, tt.status, tt.body)
+			}
+			if tt.check != nil {
+				tt.check(t, res)
+			}
+		})
+	}
+
+	_, err := s3.GetObject(DownloadInput{Bucket: "bucket", ObjectKey: "key", IfMatch: `"v2"`})
+	if !IsPreconditionFailed(err) {
+		t.Errorf("expected a precondition failure, got %v", err)
+	}
+}
+
+func TestFileDownloadResumable(t *testing.T) {
+	content := make([]byte, 100000)
+	rand.Read(content)
+
+	var changed atomic.Bool
+	etag := func() string {
+		if changed.Load() {
+			return `"v2"`
+		}
+		return `"v1"`
+	}
+
+	// The first response is cut short, and the download resumes.
+	ts := newObjectStub(t, content, etag, 0)
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+	s3.SetRetryPolicy(RetryPolicy{MaxAttempts: 1, BaseDelay: time.Millisecond})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	input := ResumableDownloadInput{Bucket: "bucket", ObjectKey: "key"}
+
+	if _, err := s3.FileDownloadResumable(input, path); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, content) {
+		t.Errorf("downloaded %d bytes, want %d", len(got), len(content))
+	}
+	if _, err := os.Stat(path + ".part.etag"); !os.IsNotExist(err) {
+		t.Errorf("expected the ETag file to be removed, got %v", err)
+	}
+
+	// A partial download left by an earlier run is continued.
+	path = filepath.Join(dir, "resumed")
+	os.WriteFile(path+".part", content[:60000], 0o644)
+	os.WriteFile(path+".part.etag", []byte(`"v1"`), 0o644)
+
+	var first ProgressInfo
+	input.OnProgress = func(info ProgressInfo) {
+		if first.UploadedBytes == 0 {
+			first = info
+		}
+	}
+	if _, err := s3.FileDownloadResumable(input, path); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, content) {
+		t.Errorf("downloaded %d bytes, want %d", len(got), len(content))
+	}
+	if first.UploadedBytes <= 60000 || first.TotalBytes != int64(len(content)) {
+		t.Errorf("expected the download to resume after 60000 bytes, got %+v", first)
+	}
+	input.OnProgress = nil
+
+	// A partial download of an object that changed is discarded.
+	path = filepath.Join(dir, "changed")
+	os.WriteFile(path+".part", content[:60000], 0o644)
+	os.WriteFile(path+".part.etag", []byte(`"v1"`), 0o644)
+	changed.Store(true)
+
+	_, err := s3.FileDownloadResumable(input, path)
+	if !errors.Is(err, ErrObjectChanged) {
+		t.Fatalf("expected the object to have changed, got %v", err)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("expected the partial file to be removed, got %v", err)
+	}
+
+	// The next attempt starts over.
+	if _, err := s3.FileDownloadResumable(input, path); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, content) {
+		t.Errorf("downloaded %d bytes, want %d", len(got), len(content))
+	}
+}
+
+// writerAt is an in-memory io.WriterAt.
+type writerAt struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *writerAt) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if end := int(off) + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+	return copy(w.buf[off:], p), nil
+}
+
+// newUnavailableStub returns a server for an object of size bytes whose
+// GET requests all fail with 503, counting them in gets.
+func newUnavailableStub(t *testing.T, size int, gets *atomic.Int32) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", fmt.Sprint(size))
+			w.Header().Set("ETag", `"v1"`)
+			return
+		}
+		gets.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>`)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestDownloadToWriterAt_Retries(t *testing.T) {
+	var gets atomic.Int32
+	ts := newUnavailableStub(t, 1000, &gets)
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+	s3.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+
+	_, err := s3.DownloadToWriterAt(ParallelDownloadInput{Bucket: "bucket", ObjectKey: "key", MaxRetries: 2}, &writerAt{})
+	if err == nil {
+		t.Fatal("expected the download to fail")
+	}
+	// The retries of the range aren't multiplied by those of the client.
+	if n := gets.Load(); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+}
+
+func TestFileDownloadResumable_Retries(t *testing.T) {
+	var gets atomic.Int32
+	ts := newUnavailableStub(t, 1000, &gets)
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+	s3.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+
+	path := filepath.Join(t.TempDir(), "file")
+	_, err := s3.FileDownloadResumable(ResumableDownloadInput{Bucket: "bucket", ObjectKey: "key", MaxRetries: 2}, path)
+	if err == nil {
+		t.Fatal("expected the download to fail")
+	}
+	if n := gets.Load(); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+}
@@ -0,0 +1,562 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+)
+
+// Client-side encryption format. The object is encrypted using
+// AES-256-GCM in segments of envelopeSegmentSize bytes, each sealed
+// with its own nonce: a random prefix, the index of the segment, and a
+// flag set on the last segment, so that segments can't be reordered,
+// dropped or truncated without decryption failing.
+const (
+	envelopeAlgorithm   = "AES256-GCM-STREAM"
+	envelopeSegmentSize = 64 * 1024
+	envelopeTagSize     = 16
+	envelopeNoncePrefix = 7
+
+	// Metadata keys holding the envelope of an object.
+	envelopeMetaAlgorithm = "cse-alg"
+	envelopeMetaKey       = "cse-key"
+	envelopeMetaKeyID     = "cse-key-id"
+	envelopeMetaIV        = "cse-iv"
+)
+
+// KeyWrapper wraps (encrypts) the data keys objects are encrypted with,
+// e.g. using a KMS. The wrapped key is stored along with the object.
+type KeyWrapper interface {
+	// WrapKey encrypts dataKey, and returns it along with the ID of
+	// the key used, which is passed to UnwrapKey.
+	WrapKey(ctx context.Context, dataKey []byte) (wrapped []byte, keyID string, err error)
+	// UnwrapKey decrypts a data key wrapped by WrapKey.
+	UnwrapKey(ctx context.Context, wrapped []byte, keyID string) ([]byte, error)
+}
+
+// KeyRing is a KeyWrapper using local 32 byte keys and AES-256-GCM.
+// New data keys are wrapped with the current key, and keys that were
+// current before can be kept to unwrap the keys of older objects.
+type KeyRing struct {
+	CurrentKeyID string
+	Keys         map[string][]byte
+}
+
+// WrapKey encrypts dataKey using the current key of the ring.
+func (k KeyRing) WrapKey(ctx context.Context, dataKey []byte) ([]byte, string, error) {
+	aead, err := k.aead(k.CurrentKeyID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(k.CurrentKeyID)), k.CurrentKeyID, nil
+}
+
+// UnwrapKey decrypts a data key wrapped using the key keyID of the ring.
+func (k KeyRing) UnwrapKey(ctx context.Context, wrapped []byte, keyID string) ([]byte, error) {
+	aead, err := k.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key with key %q: %w", keyID, err)
+	}
+	return dataKey, nil
+}
+
+func (k KeyRing) aead(keyID string) (cipher.AEAD, error) {
+	key, ok := k.Keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in key ring", keyID)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key %q must be 32 bytes, got %d", keyID, len(key))
+	}
+	return newGCM(key)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptionClient uploads and downloads objects encrypted client-side,
+// so that their content never leaves the process unencrypted. Each
+// object is encrypted using AES-256-GCM with its own data key, which is
+// wrapped by a KeyWrapper and stored in the object's metadata along with
+// the other parameters of the encryption (the envelope).
+//
+// Objects copied with the COPY metadata directive keep their envelope,
+// and can still be decrypted.
+type EncryptionClient struct {
+	s3      *S3
+	wrapper KeyWrapper
+}
+
+// NewEncryptionClient returns a client encrypting objects uploaded with
+// s3, with data keys wrapped by wrapper.
+func NewEncryptionClient(s3 *S3, wrapper KeyWrapper) *EncryptionClient {
+	return &EncryptionClient{s3: s3, wrapper: wrapper}
+}
+
+// envelope is the encryption material of an object.
+type envelope struct {
+	aead   cipher.AEAD
+	prefix []byte
+}
+
+// newEnvelope generates a data key and returns the envelope using it,
+// along with the metadata to store it in.
+func (c *EncryptionClient) newEnvelope(ctx context.Context) (*envelope, map[string]string, error) {
+	dataKey := make([]byte, 32)
+	prefix := make([]byte, envelopeNoncePrefix)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, err
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, nil, err
+	}
+
+	wrapped, keyID, err := c.wrapper.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error wrapping data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta := map[string]string{
+		envelopeMetaAlgorithm: envelopeAlgorithm,
+		envelopeMetaKey:       base64.StdEncoding.EncodeToString(wrapped),
+		envelopeMetaKeyID:     keyID,
+		envelopeMetaIV:        base64.StdEncoding.EncodeToString(prefix),
+	}
+	return &envelope{aead: aead, prefix: prefix}, meta, nil
+}
+
+// openEnvelope returns the envelope stored in the headers of an object.
+func (c *EncryptionClient) openEnvelope(ctx context.Context, h http.Header) (*envelope, error) {
+	algorithm := h.Get(AMZMetaPrefix + envelopeMetaAlgorithm)
+	if algorithm == "" {
+		return nil, fmt.Errorf("object is not encrypted client-side")
+	}
+	if algorithm != envelopeAlgorithm {
+		return nil, fmt.Errorf("unsupported client-side encryption algorithm %q", algorithm)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(h.Get(AMZMetaPrefix + envelopeMetaKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped data key: %w", err)
+	}
+	prefix, err := base64.StdEncoding.DecodeString(h.Get(AMZMetaPrefix + envelopeMetaIV))
+	if err != nil || len(prefix) != envelopeNoncePrefix {
+		return nil, fmt.Errorf("invalid IV %q", h.Get(AMZMetaPrefix+envelopeMetaIV))
+	}
+
+	dataKey, err := c.wrapper.UnwrapKey(ctx, wrapped, h.Get(AMZMetaPrefix+envelopeMetaKeyID))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope{aead: aead, prefix: prefix}, nil
+}
+
+// nonce returns the nonce of segment index, which is the last one if
+// last is set.
+func (e *envelope) nonce(index int64, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, e.prefix)
+	binary.BigEndian.PutUint32(nonce[envelopeNoncePrefix:], uint32(index))
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptedSize returns the size of size bytes once encrypted. Empty
+// objects are encrypted as a single empty segment.
+func encryptedSize(size int64) int64 {
+	segments := max((size+envelopeSegmentSize-1)/envelopeSegmentSize, 1)
+	return size + segments*envelopeTagSize
+}
+
+// decryptedSize returns the size of size bytes once decrypted, or -1 if
+// it isn't known.
+func decryptedSize(size int64) int64 {
+	if size < 0 {
+		return -1
+	}
+	segments := max((size+envelopeSegmentSize+envelopeTagSize-1)/(envelopeSegmentSize+envelopeTagSize), 1)
+	return size - segments*envelopeTagSize
+}
+
+// readSegment reads the next segment of len(buf) bytes from r, and reports
+// whether it is the last one: a segment is the last if it is short, or
+// if r has nothing left after it.
+func readSegment(r *bufio.Reader, buf []byte) (int, bool, error) {
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return n, true, nil
+	}
+	if err != nil {
+		return n, false, err
+	}
+	if _, err := r.Peek(1); err == io.EOF {
+		return n, true, nil
+	} else if err != nil {
+		return n, false, err
+	}
+	return n, false, nil
+}
+
+// encryptReader encrypts the data read from a stream.
+type encryptReader struct {
+	env   *envelope
+	src   *bufio.Reader
+	plain []byte
+	out   []byte
+	index int64
+	done  bool
+}
+
+func newEncryptReader(env *envelope, src io.Reader) *encryptReader {
+	return &encryptReader{
+		env:   env,
+		src:   bufio.NewReaderSize(src, envelopeSegmentSize),
+		plain: make([]byte, envelopeSegmentSize),
+	}
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		n, last, err := readSegment(r.src, r.plain)
+		if err != nil {
+			return 0, err
+		}
+		r.out = r.env.aead.Seal(r.out[:0], r.env.nonce(r.index, last), r.plain[:n], nil)
+		r.index++
+		r.done = last
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// encryptSeeker encrypts the size bytes of src, from its current
+// offset. Segments being encrypted deterministically, it can seek by
+// encrypting the segment it seeks to again, so that requests can be
+// signed and retried.
+type encryptSeeker struct {
+	env   *envelope
+	src   io.ReadSeeker
+	start int64
+	size  int64
+
+	pos   int64
+	seg   []byte
+	index int64
+	plain []byte
+}
+
+func newEncryptSeeker(env *envelope, src io.ReadSeeker, size int64) (*encryptSeeker, error) {
+	start, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptSeeker{
+		env:   env,
+		src:   src,
+		start: start,
+		size:  size,
+		index: -1,
+		plain: make([]byte, envelopeSegmentSize),
+	}, nil
+}
+
+func (r *encryptSeeker) Read(p []byte) (int, error) {
+	total := encryptedSize(r.size)
+	if r.pos >= total {
+		return 0, io.EOF
+	}
+
+	const sealedSize = envelopeSegmentSize + envelopeTagSize
+	index := r.pos / sealedSize
+	if index != r.index {
+		first := index * envelopeSegmentSize
+		n := min(r.size-first, envelopeSegmentSize)
+		if _, err := r.src.Seek(r.start+first, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := io.ReadFull(r.src, r.plain[:n]); err != nil {
+			return 0, err
+		}
+		last := first+n >= r.size
+		r.seg = r.env.aead.Seal(r.seg[:0], r.env.nonce(index, last), r.plain[:n], nil)
+		r.index = index
+	}
+
+	n := copy(p, r.seg[r.pos-index*sealedSize:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *encryptSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += encryptedSize(r.size)
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+// decryptReader decrypts the data read from an encrypted body.
+type decryptReader struct {
+	env    *envelope
+	body   io.ReadCloser
+	src    *bufio.Reader
+	sealed []byte
+	out    []byte
+	index  int64
+	done   bool
+}
+
+func newDecryptReader(env *envelope, body io.ReadCloser) *decryptReader {
+	return &decryptReader{
+		env:    env,
+		body:   body,
+		src:    bufio.NewReaderSize(body, envelopeSegmentSize+envelopeTagSize),
+		sealed: make([]byte, envelopeSegmentSize+envelopeTagSize),
+	}
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		n, last, err := readSegment(r.src, r.sealed)
+		if err != nil {
+			return 0, err
+		}
+		r.out, err = r.env.aead.Open(r.out[:0], r.env.nonce(r.index, last), r.sealed[:n], nil)
+		if err != nil {
+			return 0, fmt.Errorf("error decrypting segment %d: %w", r.index, err)
+		}
+		r.index++
+		r.done = last
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.body.Close()
+}
+
+// withEnvelope returns a copy of metadata holding the envelope.
+func withEnvelope(metadata, envelope map[string]string) map[string]string {
+	out := maps.Clone(metadata)
+	if out == nil {
+		out = map[string]string{}
+	}
+	maps.Copy(out, envelope)
+	return out
+}
+
+// FilePut encrypts the body of u and uploads it like S3.FilePut.
+// Bodies and readers that can seek, or read at an offset, are encrypted
+// again when the request is retried; other readers are encrypted as
+// they are streamed, and need ContentLength.
+func (c *EncryptionClient) FilePut(u UploadInput) (PutResponse, error) {
+	return c.FilePutWithContext(context.Background(), u)
+}
+
+// FilePutWithContext is like FilePut but uses ctx for the request.
+func (c *EncryptionClient) FilePutWithContext(ctx context.Context, u UploadInput) (PutResponse, error) {
+	env, meta, err := c.newEnvelope(ctx)
+	if err != nil {
+		return PutResponse{}, err
+	}
+
+	switch {
+	case u.Body != nil:
+		size := bodySize(u.Body)
+		if size < 0 {
+			return PutResponse{}, fmt.Errorf("error detecting the size of the body")
+		}
+		body, err := newEncryptSeeker(env, u.Body, size)
+		if err != nil {
+			return PutResponse{}, err
+		}
+		u.Body = body
+	case u.Reader != nil:
+		var src io.ReadSeeker
+		size := int64(-1)
+		switch r := u.Reader.(type) {
+		case io.ReadSeeker:
+			src, size = r, bodySize(r)
+		case io.ReaderAt:
+			n, err := readerAtSize(r, u.ContentLength)
+			if err != nil {
+				return PutResponse{}, err
+			}
+			src, size = io.NewSectionReader(r, 0, n), n
+		}
+		if src == nil {
+			if err := checkReaderLength(u.Reader, u.ContentLength); err != nil {
+				return PutResponse{}, err
+			}
+			u.Reader = newEncryptReader(env, u.Reader)
+			u.ContentLength = encryptedSize(u.ContentLength)
+			break
+		}
+		if size < 0 {
+			return PutResponse{}, fmt.Errorf("error detecting the size of the body")
+		}
+		body, err := newEncryptSeeker(env, src, size)
+		if err != nil {
+			return PutResponse{}, err
+		}
+		u.Body, u.Reader, u.ContentLength = body, nil, 0
+	default:
+		return PutResponse{}, fmt.Errorf("body is required")
+	}
+
+	u.CustomMetadata = withEnvelope(u.CustomMetadata, meta)
+	return c.s3.FilePutWithContext(ctx, u)
+}
+
+// FileUploadMultipart encrypts the body of input and uploads it like
+// S3.FileUploadMultipart. Resumable uploads (Checkpoint) aren't
+// supported, as the parts of an upload must all be encrypted with the
+// same data key.
+func (c *EncryptionClient) FileUploadMultipart(input MultipartUploadInput) (MultipartUploadOutput, error) {
+	return c.FileUploadMultipartWithContext(context.Background(), input)
+}
+
+// FileUploadMultipartWithContext is like FileUploadMultipart but uses ctx for the requests.
+func (c *EncryptionClient) FileUploadMultipartWithContext(ctx context.Context, input MultipartUploadInput) (MultipartUploadOutput, error) {
+	if input.Checkpoint != nil {
+		return MultipartUploadOutput{}, fmt.Errorf("resumable uploads are not supported with client-side encryption")
+	}
+	if input.Body == nil {
+		return MultipartUploadOutput{}, fmt.Errorf("body is required")
+	}
+
+	env, meta, err := c.newEnvelope(ctx)
+	if err != nil {
+		return MultipartUploadOutput{}, err
+	}
+
+	size := input.Size
+	if size <= 0 {
+		size = bodySize(input.Body)
+	}
+	if seeker, ok := input.Body.(io.ReadSeeker); ok && size >= 0 {
+		body, err := newEncryptSeeker(env, seeker, size)
+		if err != nil {
+			return MultipartUploadOutput{}, err
+		}
+		input.Body = body
+	} else {
+		input.Body = newEncryptReader(env, input.Body)
+	}
+	if size >= 0 {
+		input.Size = encryptedSize(size)
+	} else {
+		input.Size = 0
+	}
+
+	input.CustomMetadata = withEnvelope(input.CustomMetadata, meta)
+	return c.s3.FileUploadMultipartWithContext(ctx, input)
+}
+
+// GetObject downloads an object like S3.GetObject, and decrypts its
+// body as it is read. ContentLength is the size of the decrypted body.
+// Reading the body fails if the object was tampered with. Ranges and
+// parts can't be downloaded.
+func (c *EncryptionClient) GetObject(u DownloadInput) (DownloadResponse, error) {
+	return c.GetObjectWithContext(context.Background(), u)
+}
+
+// GetObjectWithContext is like GetObject but uses ctx for the request.
+func (c *EncryptionClient) GetObjectWithContext(ctx context.Context, u DownloadInput) (DownloadResponse, error) {
+	if u.Range != "" || u.PartNumber > 0 {
+		return DownloadResponse{}, fmt.Errorf("ranges and parts can't be downloaded with client-side encryption")
+	}
+
+	res, err := c.s3.GetObjectWithContext(ctx, u)
+	if err != nil {
+		return DownloadResponse{}, err
+	}
+	if res.StatusCode == http.StatusNotModified {
+		return res, nil
+	}
+
+	env, err := c.openEnvelope(ctx, res.Headers)
+	if err != nil {
+		res.Body.Close()
+		return DownloadResponse{}, fmt.Errorf("error decrypting %s/%s: %w", u.Bucket, u.ObjectKey, err)
+	}
+	res.Body = newDecryptReader(env, res.Body)
+	res.ContentLength = decryptedSize(res.ContentLength)
+	return res, nil
+}
+
+// FileDownload downloads and decrypts an object, like GetObject, and
+// returns its body.
+func (c *EncryptionClient) FileDownload(u DownloadInput) (io.ReadCloser, error) {
+	return c.FileDownloadWithContext(context.Background(), u)
+}
+
+// FileDownloadWithContext is like FileDownload but uses ctx for the request.
+func (c *EncryptionClient) FileDownloadWithContext(ctx context.Context, u DownloadInput) (io.ReadCloser, error) {
+	res, err := c.GetObjectWithContext(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
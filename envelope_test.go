@@ -0,0 +1,356 @@
+package simples3
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// objectStore is a fake S3 server keeping the objects put, along with
+// their metadata and Content-Encoding, and serving them with ranges.
+type objectStore struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	headers map[string]http.Header
+}
+
+func newObjectStore(t *testing.T) *objectStore {
+	s := &objectStore{objects: map[string][]byte{}, headers: map[string]http.Header{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := verifySigV4(r, "SuperSecretKey")
+		if err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			h := http.Header{}
+			for k, v := range r.Header {
+				if strings.HasPrefix(strings.ToLower(k), AMZMetaPrefix) {
+					h[k] = v
+				}
+			}
+			// Like S3, keep the encoding of the content, not the one of
+			// an aws-chunked request.
+			encoding := strings.TrimPrefix(strings.TrimPrefix(r.Header.Get("Content-Encoding"), "aws-chunked"), ",")
+			if encoding != "" {
+				h.Set("Content-Encoding", encoding)
+			}
+			s.put(r.URL.Path, body, h)
+			w.Header().Set("ETag", `"etag"`)
+		case http.MethodGet, http.MethodHead:
+			content, ok := s.objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+				return
+			}
+			h := s.headers[r.URL.Path]
+			for k, v := range h {
+				if k != "Content-Encoding" {
+					w.Header()[k] = v
+				}
+			}
+			w.Header().Set("ETag", `"etag"`)
+			// ServeContent doesn't set Content-Length for encoded
+			// content, so the encoding is only set with the status.
+			http.ServeContent(&encodingWriter{w, h.Get("Content-Encoding")}, r, "", time.Time{}, bytes.NewReader(content))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+type encodingWriter struct {
+	http.ResponseWriter
+	encoding string
+}
+
+func (w *encodingWriter) WriteHeader(code int) {
+	if w.encoding != "" {
+		w.Header().Set("Content-Encoding", w.encoding)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (s *objectStore) put(path string, content []byte, h http.Header) {
+	s.objects[path] = content
+	s.headers[path] = h
+}
+
+func newTestKeyRing() KeyRing {
+	return KeyRing{
+		CurrentKeyID: "key-1",
+		Keys:         map[string][]byte{"key-1": bytes.Repeat([]byte{1}, 32)},
+	}
+}
+
+func TestEncryptionClient(t *testing.T) {
+	store := newObjectStore(t)
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(store.URL)
+	client := NewEncryptionClient(s3, newTestKeyRing())
+
+	for _, size := range []int{0, 10, envelopeSegmentSize, 3*envelopeSegmentSize + 123} {
+		content := make([]byte, size)
+		rand.Read(content)
+
+		tests := []struct {
+			name  string
+			input UploadInput
+		}{
+			{"Body", UploadInput{Body: bytes.NewReader(content)}},
+			{"Reader", UploadInput{Reader: iotest.HalfReader(bytes.NewReader(content)), ContentLength: int64(size)}},
+			{"ReadSeeker", UploadInput{Reader: bytes.NewReader(content)}},
+			{"ReaderAt", UploadInput{Reader: sizedReaderAt{readerAt{nil, bytes.NewReader(content)}, int64(size)}}},
+		}
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s/%d", tt.name, size), func(t *testing.T) {
+				tt.input.Bucket, tt.input.ObjectKey = "bucket", "key"
+				tt.input.CustomMetadata = map[string]string{"owner": "alice"}
+				if _, err := client.FilePut(tt.input); err != nil {
+					t.Fatal(err)
+				}
+
+				stored := store.objects["/bucket/key"]
+				if int64(len(stored)) != encryptedSize(int64(size)) {
+					t.Errorf("stored %d bytes, want %d", len(stored), encryptedSize(int64(size)))
+				}
+				if size > 0 && bytes.Contains(stored, content) {
+					t.Errorf("object stored unencrypted")
+				}
+
+				res, err := client.GetObject(DownloadInput{Bucket: "bucket", ObjectKey: "key"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer res.Body.Close()
+				got, err := io.ReadAll(res.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, content) {
+					t.Errorf("decrypted %d bytes, which don't match the %d uploaded", len(got), len(content))
+				}
+				if res.ContentLength != int64(size) {
+					t.Errorf("ContentLength = %d, want %d", res.ContentLength, size)
+				}
+				if res.Headers.Get(AMZMetaPrefix+"owner") != "alice" {
+					t.Errorf("custom metadata lost: %v", res.Headers)
+				}
+			})
+		}
+	}
+
+	t.Run("ReaderWithoutLength", func(t *testing.T) {
+		_, err := client.FilePut(UploadInput{
+			Bucket:    "bucket",
+			ObjectKey: "key",
+			Reader:    iotest.HalfReader(bytes.NewReader(make([]byte, 100<<10))),
+		})
+		if err == nil || !strings.Contains(err.Error(), "content length is required") {
+			t.Errorf("expected a reader without length to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("NotEncrypted", func(t *testing.T) {
+		store.put("/bucket/plain", []byte("content"), http.Header{})
+		if _, err := client.GetObject(DownloadInput{Bucket: "bucket", ObjectKey: "plain"}); err == nil {
+			t.Errorf("expected an object without envelope to be rejected")
+		}
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		if _, err := client.GetObject(DownloadInput{Bucket: "bucket", ObjectKey: "key", Range: "bytes=0-9"}); err == nil {
+			t.Errorf("expected ranges to be rejected")
+		}
+	})
+}
+
+func TestEncryptionClient_Tampered(t *testing.T) {
+	store := newObjectStore(t)
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(store.URL)
+	client := NewEncryptionClient(s3, newTestKeyRing())
+
+	content := make([]byte, 2*envelopeSegmentSize+10)
+	rand.Read(content)
+	if _, err := client.FilePut(UploadInput{Bucket: "bucket", ObjectKey: "key", Body: bytes.NewReader(content)}); err != nil {
+		t.Fatal(err)
+	}
+	stored := store.objects["/bucket/key"]
+	const sealedSize = envelopeSegmentSize + envelopeTagSize
+
+	tests := []struct {
+		name   string
+		tamper func([]byte) []byte
+	}{
+		{"Modified", func(b []byte) []byte {
+			b = bytes.Clone(b)
+			b[100] ^= 1
+			return b
+		}},
+		{"Truncated", func(b []byte) []byte { return b[:2*sealedSize] }},
+		{"Reordered", func(b []byte) []byte {
+			return append(append(bytes.Clone(b[sealedSize:2*sealedSize]), b[:sealedSize]...), b[2*sealedSize:]...)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.put("/bucket/key", tt.tamper(stored), store.headers["/bucket/key"])
+
+			res, err := client.GetObject(DownloadInput{Bucket: "bucket", ObjectKey: "key"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if _, err := io.ReadAll(res.Body); err == nil {
+				t.Errorf("expected reading a tampered object to fail")
+			}
+		})
+	}
+}
+
+func TestEncryptionClient_Multipart(t *testing.T) {
+	content := make([]byte, 2*MinPartSize+1234)
+	rand.Read(content)
+
+	tests := []struct {
+		name string
+		body io.Reader
+	}{
+		{"Seeker", bytes.NewReader(content)},
+		{"Stream", iotest.HalfReader(bytes.NewReader(content))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newMultipartStub(t)
+			s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+			s3.SetEndpoint(stub.URL)
+			client := NewEncryptionClient(s3, newTestKeyRing())
+
+			_, err := client.FileUploadMultipart(MultipartUploadInput{
+				Bucket:      "bucket",
+				ObjectKey:   "key",
+				Body:        tt.body,
+				Concurrency: 2,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stub.completed) != int(encryptedSize(int64(len(content)))) {
+				t.Fatalf("uploaded %d bytes, want %d", len(stub.completed), encryptedSize(int64(len(content))))
+			}
+
+			// Serve the completed upload, with the metadata it was
+			// initiated with.
+			store := newObjectStore(t)
+			store.put("/bucket/key", stub.completed, stub.initiate.Header)
+			s3.SetEndpoint(store.URL)
+
+			body, err := client.FileDownload(DownloadInput{Bucket: "bucket", ObjectKey: "key"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer body.Close()
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("decrypted content doesn't match the uploaded one")
+			}
+		})
+	}
+
+	_, err := NewEncryptionClient(New("us-east-1", "AccessKey", "SuperSecretKey"), newTestKeyRing()).FileUploadMultipart(MultipartUploadInput{
+		Bucket:     "bucket",
+		ObjectKey:  "key",
+		Body:       bytes.NewReader(content),
+		Checkpoint: FileCheckpointStore{Path: t.TempDir() + "/checkpoint.json"},
+	})
+	if err == nil {
+		t.Errorf("expected resumable uploads to be rejected")
+	}
+}
+
+func TestEncryptSeeker(t *testing.T) {
+	env := &envelope{prefix: make([]byte, envelopeNoncePrefix)}
+	env.aead, _ = newGCM(bytes.Repeat([]byte{2}, 32))
+
+	content := make([]byte, 2*envelopeSegmentSize+10)
+	rand.Read(content)
+	want, err := io.ReadAll(newEncryptReader(env, bytes.NewReader(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := newEncryptSeeker(env, bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end, _ := r.Seek(0, io.SeekEnd); end != int64(len(want)) {
+		t.Errorf("size %d, want %d", end, len(want))
+	}
+	for _, offset := range []int64{envelopeSegmentSize + 5, 0, int64(len(want)) - 3} {
+		r.Seek(offset, io.SeekStart)
+		got, err := io.ReadAll(iotest.OneByteReader(r))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want[offset:]) {
+			t.Errorf("read from %d doesn't match the stream", offset)
+		}
+	}
+}
+
+func TestKeyRing(t *testing.T) {
+	ctx := context.Background()
+	ring := newTestKeyRing()
+	dataKey := bytes.Repeat([]byte{3}, 32)
+
+	wrapped, keyID, err := ring.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID != "key-1" {
+		t.Errorf("keyID = %q, want key-1", keyID)
+	}
+
+	// Rotate the current key, keeping the old one to unwrap keys.
+	ring.Keys["key-2"] = bytes.Repeat([]byte{2}, 32)
+	ring.CurrentKeyID = "key-2"
+	got, err := ring.UnwrapKey(ctx, wrapped, keyID)
+	if err != nil || !bytes.Equal(got, dataKey) {
+		t.Errorf("UnwrapKey = %x, %v", got, err)
+	}
+	if _, keyID, _ := ring.WrapKey(ctx, dataKey); keyID != "key-2" {
+		t.Errorf("keyID = %q after rotation, want key-2", keyID)
+	}
+
+	if _, err := ring.UnwrapKey(ctx, wrapped, "key-2"); err == nil {
+		t.Errorf("expected unwrapping with another key to fail")
+	}
+	delete(ring.Keys, "key-1")
+	if _, err := ring.UnwrapKey(ctx, wrapped, "key-1"); err == nil {
+		t.Errorf("expected unwrapping with a missing key to fail")
+	}
+}
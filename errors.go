@@ -0,0 +1,215 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// S3Error represents an S3 API error response.
+//
+// Every operation returns a *S3Error when S3 responds with an
+// unexpected status code, so callers can inspect it using errors.As
+// or one of the IsNotFound, IsAccessDenied, IsPreconditionFailed helpers.
+type S3Error struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	RequestID string   `xml:"RequestId"`
+	HostID    string   `xml:"HostId"`
+
+	// StatusCode and Status are taken from the HTTP response.
+	StatusCode int    `xml:"-"`
+	Status     string `xml:"-"`
+
+	// Operation is the name of the simples3 method that failed
+	// (e.g. "FilePut"), Bucket and Key identify the resource it
+	// was called for, if any.
+	Operation string `xml:"-"`
+	Bucket    string `xml:"-"`
+	Key       string `xml:"-"`
+
+	// DeleteMarker is true if the object, or the requested version,
+	// is a delete marker, whose version is then in VersionId.
+	DeleteMarker bool   `xml:"-"`
+	VersionId    string `xml:"-"`
+}
+
+// Error returns a string representation of the S3Error
+func (e S3Error) Error() string {
+	var b strings.Builder
+	b.WriteString("S3 Error: ")
+	b.WriteString(e.Code)
+	if e.Message != "" {
+		b.WriteString(" - ")
+		b.WriteString(e.Message)
+	}
+
+	var details []string
+	if e.Operation != "" {
+		resource := e.Bucket
+		if e.Key != "" {
+			resource += "/" + e.Key
+		}
+		details = append(details, strings.TrimSpace(e.Operation+" "+resource))
+	}
+	if e.Status != "" {
+		details = append(details, "status code: "+e.Status)
+	}
+	if e.RequestID != "" {
+		details = append(details, "request id: "+e.RequestID)
+	}
+	if len(details) > 0 {
+		b.WriteString(" (" + strings.Join(details, ", ") + ")")
+	}
+
+	return b.String()
+}
+
+// newResponseError builds a *S3Error from an unexpected HTTP response.
+// body is the (possibly empty) response body, which is parsed as an
+// S3 error document when possible. Responses without a body, such as
+// those to HEAD requests, get a Code derived from the status code.
+func newResponseError(op, bucket, key string, res *http.Response, body []byte) error {
+	e := &S3Error{
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Operation:  op,
+		Bucket:     bucket,
+		Key:        key,
+	}
+
+	if len(body) > 0 {
+		if err := xml.Unmarshal(body, e); err != nil || e.Code == "" {
+			e.Message = strings.TrimSpace(string(body))
+		}
+	}
+
+	if e.Code == "" {
+		e.Code = statusErrorCode(res.StatusCode)
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(res.StatusCode)
+	}
+	if e.RequestID == "" {
+		e.RequestID = res.Header.Get("x-amz-request-id")
+	}
+	if e.HostID == "" {
+		e.HostID = res.Header.Get("x-amz-id-2")
+	}
+	if res.Header.Get("x-amz-delete-marker") == "true" {
+		e.DeleteMarker = true
+		e.VersionId = res.Header.Get("x-amz-version-id")
+	}
+
+	return e
+}
+
+// statusErrorCode returns the error code S3 uses for a status code
+// when the response does not carry an error document.
+func statusErrorCode(status int) string {
+	switch status {
+	case http.StatusNotModified:
+		return "NotModified"
+	case http.StatusBadRequest:
+		return "BadRequest"
+	case http.StatusForbidden:
+		return "Forbidden"
+	case http.StatusNotFound:
+		return "NotFound"
+	case http.StatusPreconditionFailed:
+		return "PreconditionFailed"
+	default:
+		return strings.ReplaceAll(http.StatusText(status), " ", "")
+	}
+}
+
+// asS3Error returns the *S3Error in err's chain, if any.
+func asS3Error(err error) (*S3Error, bool) {
+	var e *S3Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// IsNotFound reports whether err was caused by a missing bucket,
+// object, version or upload.
+func IsNotFound(err error) bool {
+	e, ok := asS3Error(err)
+	if !ok {
+		return false
+	}
+
+	switch e.Code {
+	case "NoSuchKey", "NoSuchBucket", "NoSuchVersion", "NoSuchUpload",
+		"NoSuchLifecycleConfiguration", "NoSuchTagSet", "NotFound":
+		return true
+	}
+	return e.StatusCode == http.StatusNotFound
+}
+
+// IsAccessDenied reports whether err was caused by S3 denying
+// access to the requested resource.
+func IsAccessDenied(err error) bool {
+	e, ok := asS3Error(err)
+	if !ok {
+		return false
+	}
+	return e.Code == "AccessDenied" || e.StatusCode == http.StatusForbidden
+}
+
+// IsPreconditionFailed reports whether err was caused by a failed
+// conditional request (412 Precondition Failed).
+func IsPreconditionFailed(err error) bool {
+	e, ok := asS3Error(err)
+	if !ok {
+		return false
+	}
+	return e.Code == "PreconditionFailed" || e.StatusCode == http.StatusPreconditionFailed
+}
+
+// IsConditionalConflict reports whether err was caused by a conditional
+// write conflicting with a concurrent write of the same object
+// (409 ConditionalRequestConflict). The write may be retried.
+func IsConditionalConflict(err error) bool {
+	e, ok := asS3Error(err)
+	if !ok {
+		return false
+	}
+	return e.Code == "ConditionalRequestConflict"
+}
+
+// IsRestoreInProgress reports whether err was caused by a restore of
+// the object already being in progress (409 RestoreAlreadyInProgress).
+func IsRestoreInProgress(err error) bool {
+	e, ok := asS3Error(err)
+	if !ok {
+		return false
+	}
+	return e.Code == "RestoreAlreadyInProgress"
+}
+
+// IsDeleteMarker reports whether err was caused by the object, or the
+// requested version, being a delete marker.
+func IsDeleteMarker(err error) bool {
+	e, ok := asS3Error(err)
+	if !ok {
+		return false
+	}
+	return e.DeleteMarker
+}
+
+// IsNotImplemented reports whether err was caused by the backend not
+// supporting the requested operation (501 Not Implemented).
+func IsNotImplemented(err error) bool {
+	e, ok := asS3Error(err)
+	if !ok {
+		return false
+	}
+	return e.Code == "NotImplemented" || e.StatusCode == http.StatusNotImplemented
+}
@@ -0,0 +1,227 @@
+package simples3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestS3Error_Response(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-amz-request-id", "REQ123")
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, "/denied"):
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?>
+<Error><Code>AccessDenied</Code><Message>Access Denied</Message><RequestId>REQ456</RequestId><HostId>HOST</HostId></Error>`)
+		case r.Method == http.MethodPut:
+			w.WriteHeader(http.StatusPreconditionFailed)
+			io.WriteString(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+		}
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	t.Run("FileDownload", func(t *testing.T) {
+		_, err := s3.FileDownload(DownloadInput{Bucket: "bucket", ObjectKey: "missing"})
+
+		var s3Err *S3Error
+		if !errors.As(err, &s3Err) {
+			t.Fatalf("expected *S3Error, got %T: %v", err, err)
+		}
+		if s3Err.Code != "NoSuchKey" || s3Err.StatusCode != http.StatusNotFound {
+			t.Errorf("unexpected error fields: %+v", s3Err)
+		}
+		if s3Err.Operation != "FileDownload" || s3Err.Bucket != "bucket" || s3Err.Key != "missing" {
+			t.Errorf("unexpected error context: %+v", s3Err)
+		}
+		if s3Err.RequestID != "REQ123" {
+			t.Errorf("expected request id from header, got %q", s3Err.RequestID)
+		}
+		if !IsNotFound(err) {
+			t.Errorf("IsNotFound() = false, want true")
+		}
+	})
+
+	t.Run("FileDetails", func(t *testing.T) {
+		_, err := s3.FileDetails(DetailsInput{Bucket: "bucket", ObjectKey: "missing"})
+		if !IsNotFound(err) {
+			t.Errorf("IsNotFound() = false for %v", err)
+		}
+
+		s3Err, _ := asS3Error(err)
+		if s3Err == nil || s3Err.Code != "NotFound" {
+			t.Errorf("expected NotFound code for HEAD, got %v", err)
+		}
+	})
+
+	t.Run("AccessDenied", func(t *testing.T) {
+		_, err := s3.GetObjectTagging(GetObjectTaggingInput{Bucket: "bucket", ObjectKey: "denied"})
+		if !IsAccessDenied(err) {
+			t.Fatalf("IsAccessDenied() = false for %v", err)
+		}
+		if IsNotFound(err) {
+			t.Errorf("IsNotFound() = true for %v", err)
+		}
+
+		s3Err, _ := asS3Error(err)
+		if s3Err.RequestID != "REQ456" || s3Err.HostID != "HOST" {
+			t.Errorf("expected ids from error document, got %+v", s3Err)
+		}
+	})
+
+	t.Run("PreconditionFailed", func(t *testing.T) {
+		_, err := s3.FilePut(UploadInput{
+			Bucket:    "bucket",
+			ObjectKey: "key",
+			Body:      strings.NewReader("data"),
+		})
+		if !IsPreconditionFailed(err) {
+			t.Errorf("IsPreconditionFailed() = false for %v", err)
+		}
+	})
+}
+
+func TestS3Error_Helpers(t *testing.T) {
+	wrapped := fmt.Errorf("wrapped: %w", &S3Error{Code: "NoSuchBucket", StatusCode: http.StatusNotFound})
+	if !IsNotFound(wrapped) {
+		t.Errorf("IsNotFound() should see through wrapped errors")
+	}
+	if IsNotFound(errors.New("NoSuchKey")) {
+		t.Errorf("IsNotFound() should not match plain errors")
+	}
+	if IsDeleteMarker(&S3Error{Code: "NotFound", StatusCode: http.StatusNotFound}) {
+		t.Errorf("IsDeleteMarker() should not match other errors")
+	}
+
+	if !IsRetryable(&S3Error{Code: "InternalError", StatusCode: http.StatusInternalServerError}) {
+		t.Errorf("expected 500 to be retryable")
+	}
+	if IsRetryable(&S3Error{Code: "NoSuchKey", StatusCode: http.StatusNotFound}) {
+		t.Errorf("expected 404 not to be retryable")
+	}
+	if !IsRetryable(fmt.Errorf("read: %w", io.ErrUnexpectedEOF)) {
+		t.Errorf("expected unexpected EOF to be retryable")
+	}
+
+	e := S3Error{
+		Code:      "NoSuchKey",
+		Message:   "The specified key does not exist.",
+		Status:    "404 Not Found",
+		Operation: "FileDownload",
+		Bucket:    "bucket",
+		Key:       "key",
+	}
+	want := "S3 Error: NoSuchKey - The specified key does not exist. (FileDownload bucket/key, status code: 404 Not Found)"
+	if e.Error() != want {
+		t.Errorf("Error() = %q, want %q", e.Error(), want)
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/exists"):
+			w.WriteHeader(http.StatusPreconditionFailed)
+			io.WriteString(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+		case strings.HasSuffix(r.URL.Path, "/conflict"):
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, `<Error><Code>ConditionalRequestConflict</Code><Message>A conflicting operation occurred.</Message></Error>`)
+		case r.URL.Query().Has("uploadId"):
+			io.WriteString(w, `<CompleteMultipartUploadResult><ETag>"etag"</ETag></CompleteMultipartUploadResult>`)
+		case r.Header.Get("x-amz-copy-source") != "":
+			io.WriteString(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+		default:
+			w.Header().Set("ETag", `"etag"`)
+		}
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	put := func(key string) error {
+		_, err := s3.FilePut(UploadInput{
+			Bucket:      "bucket",
+			ObjectKey:   key,
+			Body:        strings.NewReader("data"),
+			IfNoneMatch: "*",
+		})
+		return err
+	}
+
+	if err := put("new"); err != nil {
+		t.Fatal(err)
+	}
+	if v := got.Get("If-None-Match"); v != "*" {
+		t.Errorf("If-None-Match = %q, want *", v)
+	}
+
+	err := put("exists")
+	if !IsPreconditionFailed(err) || IsConditionalConflict(err) {
+		t.Errorf("expected a precondition failure, got %v", err)
+	}
+	err = put("conflict")
+	if !IsConditionalConflict(err) || IsPreconditionFailed(err) {
+		t.Errorf("expected a conditional conflict, got %v", err)
+	}
+
+	t.Run("CompleteMultipartUpload", func(t *testing.T) {
+		_, err := s3.CompleteMultipartUpload(CompleteMultipartUploadInput{
+			Bucket:    "bucket",
+			ObjectKey: "key",
+			UploadID:  "upload",
+			Parts:     []CompletedPart{{PartNumber: 1, ETag: `"etag"`}},
+			IfMatch:   `"previous"`,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := got.Get("If-Match"); v != `"previous"` {
+			t.Errorf("If-Match = %q", v)
+		}
+	})
+
+	t.Run("CopyObject", func(t *testing.T) {
+		since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		_, err := s3.CopyObject(CopyObjectInput{
+			SourceBucket:              "bucket",
+			SourceKey:                 "source",
+			DestBucket:                "bucket",
+			DestKey:                   "dest",
+			IfNoneMatch:               "*",
+			CopySourceIfMatch:         `"source"`,
+			CopySourceIfModifiedSince: since,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{
+			"If-None-Match":                       "*",
+			"X-Amz-Copy-Source-If-Match":          `"source"`,
+			"X-Amz-Copy-Source-If-Modified-Since": since.Format(http.TimeFormat),
+		}
+		for k, v := range want {
+			if got.Get(k) != v {
+				t.Errorf("%s = %q, want %q", k, got.Get(k), v)
+			}
+		}
+		if got.Get("X-Amz-Copy-Source-If-None-Match") != "" {
+			t.Errorf("unexpected X-Amz-Copy-Source-If-None-Match header")
+		}
+	})
+}
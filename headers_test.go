@@ -0,0 +1,243 @@
+package simples3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObjectHeaders(t *testing.T) {
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifySigV4(r, "SuperSecretKey"); err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		got = r.Header.Clone()
+		switch {
+		case r.Header.Get("x-amz-copy-source") != "":
+			io.WriteString(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+		case r.Method == http.MethodPost && r.URL.Query().Has("uploads"):
+			io.WriteString(w, `<InitiateMultipartUploadResult><UploadId>upload</UploadId></InitiateMultipartUploadResult>`)
+		}
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	grantee := `uri="http://acs.amazonaws.com/groups/global/AllUsers"`
+
+	checkHeaders := func(t *testing.T, encoding string) {
+		t.Helper()
+		want := map[string]string{
+			"Cache-Control":                   "public, max-age=3600",
+			"Content-Encoding":                encoding,
+			"Content-Language":                "en",
+			"Expires":                         "Wed, 02 Jan 2030 03:04:05 GMT",
+			"x-amz-website-redirect-location": "/new",
+			"x-amz-grant-read":                grantee,
+		}
+		for k, v := range want {
+			if got.Get(k) != v {
+				t.Errorf("%s = %q, want %q", k, got.Get(k), v)
+			}
+		}
+	}
+
+	t.Run("FilePut", func(t *testing.T) {
+		_, err := s3.FilePut(UploadInput{
+			Bucket:                  "bucket",
+			ObjectKey:               "key",
+			Body:                    strings.NewReader("content"),
+			CacheControl:            "public, max-age=3600",
+			ContentEncoding:         "identity",
+			ContentLanguage:         "en",
+			Expires:                 expires,
+			WebsiteRedirectLocation: "/new",
+			GrantRead:               grantee,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkHeaders(t, "identity")
+	})
+
+	t.Run("InitiateMultipartUpload", func(t *testing.T) {
+		_, err := s3.InitiateMultipartUpload(InitiateMultipartUploadInput{
+			Bucket:                  "bucket",
+			ObjectKey:               "key",
+			CacheControl:            "public, max-age=3600",
+			ContentEncoding:         "identity",
+			ContentLanguage:         "en",
+			Expires:                 expires,
+			WebsiteRedirectLocation: "/new",
+			GrantRead:               grantee,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkHeaders(t, "identity")
+	})
+
+	t.Run("CopyObject", func(t *testing.T) {
+		input := CopyObjectInput{
+			SourceBucket: "bucket", SourceKey: "source",
+			DestBucket: "bucket", DestKey: "dest",
+			MetadataDirective:       "REPLACE",
+			CacheControl:            "public, max-age=3600",
+			ContentEncoding:         "identity",
+			ContentLanguage:         "en",
+			Expires:                 expires,
+			WebsiteRedirectLocation: "/new",
+			GrantRead:               grantee,
+		}
+		if _, err := s3.CopyObject(input); err != nil {
+			t.Fatal(err)
+		}
+		checkHeaders(t, "identity")
+
+		// The headers of the source are kept without REPLACE.
+		input.MetadataDirective = ""
+		if _, err := s3.CopyObject(input); err != nil {
+			t.Fatal(err)
+		}
+		if got.Get("Cache-Control") != "" || got.Get("x-amz-grant-read") != grantee {
+			t.Errorf("unexpected headers without REPLACE: %v", got)
+		}
+	})
+
+	t.Run("CopyObjectMultipart", func(t *testing.T) {
+		var init http.Header
+		ts := newCopyStub(t, nil, &init)
+		s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+		s3.SetEndpoint(ts.URL)
+
+		_, err := s3.CopyObjectMultipart(CopyObjectMultipartInput{CopyObjectInput: CopyObjectInput{
+			SourceBucket: "src", SourceKey: "big.bin",
+			DestBucket: "dst", DestKey: "copy.bin",
+			MetadataDirective:       "REPLACE",
+			CacheControl:            "public, max-age=3600",
+			ContentEncoding:         "identity",
+			ContentLanguage:         "en",
+			Expires:                 expires,
+			WebsiteRedirectLocation: "/new",
+			GrantRead:               grantee,
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = init
+		checkHeaders(t, "identity")
+	})
+
+	t.Run("Compression", func(t *testing.T) {
+		_, err := s3.FilePut(UploadInput{
+			Bucket:          "bucket",
+			ObjectKey:       "key",
+			Body:            strings.NewReader("content"),
+			ContentEncoding: CompressionGzip,
+			Compression:     CompressionGzip,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Get("Content-Encoding") != CompressionGzip {
+			t.Errorf("Content-Encoding = %q, want gzip", got.Get("Content-Encoding"))
+		}
+
+		_, err = s3.FileUploadMultipart(MultipartUploadInput{
+			Bucket:          "bucket",
+			ObjectKey:       "key",
+			Body:            bytes.NewReader([]byte("content")),
+			ContentEncoding: "br",
+			Compression:     CompressionGzip,
+		})
+		if err == nil {
+			t.Errorf("expected a content encoding other than the compression to be rejected")
+		}
+	})
+}
+
+func TestFileDetails_ObjectHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Content-Disposition", `attachment; filename="report.pdf"`)
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Language", "de")
+		w.Header().Set("Expires", "Wed, 02 Jan 2030 03:04:05 GMT")
+		w.Header().Set("x-amz-website-redirect-location", "/new")
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	details, err := s3.FileDetails(DetailsInput{Bucket: "bucket", ObjectKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.CacheControl != "no-cache" ||
+		details.ContentDisposition != `attachment; filename="report.pdf"` ||
+		details.ContentEncoding != "gzip" ||
+		details.ContentLanguage != "de" ||
+		!details.Expires.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)) ||
+		details.WebsiteRedirectLocation != "/new" {
+		t.Errorf("unexpected details %+v", details)
+	}
+	// The headers stay in ExtraHeaders, as before they had a field.
+	if details.ExtraHeaders["Cache-Control"] != "no-cache" || details.ExtraHeaders["X-Amz-Website-Redirect-Location"] != "/new" {
+		t.Errorf("unexpected extra headers %v", details.ExtraHeaders)
+	}
+}
+
+func TestCreateUploadPolicies_ObjectHeaders(t *testing.T) {
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	policies, err := s3.CreateUploadPolicies(UploadConfig{
+		BucketName:      "bucket",
+		ObjectKey:       "key",
+		ContentType:     "text/html",
+		CacheControl:    "max-age=60",
+		ContentLanguage: "en",
+		Expires:         time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"Cache-Control":    "max-age=60",
+		"Content-Language": "en",
+		"Expires":          "Wed, 02 Jan 2030 03:04:05 GMT",
+	}
+	data, err := base64.StdEncoding.DecodeString(policies.Form["Policy"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var policy struct {
+		Conditions []json.RawMessage `json:"conditions"`
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range want {
+		if policies.Form[k] != v {
+			t.Errorf("form field %s = %q, want %q", k, policies.Form[k], v)
+		}
+		condition, _ := json.Marshal(map[string]string{k: v})
+		found := false
+		for _, c := range policy.Conditions {
+			found = found || bytes.Equal(c, condition)
+		}
+		if !found {
+			t.Errorf("no policy condition for %s in %s", k, data)
+		}
+	}
+}
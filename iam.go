@@ -4,10 +4,16 @@
 package simples3
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -18,10 +24,20 @@ const (
 	securityCredentialsURI = "/meta-data/iam/security-credentials/"
 	imdsTokenURI           = "/api/token"
 	defaultIMDSTokenTTL    = "60"
+
+	containerCredentialsHost = "http://169.254.170.2"
+
+	// credentialsRefresherRetry is how long StartCredentialsRefresher
+	// waits before trying again after failing to refresh credentials.
+	credentialsRefresherRetry = 10 * time.Second
 )
 
-// IAMResponse is used by NewUsingIAM to auto
-// detect the credentials.
+// DefaultCredentialsRefreshWindow is how long before their expiry
+// credentials are refreshed, unless set using SetCredentialsRefreshWindow.
+const DefaultCredentialsRefreshWindow = 5 * time.Minute
+
+// IAMResponse is used by NewUsingIAM and NewUsingContainerCredentials
+// to auto detect the credentials.
 type IAMResponse struct {
 	Code            string    `json:"Code"`
 	LastUpdated     string    `json:"LastUpdated"`
@@ -35,7 +51,13 @@ type IAMResponse struct {
 // NewUsingIAM automatically generates an Instance of S3
 // using instance metatdata.
 func NewUsingIAM(region string) (*S3, error) {
-	return newUsingIAM(
+	return NewUsingIAMWithContext(context.Background(), region)
+}
+
+// NewUsingIAMWithContext is like NewUsingIAM but uses ctx for
+// the instance metadata requests.
+func NewUsingIAMWithContext(ctx context.Context, region string) (*S3, error) {
+	return newUsingIAMWithContext(ctx,
 		&http.Client{
 			// Set a timeout of 3 seconds for AWS IAM Calls.
 			Timeout: time.Second * 3, //nolint:gomnd
@@ -46,8 +68,8 @@ func NewUsingIAM(region string) (*S3, error) {
 // EC2 instance metadata service. It returns a token and boolean,
 // only if IMDSv2 is enabled in the EC2 instance metadata
 // configuration, otherwise returns an error.
-func fetchIMDSToken(cl *http.Client, baseURL string) (string, bool, error) {
-	req, err := http.NewRequest(http.MethodPut, baseURL+imdsTokenURI, nil)
+func fetchIMDSToken(ctx context.Context, cl *http.Client, baseURL string) (string, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, baseURL+imdsTokenURI, nil)
 	if err != nil {
 		return "", false, err
 	}
@@ -81,15 +103,15 @@ func fetchIMDSToken(cl *http.Client, baseURL string) (string, bool, error) {
 // In case of a normal AWS setup, baseURL would be metadataBaseURL.
 // You can use this method, to manually fetch IAM data from a custom
 // endpoint and pass it to SetIAMData.
-func fetchIAMData(cl *http.Client, baseURL string) (IAMResponse, error) {
-	token, useIMDSv2, err := fetchIMDSToken(cl, baseURL)
+func fetchIAMData(ctx context.Context, cl *http.Client, baseURL string) (IAMResponse, error) {
+	token, useIMDSv2, err := fetchIMDSToken(ctx, cl, baseURL)
 	if err != nil {
 		return IAMResponse{}, fmt.Errorf("error fetching IMDSv2 token: %w", err)
 	}
 
 	url := baseURL + securityCredentialsURI
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return IAMResponse{}, fmt.Errorf("error creating imdsv2 token request: %w", err)
 	}
@@ -115,7 +137,7 @@ func fetchIAMData(cl *http.Client, baseURL string) (IAMResponse, error) {
 	_, _ = io.Copy(io.Discard, resp.Body)
 	_ = resp.Body.Close()
 
-	req, err = http.NewRequest(http.MethodGet, url+string(role), nil)
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, url+string(role), nil)
 	if err != nil {
 		return IAMResponse{}, fmt.Errorf("error creating role request: %w", err)
 	}
@@ -151,52 +173,303 @@ func fetchIAMData(cl *http.Client, baseURL string) (IAMResponse, error) {
 	return jResp, nil
 }
 
-func newUsingIAM(cl *http.Client, baseURL, region string) (*S3, error) {
-	// Get the IAM role
-	iamResp, err := fetchIAMData(cl, baseURL)
+// NewUsingContainerCredentials automatically generates an Instance of S3
+// using the credentials endpoint of an ECS task or EKS pod.
+func NewUsingContainerCredentials(region string) (*S3, error) {
+	return NewUsingContainerCredentialsWithContext(context.Background(), region)
+}
+
+// NewUsingContainerCredentialsWithContext is like NewUsingContainerCredentials
+// but uses ctx for the credentials request.
+func NewUsingContainerCredentialsWithContext(ctx context.Context, region string) (*S3, error) {
+	s3 := NewWithProvider(region, ContainerProvider{})
+	if err := s3.renewIAMToken(ctx); err != nil {
+		return nil, err
+	}
+	return s3, nil
+}
+
+// ContainerProvider provides the credentials of the IAM role of an ECS task,
+// or of an EKS pod using Pod Identity. The endpoint to fetch them from is
+// read from AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or
+// AWS_CONTAINER_CREDENTIALS_FULL_URI, along with the authorization token in
+// AWS_CONTAINER_AUTHORIZATION_TOKEN or AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE.
+type ContainerProvider struct {
+	// Client is used for the credentials requests. Defaults to a
+	// client with a timeout of 3 seconds.
+	Client *http.Client
+}
+
+// Retrieve fetches the credentials from the container credentials endpoint.
+func (p ContainerProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	endpoint, err := containerCredentialsURL()
 	if err != nil {
-		return nil, fmt.Errorf("error fetching IAM data: %w", err)
+		return Credentials{}, err
+	}
+
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if file := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("error reading container authorization token: %w", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	cl := p.Client
+	if cl == nil {
+		cl = &http.Client{
+			// Set a timeout of 3 seconds for AWS IAM Calls.
+			Timeout: time.Second * 3, //nolint:gomnd
+		}
 	}
 
-	return &S3{
-		Region:    region,
-		AccessKey: iamResp.AccessKeyID,
-		SecretKey: iamResp.SecretAccessKey,
-		Token:     iamResp.Token,
+	iamResp, err := fetchContainerCredentials(ctx, cl, endpoint, token)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("error fetching container credentials: %w", err)
+	}
 
-		URIFormat: "https://s3.%s.amazonaws.com/%s",
-		initMode:  "iam",
-		expiry:    iamResp.Expiration,
+	return Credentials{
+		AccessKeyID:     iamResp.AccessKeyID,
+		SecretAccessKey: iamResp.SecretAccessKey,
+		SessionToken:    iamResp.Token,
+		Expires:         iamResp.Expiration,
 	}, nil
 }
 
+// containerCredentialsURL returns the URL of the container credentials
+// endpoint configured in the environment. Full URIs using plain HTTP
+// must point to a loopback address or to the ECS and EKS agents.
+func containerCredentialsURL() (string, error) {
+	if rel := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); rel != "" {
+		return containerCredentialsHost + rel, nil
+	}
+
+	full := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if full == "" {
+		return "", fmt.Errorf("container credentials: %w", ErrNoCredentials)
+	}
+
+	u, err := url.Parse(full)
+	if err != nil {
+		return "", fmt.Errorf("invalid AWS_CONTAINER_CREDENTIALS_FULL_URI: %w", err)
+	}
+	if u.Scheme == "https" {
+		return full, nil
+	}
+
+	switch host := u.Hostname(); host {
+	case "localhost", "169.254.170.2", "169.254.170.23", "fd00:ec2::23":
+		return full, nil
+	default:
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			return full, nil
+		}
+	}
+	return "", fmt.Errorf("AWS_CONTAINER_CREDENTIALS_FULL_URI host %q is not allowed over http", u.Host)
+}
+
+// fetchContainerCredentials fetches the credentials from the container
+// credentials endpoint, which responds in the same format as IMDS.
+func fetchContainerCredentials(ctx context.Context, cl *http.Client, endpoint, token string) (IAMResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return IAMResponse{}, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := cl.Do(req)
+	if err != nil {
+		return IAMResponse{}, err
+	}
+
+	defer func() {
+		// Drain and close the body to let the Transport reuse the connection
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return IAMResponse{}, fmt.Errorf("got non 200 code: %s", resp.Status)
+	}
+
+	var jResp IAMResponse
+	jsonString, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return IAMResponse{}, fmt.Errorf("error reading credentials: %w", err)
+	}
+
+	if err := json.Unmarshal(jsonString, &jResp); err != nil {
+		return IAMResponse{}, fmt.Errorf("error unmarshalling credentials: %w (%s)", err, jsonString)
+	}
+
+	return jResp, nil
+}
+
+func newUsingIAM(cl *http.Client, baseURL, region string) (*S3, error) {
+	return newUsingIAMWithContext(context.Background(), cl, baseURL, region)
+}
+
+func newUsingIAMWithContext(ctx context.Context, cl *http.Client, baseURL, region string) (*S3, error) {
+	s3 := NewWithProvider(region, IMDSProvider{Client: cl, Endpoint: baseURL})
+
+	// Get the IAM role
+	if err := s3.renewIAMToken(ctx); err != nil {
+		return nil, err
+	}
+
+	return s3, nil
+}
+
 // setIAMData sets the IAM data on the S3 instance.
 func (s3 *S3) SetIAMData(iamResp IAMResponse) {
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+
 	s3.AccessKey = iamResp.AccessKeyID
 	s3.SecretKey = iamResp.SecretAccessKey
 	s3.Token = iamResp.Token
+	s3.expiry = iamResp.Expiration
+}
+
+// credentialsRefresh is a retrieval of credentials from the client's
+// provider, shared by all the callers of renewIAMToken while it runs.
+type credentialsRefresh struct {
+	done chan struct{}
+	err  error
 }
 
-func (s3 *S3) renewIAMToken() error {
-	if s3.initMode != "iam" {
+// renewIAMToken retrieves credentials from the client's provider, if it
+// has one, when none were retrieved yet or they expire within the refresh
+// window. Concurrent callers wait for a single retrieval, which another
+// caller starts again if the one running it is cancelled. If it fails
+// while the current credentials are still valid, they keep being used.
+func (s3 *S3) renewIAMToken(ctx context.Context) error {
+	for {
+		s3.mu.Lock()
+		if s3.provider == nil || !s3.needsRefresh() {
+			s3.mu.Unlock()
+			return nil
+		}
+
+		r := s3.refresh
+		leader := r == nil
+		if leader {
+			r = &credentialsRefresh{done: make(chan struct{})}
+			s3.refresh = r
+		}
+		provider := s3.provider
+		s3.mu.Unlock()
+
+		if leader {
+			creds, err := provider.Retrieve(ctx)
+
+			s3.mu.Lock()
+			// Don't store credentials of a provider that has been replaced
+			// using SetCredentialsProvider in the meantime.
+			if err == nil && provider == s3.provider {
+				s3.expiry = creds.Expires
+				s3.Token = creds.SessionToken
+				s3.AccessKey = creds.AccessKeyID
+				s3.SecretKey = creds.SecretAccessKey
+			}
+			r.err = err
+			if s3.refresh == r {
+				s3.refresh = nil
+			}
+			s3.mu.Unlock()
+			close(r.done)
+		} else {
+			select {
+			case <-r.done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		// The retrieval was cancelled with the request of the caller that
+		// started it: start another one, unless this request is done too.
+		if !leader && ctx.Err() == nil &&
+			(errors.Is(r.err, context.Canceled) || errors.Is(r.err, context.DeadlineExceeded)) {
+			continue
+		}
+
+		if r.err != nil {
+			s3.mu.Lock()
+			valid := s3.AccessKey != "" && (s3.expiry.IsZero() || time.Now().Before(s3.expiry))
+			s3.mu.Unlock()
+			if !valid {
+				return r.err
+			}
+		}
+
 		return nil
 	}
+}
 
-	if time.Since(s3.expiry) < 0 {
-		return nil
+// needsRefresh reports whether credentials must be retrieved from the
+// provider. It must be called with s3.mu held.
+func (s3 *S3) needsRefresh() bool {
+	if s3.AccessKey == "" {
+		return true
 	}
+	if s3.expiry.IsZero() {
+		return false
+	}
+
+	return time.Until(s3.expiry) < s3.getRefreshWindow()
+}
 
+// getRefreshWindow returns the client's credentials refresh window.
+// It must be called with s3.mu held.
+func (s3 *S3) getRefreshWindow() time.Duration {
+	if s3.refreshWindow != nil {
+		return *s3.refreshWindow
+	}
+	return DefaultCredentialsRefreshWindow
+}
+
+// SetCredentialsRefreshWindow sets how long before their expiry credentials
+// obtained from a provider (or IMDS) are refreshed. Requests made during
+// that window trigger a refresh, so that requests in flight are never
+// signed with credentials that are about to expire.
+// Defaults to DefaultCredentialsRefreshWindow.
+func (s3 *S3) SetCredentialsRefreshWindow(d time.Duration) *S3 {
 	s3.mu.Lock()
 	defer s3.mu.Unlock()
-	iamResp, err := fetchIAMData(s3.getClient(), metadataBaseURL)
-	if err != nil {
-		return fmt.Errorf("error fetching IAM data: %w", err)
-	}
 
-	s3.expiry = iamResp.Expiration
-	s3.Token = iamResp.Token
-	s3.AccessKey = iamResp.AccessKeyID
-	s3.SecretKey = iamResp.SecretAccessKey
+	s3.refreshWindow = &d
+	return s3
+}
 
-	return nil
+// StartCredentialsRefresher starts a goroutine that refreshes credentials
+// obtained from a provider (or IMDS) as they enter the refresh window,
+// so that requests never have to wait for credentials to be retrieved.
+// It stops when ctx is done, or if the credentials never expire.
+func (s3 *S3) StartCredentialsRefresher(ctx context.Context) {
+	go func() {
+		for {
+			err := s3.renewIAMToken(ctx)
+
+			s3.mu.Lock()
+			wait := credentialsRefresherRetry
+			if err == nil {
+				if s3.expiry.IsZero() {
+					s3.mu.Unlock()
+					return
+				}
+				wait = time.Until(s3.expiry) - s3.getRefreshWindow()
+			}
+			s3.mu.Unlock()
+
+			if wait < time.Second {
+				wait = time.Second
+			}
+			if err := sleepWithContext(ctx, wait); err != nil {
+				return
+			}
+		}
+	}()
 }
@@ -1,11 +1,14 @@
 package simples3
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -108,3 +111,42 @@ func TestS3_NewUsingIAM(t *testing.T) {
 		t.Errorf("Expected error, got nil")
 	}
 }
+
+func TestContainerProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/credentials/task" || r.Header.Get("Authorization") != "secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, `{"AccessKeyId":"container-key","SecretAccessKey":"container-secret",
+			"Token":"container-token","Expiration":"2030-01-01T00:00:00Z","RoleArn":"arn"}`)
+	}))
+	defer ts.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("secret-token\n"), 0o600)
+
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", tokenFile)
+
+	if _, err := NewUsingContainerCredentials("us-east-1"); !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("expected ErrNoCredentials, got %v", err)
+	}
+
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", ts.URL+"/v2/credentials/task")
+	s3, err := NewUsingContainerCredentials("us-east-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s3.AccessKey != "container-key" || s3.Token != "container-token" || s3.expiry.Year() != 2030 {
+		t.Errorf("unexpected credentials: %+v", s3)
+	}
+
+	// Plain HTTP is only allowed to local endpoints.
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "http://example.com/creds")
+	if _, err := (ContainerProvider{}).Retrieve(context.Background()); err == nil {
+		t.Errorf("expected non-local http endpoint to be rejected")
+	}
+}
@@ -4,6 +4,7 @@
 package simples3
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -72,22 +73,13 @@ type Object struct {
 	StorageClass string
 }
 
-// S3Error represents an S3 API error response
-type S3Error struct {
-	XMLName   xml.Name `xml:"Error"`
-	Code      string   `xml:"Code"`
-	Message   string   `xml:"Message"`
-	RequestID string   `xml:"RequestId"`
-	HostID    string   `xml:"HostId"`
-}
-
-// Error returns a string representation of the S3Error
-func (e S3Error) Error() string {
-	return fmt.Sprintf("S3 Error: %s - %s", e.Code, e.Message)
-}
-
 // List implements a simple S3 object listing API
 func (s3 *S3) List(input ListInput) (ListResponse, error) {
+	return s3.ListWithContext(context.Background(), input)
+}
+
+// ListWithContext is like List but uses ctx for the request.
+func (s3 *S3) ListWithContext(ctx context.Context, input ListInput) (ListResponse, error) {
 	// Input validation
 	if input.Bucket == "" {
 		return ListResponse{}, fmt.Errorf("bucket name cannot be empty")
@@ -131,22 +123,13 @@ func (s3 *S3) List(input ListInput) (ListResponse, error) {
 	parsedURL.RawQuery = query.Encode()
 
 	// Create HTTP request
-	req, err := http.NewRequest(http.MethodGet, parsedURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
 	if err != nil {
 		return ListResponse{}, err
 	}
 
-	// Apply AWS V4 signing
-	if err := s3.renewIAMToken(); err != nil {
-		return ListResponse{}, err
-	}
-	if err := s3.signRequest(req); err != nil {
-		return ListResponse{}, err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return ListResponse{}, err
 	}
@@ -162,11 +145,7 @@ func (s3 *S3) List(input ListInput) (ListResponse, error) {
 
 	// Handle response status codes
 	if res.StatusCode != http.StatusOK {
-		var s3Err S3Error
-		if xmlErr := xml.Unmarshal(body, &s3Err); xmlErr == nil {
-			return ListResponse{}, fmt.Errorf("S3 Error: %s - %s", s3Err.Code, s3Err.Message)
-		}
-		return ListResponse{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return ListResponse{}, newResponseError("List", input.Bucket, "", res, body)
 	}
 
 	// Parse XML response using internal struct
@@ -223,13 +202,18 @@ func (s3 *S3) List(input ListInput) (ListResponse, error) {
 // automatically handling pagination. It also returns a finish callback
 // that should be called after iteration to check for any errors.
 func (s3 *S3) ListAll(input ListInput) (iter.Seq[Object], func() error) {
+	return s3.ListAllWithContext(context.Background(), input)
+}
+
+// ListAllWithContext is like ListAll but uses ctx for every page request.
+func (s3 *S3) ListAllWithContext(ctx context.Context, input ListInput) (iter.Seq[Object], func() error) {
 	var iterErr error
 
 	seq := func(yield func(Object) bool) {
 		currentInput := input
 
 		for {
-			response, err := s3.List(currentInput)
+			response, err := s3.ListWithContext(ctx, currentInput)
 			if err != nil {
 				iterErr = err
 				return
@@ -325,6 +309,11 @@ type ListVersionsResponse struct {
 
 // ListVersions lists object versions in a bucket.
 func (s3 *S3) ListVersions(input ListVersionsInput) (ListVersionsResponse, error) {
+	return s3.ListVersionsWithContext(context.Background(), input)
+}
+
+// ListVersionsWithContext is like ListVersions but uses ctx for the request.
+func (s3 *S3) ListVersionsWithContext(ctx context.Context, input ListVersionsInput) (ListVersionsResponse, error) {
 	// Input validation
 	if input.Bucket == "" {
 		return ListVersionsResponse{}, fmt.Errorf("bucket name cannot be empty")
@@ -368,22 +357,13 @@ func (s3 *S3) ListVersions(input ListVersionsInput) (ListVersionsResponse, error
 	parsedURL.RawQuery = query.Encode()
 
 	// Create HTTP request
-	req, err := http.NewRequest(http.MethodGet, parsedURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
 	if err != nil {
 		return ListVersionsResponse{}, err
 	}
 
-	// Apply AWS V4 signing
-	if err := s3.renewIAMToken(); err != nil {
-		return ListVersionsResponse{}, err
-	}
-	if err := s3.signRequest(req); err != nil {
-		return ListVersionsResponse{}, err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return ListVersionsResponse{}, err
 	}
@@ -399,11 +379,7 @@ func (s3 *S3) ListVersions(input ListVersionsInput) (ListVersionsResponse, error
 
 	// Handle response status codes
 	if res.StatusCode != http.StatusOK {
-		var s3Err S3Error
-		if xmlErr := xml.Unmarshal(body, &s3Err); xmlErr == nil {
-			return ListVersionsResponse{}, fmt.Errorf("S3 Error: %s - %s", s3Err.Code, s3Err.Message)
-		}
-		return ListVersionsResponse{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return ListVersionsResponse{}, newResponseError("ListVersions", input.Bucket, "", res, body)
 	}
 
 	// Parse XML response using internal struct for CommonPrefixes handling
@@ -6,13 +6,18 @@ import (
 	"crypto/md5"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/xml"
 	"fmt"
+	"hash"
 	"io"
-	"math"
+	"iter"
 	"net/http"
 	"net/url"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -22,7 +27,8 @@ const (
 	MinPartSize          = 5 * 1024 * 1024        // 5 MB
 	MaxPartSize          = 5 * 1024 * 1024 * 1024 // 5 GB
 	MaxParts             = 10000
-	DefaultPartSize      = 5 * 1024 * 1024 // 5 MB
+	DefaultPartSize      = 5 * 1024 * 1024   // 5 MB
+	DefaultCopyPartSize  = 100 * 1024 * 1024 // 100 MB
 	DefaultMaxRetries    = 3
 	DefaultRetryBaseWait = 100 * time.Millisecond
 	DefaultRetryMaxWait  = 5 * time.Second
@@ -30,16 +36,44 @@ const (
 
 // InitiateMultipartUploadInput contains parameters for initiating a multipart upload
 type InitiateMultipartUploadInput struct {
-	Bucket         string            // Required: bucket name
-	ObjectKey      string            // Required: object key
-	ContentType    string            // Optional: content type
-	CustomMetadata map[string]string // Optional: x-amz-meta-* headers
-	ACL            string            // Optional: x-amz-acl
+	Bucket          string            // Required: bucket name
+	ObjectKey       string            // Required: object key
+	ContentType     string            // Optional: content type
+	ContentEncoding string            // Optional: content encoding, e.g. "gzip"
+	CustomMetadata  map[string]string // Optional: x-amz-meta-* headers
+	ACL             string            // Optional: x-amz-acl
+	StorageClass    string            // Optional: storage class, e.g. "STANDARD_IA"
+
+	// Optional: Standard headers of the object (see UploadInput)
+	ContentDisposition      string
+	CacheControl            string
+	ContentLanguage         string
+	Expires                 time.Time
+	WebsiteRedirectLocation string
+
+	// Optional: Grants of permissions to the object (see UploadInput)
+	GrantRead        string
+	GrantReadACP     string
+	GrantWriteACP    string
+	GrantFullControl string
 
 	// Optional: Server-side encryption (e.g. "AES256" or "aws:kms")
 	ServerSideEncryption string
 	// Optional: KMS Key ID (ARN or ID) when ServerSideEncryption is "aws:kms"
 	SSEKMSKeyId string
+	// Optional: 32 byte key to encrypt the object with (SSE-C). The
+	// same key must be passed to UploadPart.
+	SSECustomerKey []byte
+
+	// Optional: checksum algorithm of the parts (e.g. ChecksumCRC32C),
+	// and ChecksumTypeComposite (default) or ChecksumTypeFullObject
+	ChecksumAlgorithm string
+	ChecksumType      string
+
+	// Optional: Object Lock retention and legal hold of the object
+	ObjectLockMode            string
+	ObjectLockRetainUntilDate time.Time
+	ObjectLockLegalHoldStatus string
 }
 
 // InitiateMultipartUploadOutput contains the response from initiating a multipart upload
@@ -59,6 +93,11 @@ type initiateMultipartUploadResult struct {
 
 // InitiateMultipartUpload initiates a multipart upload and returns an upload ID
 func (s3 *S3) InitiateMultipartUpload(input InitiateMultipartUploadInput) (InitiateMultipartUploadOutput, error) {
+	return s3.InitiateMultipartUploadWithContext(context.Background(), input)
+}
+
+// InitiateMultipartUploadWithContext is like InitiateMultipartUpload but uses ctx for the request.
+func (s3 *S3) InitiateMultipartUploadWithContext(ctx context.Context, input InitiateMultipartUploadInput) (InitiateMultipartUploadOutput, error) {
 	if input.Bucket == "" {
 		return InitiateMultipartUploadOutput{}, fmt.Errorf("bucket name is required")
 	}
@@ -66,13 +105,9 @@ func (s3 *S3) InitiateMultipartUpload(input InitiateMultipartUploadInput) (Initi
 		return InitiateMultipartUploadOutput{}, fmt.Errorf("object key is required")
 	}
 
-	if err := s3.renewIAMToken(); err != nil {
-		return InitiateMultipartUploadOutput{}, err
-	}
-
 	urlStr := s3.getURL(input.Bucket, input.ObjectKey) + "?uploads"
 
-	req, err := http.NewRequest(http.MethodPost, urlStr, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlStr, nil)
 	if err != nil {
 		return InitiateMultipartUploadOutput{}, err
 	}
@@ -81,10 +116,21 @@ func (s3 *S3) InitiateMultipartUpload(input InitiateMultipartUploadInput) (Initi
 	if input.ContentType != "" {
 		req.Header.Set("Content-Type", input.ContentType)
 	}
+	if input.ContentEncoding != "" {
+		req.Header.Set("Content-Encoding", input.ContentEncoding)
+	}
+	if input.StorageClass != "" {
+		req.Header.Set("x-amz-storage-class", input.StorageClass)
+	}
+	if input.ContentDisposition != "" {
+		req.Header.Set("Content-Disposition", input.ContentDisposition)
+	}
+	setObjectHeaders(req.Header, input.CacheControl, input.ContentLanguage, input.Expires, input.WebsiteRedirectLocation)
 
 	if input.ACL != "" {
 		req.Header.Set("x-amz-acl", input.ACL)
 	}
+	setGrantHeaders(req.Header, input.GrantRead, input.GrantReadACP, input.GrantWriteACP, input.GrantFullControl)
 
 	// Set server-side encryption
 	if input.ServerSideEncryption != "" {
@@ -93,6 +139,18 @@ func (s3 *S3) InitiateMultipartUpload(input InitiateMultipartUploadInput) (Initi
 	if input.SSEKMSKeyId != "" {
 		req.Header.Set("x-amz-server-side-encryption-aws-kms-key-id", input.SSEKMSKeyId)
 	}
+	if err := setSSECustomerKey(req.Header, input.SSECustomerKey); err != nil {
+		return InitiateMultipartUploadOutput{}, err
+	}
+
+	if input.ChecksumAlgorithm != "" {
+		req.Header.Set("x-amz-checksum-algorithm", input.ChecksumAlgorithm)
+	}
+	if input.ChecksumType != "" {
+		req.Header.Set("x-amz-checksum-type", input.ChecksumType)
+	}
+
+	setObjectLockHeaders(req.Header, input.ObjectLockMode, input.ObjectLockRetainUntilDate, input.ObjectLockLegalHoldStatus)
 
 	// Set custom metadata
 	for k, v := range input.CustomMetadata {
@@ -102,12 +160,7 @@ func (s3 *S3) InitiateMultipartUpload(input InitiateMultipartUploadInput) (Initi
 	// Empty body hash for POST with no body
 	req.Header.Set("x-amz-content-sha256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
 
-	if err := s3.signRequest(req); err != nil {
-		return InitiateMultipartUploadOutput{}, err
-	}
-
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return InitiateMultipartUploadOutput{}, err
 	}
@@ -122,7 +175,7 @@ func (s3 *S3) InitiateMultipartUpload(input InitiateMultipartUploadInput) (Initi
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return InitiateMultipartUploadOutput{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return InitiateMultipartUploadOutput{}, newResponseError("InitiateMultipartUpload", input.Bucket, input.ObjectKey, res, body)
 	}
 
 	var result initiateMultipartUploadResult
@@ -143,18 +196,31 @@ type UploadPartInput struct {
 	ObjectKey  string    // Required: object key
 	UploadID   string    // Required: upload ID from InitiateMultipartUpload
 	PartNumber int       // Required: part number (1-10000)
-	Body       io.Reader // Required: part data
+	Body       io.Reader // Required: part data, retried only if it is an io.ReadSeeker
 	Size       int64     // Required: size of part for Content-Length
+
+	// Optional: checksum algorithm of the part, required if the upload
+	// was initiated with one
+	ChecksumAlgorithm string
+
+	// Optional: SSE-C key the upload was initiated with
+	SSECustomerKey []byte
 }
 
 // UploadPartOutput contains the response from uploading a part
 type UploadPartOutput struct {
 	ETag       string // Required for CompleteMultipartUpload
 	PartNumber int
+	Checksum   string // Checksum of the part, if ChecksumAlgorithm was set
 }
 
 // UploadPart uploads a single part for a multipart upload
 func (s3 *S3) UploadPart(input UploadPartInput) (UploadPartOutput, error) {
+	return s3.UploadPartWithContext(context.Background(), input)
+}
+
+// UploadPartWithContext is like UploadPart but uses ctx for the request.
+func (s3 *S3) UploadPartWithContext(ctx context.Context, input UploadPartInput) (UploadPartOutput, error) {
 	if input.Bucket == "" {
 		return UploadPartOutput{}, fmt.Errorf("bucket name is required")
 	}
@@ -170,12 +236,16 @@ func (s3 *S3) UploadPart(input UploadPartInput) (UploadPartOutput, error) {
 	if input.Body == nil {
 		return UploadPartOutput{}, fmt.Errorf("body is required")
 	}
-	if input.Size <= 0 {
-		return UploadPartOutput{}, fmt.Errorf("size must be greater than 0")
+	if input.Size < 0 {
+		return UploadPartOutput{}, fmt.Errorf("size must not be negative")
 	}
 
-	if err := s3.renewIAMToken(); err != nil {
-		return UploadPartOutput{}, err
+	var sum hash.Hash
+	if input.ChecksumAlgorithm != "" {
+		var err error
+		if sum, err = newChecksum(input.ChecksumAlgorithm); err != nil {
+			return UploadPartOutput{}, err
+		}
 	}
 
 	// Build URL with query parameters
@@ -185,29 +255,51 @@ func (s3 *S3) UploadPart(input UploadPartInput) (UploadPartOutput, error) {
 	params.Set("uploadId", input.UploadID)
 	urlStr += "?" + params.Encode()
 
-	// Read body and compute SHA256
-	content, err := io.ReadAll(input.Body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, urlStr, nil)
 	if err != nil {
 		return UploadPartOutput{}, err
 	}
-
-	req, err := http.NewRequest(http.MethodPut, urlStr, bytes.NewReader(content))
-	if err != nil {
+	if err := setSSECustomerKey(req.Header, input.SSECustomerKey); err != nil {
 		return UploadPartOutput{}, err
 	}
 
-	// Set headers
-	req.ContentLength = input.Size
-	h := sha256.New()
-	h.Write(content)
-	req.Header.Set("x-amz-content-sha256", fmt.Sprintf("%x", h.Sum(nil)))
-
-	if err := s3.signRequest(req); err != nil {
-		return UploadPartOutput{}, err
+	if input.Size == 0 {
+		// Only the last part, or the single part of an empty
+		// object, may be empty.
+		req.Body = http.NoBody
+		req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+		if sum != nil {
+			req.Header.Set(checksumHeader(input.ChecksumAlgorithm), encodeChecksum(sum))
+		}
+	} else if body, ok := input.Body.(io.ReadSeeker); ok {
+		// Hash the part in a first pass, then stream it. The body
+		// can be rewound when the request is retried.
+		payloadHash, err := hashReadSeeker(body, sum)
+		if err != nil {
+			return UploadPartOutput{}, err
+		}
+		if err := setSeekableBody(req, body); err != nil {
+			return UploadPartOutput{}, err
+		}
+		req.ContentLength = input.Size
+		req.Header.Set("x-amz-content-sha256", payloadHash)
+		if sum != nil {
+			req.Header.Set(checksumHeader(input.ChecksumAlgorithm), encodeChecksum(sum))
+		}
+	} else {
+		// Stream the part using signed chunks. The body can't be
+		// rewound, so the request is sent only once.
+		req.Body = io.NopCloser(input.Body)
+		trailer := ""
+		if sum != nil {
+			trailer = checksumHeader(input.ChecksumAlgorithm)
+		}
+		if err := setStreamingPayload(req, input.Size, trailer); err != nil {
+			return UploadPartOutput{}, err
+		}
 	}
 
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return UploadPartOutput{}, err
 	}
@@ -222,85 +314,180 @@ func (s3 *S3) UploadPart(input UploadPartInput) (UploadPartOutput, error) {
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return UploadPartOutput{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return UploadPartOutput{}, newResponseError("UploadPart", input.Bucket, input.ObjectKey, res, body)
 	}
 
 	etag := res.Header.Get("ETag")
 	if etag == "" {
 		return UploadPartOutput{}, fmt.Errorf("ETag not found in response")
 	}
+	_, checksum := responseChecksum(res.Header)
+	if checksum == "" && sum != nil && !isStreamingPayload(req) {
+		checksum = encodeChecksum(sum)
+	}
 
 	return UploadPartOutput{
 		ETag:       etag,
 		PartNumber: input.PartNumber,
+		Checksum:   checksum,
 	}, nil
 }
 
-// uploadPartWithRetry uploads a part with retry logic
-func (s3 *S3) uploadPartWithRetry(input UploadPartInput, maxRetries int) (UploadPartOutput, error) {
+// uploadPartWithRetry uploads a part, retrying transient failures up to
+// maxRetries times using the client's retry policy backoff.
+func (s3 *S3) uploadPartWithRetry(ctx context.Context, input UploadPartInput, maxRetries int) (UploadPartOutput, error) {
+	return s3.UploadPartWithContext(s3.withMaxRetries(ctx, maxRetries), input)
+}
+
+// withMaxRetries returns a copy of ctx whose retry policy retries
+// transient failures up to maxRetries times, using the client's
+// retry policy backoff.
+func (s3 *S3) withMaxRetries(ctx context.Context, maxRetries int) context.Context {
 	if maxRetries <= 0 {
 		maxRetries = DefaultMaxRetries
 	}
 
-	var lastErr error
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff
-			waitTime := time.Duration(math.Pow(2, float64(attempt-1))) * DefaultRetryBaseWait
-			if waitTime > DefaultRetryMaxWait {
-				waitTime = DefaultRetryMaxWait
-			}
-			time.Sleep(waitTime)
-		}
+	policy := s3.getRetryPolicy(ctx)
+	policy.MaxAttempts = maxRetries + 1
+	return withRetryPolicy(ctx, policy)
+}
 
-		output, err := s3.UploadPart(input)
-		if err == nil {
-			return output, nil
-		}
+// UploadPartCopyInput contains parameters for copying a part from an existing object
+type UploadPartCopyInput struct {
+	Bucket       string // Required: bucket name
+	ObjectKey    string // Required: object key
+	UploadID     string // Required: upload ID from InitiateMultipartUpload
+	PartNumber   int    // Required: part number (1-10000)
+	SourceBucket string // Required: source bucket name
+	SourceKey    string // Required: source object key
+
+	// Optional: byte range of the source to copy, as first and last
+	// byte (inclusive). The whole source is copied if both are 0.
+	SourceFirstByte int64
+	SourceLastByte  int64
+
+	// Optional: copy only if the source matches these conditions
+	CopySourceIfMatch           string
+	CopySourceIfNoneMatch       string
+	CopySourceIfModifiedSince   time.Time
+	CopySourceIfUnmodifiedSince time.Time
+
+	// Optional: SSE-C key the upload was initiated with, and the one
+	// the source is encrypted with
+	SSECustomerKey           []byte
+	CopySourceSSECustomerKey []byte
+}
 
-		lastErr = err
+// UploadPartCopyOutput contains the response from copying a part
+type UploadPartCopyOutput struct {
+	ETag         string
+	LastModified time.Time
+	PartNumber   int
+}
 
-		// Check if error is retryable
-		if !isRetryableError(err) {
-			return UploadPartOutput{}, err
-		}
+// UploadPartCopy uploads a part by copying data from an existing object
+func (s3 *S3) UploadPartCopy(input UploadPartCopyInput) (UploadPartCopyOutput, error) {
+	return s3.UploadPartCopyWithContext(context.Background(), input)
+}
+
+// UploadPartCopyWithContext is like UploadPartCopy but uses ctx for the request.
+func (s3 *S3) UploadPartCopyWithContext(ctx context.Context, input UploadPartCopyInput) (UploadPartCopyOutput, error) {
+	if input.Bucket == "" {
+		return UploadPartCopyOutput{}, fmt.Errorf("bucket name is required")
+	}
+	if input.ObjectKey == "" {
+		return UploadPartCopyOutput{}, fmt.Errorf("object key is required")
+	}
+	if input.UploadID == "" {
+		return UploadPartCopyOutput{}, fmt.Errorf("upload ID is required")
+	}
+	if input.PartNumber < 1 || input.PartNumber > MaxParts {
+		return UploadPartCopyOutput{}, fmt.Errorf("part number must be between 1 and %d", MaxParts)
+	}
+	if input.SourceBucket == "" || input.SourceKey == "" {
+		return UploadPartCopyOutput{}, fmt.Errorf("source bucket and key are required")
+	}
+	if input.SourceFirstByte < 0 || input.SourceLastByte < input.SourceFirstByte {
+		return UploadPartCopyOutput{}, fmt.Errorf("invalid source range %d-%d", input.SourceFirstByte, input.SourceLastByte)
 	}
 
-	return UploadPartOutput{}, fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
-}
+	// Build URL with query parameters
+	urlStr := s3.getURL(input.Bucket, input.ObjectKey)
+	params := url.Values{}
+	params.Set("partNumber", strconv.Itoa(input.PartNumber))
+	params.Set("uploadId", input.UploadID)
+	urlStr += "?" + params.Encode()
 
-// isRetryableError determines if an error should be retried
-func isRetryableError(err error) bool {
-	if err == nil {
-		return false
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, urlStr, nil)
+	if err != nil {
+		return UploadPartCopyOutput{}, err
 	}
 
-	errStr := err.Error()
-	// Retry on 5xx errors, timeouts, and connection resets
-	return contains(errStr, "status code: 5") ||
-		contains(errStr, "timeout") ||
-		contains(errStr, "connection reset") ||
-		contains(errStr, "EOF")
-}
+	req.Header.Set("x-amz-copy-source", "/"+input.SourceBucket+"/"+encodePath(input.SourceKey))
+	if input.SourceLastByte > 0 {
+		req.Header.Set("x-amz-copy-source-range", fmt.Sprintf("bytes=%d-%d", input.SourceFirstByte, input.SourceLastByte))
+	}
+	if input.CopySourceIfMatch != "" {
+		req.Header.Set("x-amz-copy-source-if-match", input.CopySourceIfMatch)
+	}
+	if input.CopySourceIfNoneMatch != "" {
+		req.Header.Set("x-amz-copy-source-if-none-match", input.CopySourceIfNoneMatch)
+	}
+	if !input.CopySourceIfModifiedSince.IsZero() {
+		req.Header.Set("x-amz-copy-source-if-modified-since", input.CopySourceIfModifiedSince.UTC().Format(http.TimeFormat))
+	}
+	if !input.CopySourceIfUnmodifiedSince.IsZero() {
+		req.Header.Set("x-amz-copy-source-if-unmodified-since", input.CopySourceIfUnmodifiedSince.UTC().Format(http.TimeFormat))
+	}
+	if err := setSSECustomerKey(req.Header, input.SSECustomerKey); err != nil {
+		return UploadPartCopyOutput{}, err
+	}
+	if err := setCopySourceSSECustomerKey(req.Header, input.CopySourceSSECustomerKey); err != nil {
+		return UploadPartCopyOutput{}, err
+	}
 
-// contains checks if a string contains a substring
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || containsMiddle(s, substr)))
-}
+	// Empty body hash
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
 
-func containsMiddle(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
+	res, err := s3.do(req)
+	if err != nil {
+		return UploadPartCopyOutput{}, err
+	}
+	defer func() {
+		res.Body.Close()
+		io.Copy(io.Discard, res.Body)
+	}()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return UploadPartCopyOutput{}, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return UploadPartCopyOutput{}, newResponseError("UploadPartCopy", input.Bucket, input.ObjectKey, res, body)
+	}
+
+	var result struct {
+		XMLName      xml.Name  `xml:"CopyPartResult"`
+		ETag         string    `xml:"ETag"`
+		LastModified time.Time `xml:"LastModified"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return UploadPartCopyOutput{}, err
 	}
-	return false
+
+	return UploadPartCopyOutput{
+		ETag:         result.ETag,
+		LastModified: result.LastModified,
+		PartNumber:   input.PartNumber,
+	}, nil
 }
 
 // CompletedPart represents a part that has been uploaded
 type CompletedPart struct {
 	PartNumber int
 	ETag       string
+	Checksum   string // Required if the upload has a checksum algorithm
 }
 
 // CompleteMultipartUploadInput contains parameters for completing a multipart upload
@@ -309,6 +496,19 @@ type CompleteMultipartUploadInput struct {
 	ObjectKey string          // Required: object key
 	UploadID  string          // Required: upload ID
 	Parts     []CompletedPart // Required: list of parts, ordered by PartNumber
+
+	// Optional: Conditional write. IfNoneMatch "*" only completes the
+	// upload if the object doesn't exist, IfMatch only if its ETag matches.
+	IfNoneMatch string
+	IfMatch     string
+
+	// Optional: checksum algorithm and type the upload was initiated
+	// with. ChecksumAlgorithm is required if the parts have checksums.
+	ChecksumAlgorithm string
+	ChecksumType      string
+	// Optional: checksum of the whole object, checked by S3 if
+	// ChecksumType is ChecksumTypeFullObject
+	Checksum string
 }
 
 // CompleteMultipartUploadOutput contains the response from completing a multipart upload
@@ -317,6 +517,11 @@ type CompleteMultipartUploadOutput struct {
 	Bucket   string
 	Key      string
 	ETag     string
+
+	// Checksum of the object, if the upload has a checksum algorithm,
+	// and whether it is a COMPOSITE or FULL_OBJECT checksum
+	Checksum     string
+	ChecksumType string
 }
 
 // completeMultipartUploadRequest is the XML request structure
@@ -329,6 +534,7 @@ type completeMultipartUploadRequest struct {
 type completePart struct {
 	PartNumber int    `xml:"PartNumber"`
 	ETag       string `xml:"ETag"`
+	checksums
 }
 
 // completeMultipartUploadResult is the XML response structure
@@ -338,10 +544,17 @@ type completeMultipartUploadResult struct {
 	Bucket   string   `xml:"Bucket"`
 	Key      string   `xml:"Key"`
 	ETag     string   `xml:"ETag"`
+	checksums
+	ChecksumType string `xml:"ChecksumType"`
 }
 
 // CompleteMultipartUpload completes a multipart upload
 func (s3 *S3) CompleteMultipartUpload(input CompleteMultipartUploadInput) (CompleteMultipartUploadOutput, error) {
+	return s3.CompleteMultipartUploadWithContext(context.Background(), input)
+}
+
+// CompleteMultipartUploadWithContext is like CompleteMultipartUpload but uses ctx for the request.
+func (s3 *S3) CompleteMultipartUploadWithContext(ctx context.Context, input CompleteMultipartUploadInput) (CompleteMultipartUploadOutput, error) {
 	if input.Bucket == "" {
 		return CompleteMultipartUploadOutput{}, fmt.Errorf("bucket name is required")
 	}
@@ -354,9 +567,10 @@ func (s3 *S3) CompleteMultipartUpload(input CompleteMultipartUploadInput) (Compl
 	if len(input.Parts) == 0 {
 		return CompleteMultipartUploadOutput{}, fmt.Errorf("parts list cannot be empty")
 	}
-
-	if err := s3.renewIAMToken(); err != nil {
-		return CompleteMultipartUploadOutput{}, err
+	if input.ChecksumAlgorithm != "" {
+		if _, err := newChecksum(input.ChecksumAlgorithm); err != nil {
+			return CompleteMultipartUploadOutput{}, err
+		}
 	}
 
 	// Build URL with query parameter
@@ -368,6 +582,7 @@ func (s3 *S3) CompleteMultipartUpload(input CompleteMultipartUploadInput) (Compl
 		parts[i] = completePart{
 			PartNumber: p.PartNumber,
 			ETag:       p.ETag,
+			checksums:  newChecksums(input.ChecksumAlgorithm, p.Checksum),
 		}
 	}
 
@@ -381,7 +596,7 @@ func (s3 *S3) CompleteMultipartUpload(input CompleteMultipartUploadInput) (Compl
 		return CompleteMultipartUploadOutput{}, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, urlStr, bytes.NewReader(xmlBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlStr, bytes.NewReader(xmlBody))
 	if err != nil {
 		return CompleteMultipartUploadOutput{}, err
 	}
@@ -399,12 +614,21 @@ func (s3 *S3) CompleteMultipartUpload(input CompleteMultipartUploadInput) (Compl
 	contentMD5 := base64.StdEncoding.EncodeToString(md5Hash[:])
 	req.Header.Set("Content-MD5", contentMD5)
 
-	if err := s3.signRequest(req); err != nil {
-		return CompleteMultipartUploadOutput{}, err
+	if input.IfNoneMatch != "" {
+		req.Header.Set("If-None-Match", input.IfNoneMatch)
+	}
+	if input.IfMatch != "" {
+		req.Header.Set("If-Match", input.IfMatch)
+	}
+
+	if input.ChecksumType != "" {
+		req.Header.Set("x-amz-checksum-type", input.ChecksumType)
+	}
+	if input.Checksum != "" && input.ChecksumAlgorithm != "" {
+		req.Header.Set(checksumHeader(input.ChecksumAlgorithm), input.Checksum)
 	}
 
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return CompleteMultipartUploadOutput{}, err
 	}
@@ -419,7 +643,7 @@ func (s3 *S3) CompleteMultipartUpload(input CompleteMultipartUploadInput) (Compl
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return CompleteMultipartUploadOutput{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return CompleteMultipartUploadOutput{}, newResponseError("CompleteMultipartUpload", input.Bucket, input.ObjectKey, res, body)
 	}
 
 	var result completeMultipartUploadResult
@@ -428,10 +652,12 @@ func (s3 *S3) CompleteMultipartUpload(input CompleteMultipartUploadInput) (Compl
 	}
 
 	return CompleteMultipartUploadOutput{
-		Location: result.Location,
-		Bucket:   result.Bucket,
-		Key:      result.Key,
-		ETag:     result.ETag,
+		Location:     result.Location,
+		Bucket:       result.Bucket,
+		Key:          result.Key,
+		ETag:         result.ETag,
+		Checksum:     result.value(),
+		ChecksumType: result.ChecksumType,
 	}, nil
 }
 
@@ -444,6 +670,11 @@ type AbortMultipartUploadInput struct {
 
 // AbortMultipartUpload aborts a multipart upload and cleans up parts
 func (s3 *S3) AbortMultipartUpload(input AbortMultipartUploadInput) error {
+	return s3.AbortMultipartUploadWithContext(context.Background(), input)
+}
+
+// AbortMultipartUploadWithContext is like AbortMultipartUpload but uses ctx for the request.
+func (s3 *S3) AbortMultipartUploadWithContext(ctx context.Context, input AbortMultipartUploadInput) error {
 	if input.Bucket == "" {
 		return fmt.Errorf("bucket name is required")
 	}
@@ -454,14 +685,10 @@ func (s3 *S3) AbortMultipartUpload(input AbortMultipartUploadInput) error {
 		return fmt.Errorf("upload ID is required")
 	}
 
-	if err := s3.renewIAMToken(); err != nil {
-		return err
-	}
-
 	// Build URL with query parameter
 	urlStr := s3.getURL(input.Bucket, input.ObjectKey) + "?uploadId=" + url.QueryEscape(input.UploadID)
 
-	req, err := http.NewRequest(http.MethodDelete, urlStr, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, urlStr, nil)
 	if err != nil {
 		return err
 	}
@@ -469,12 +696,7 @@ func (s3 *S3) AbortMultipartUpload(input AbortMultipartUploadInput) error {
 	// Empty body hash
 	req.Header.Set("x-amz-content-sha256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
 
-	if err := s3.signRequest(req); err != nil {
-		return err
-	}
-
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return err
 	}
@@ -489,7 +711,7 @@ func (s3 *S3) AbortMultipartUpload(input AbortMultipartUploadInput) error {
 	}
 
 	if res.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return newResponseError("AbortMultipartUpload", input.Bucket, input.ObjectKey, res, body)
 	}
 
 	return nil
@@ -510,6 +732,7 @@ type Part struct {
 	ETag         string
 	Size         int64
 	LastModified time.Time
+	Checksum     string // Checksum of the part, if the upload has a checksum algorithm
 }
 
 // ListPartsOutput contains the response from listing parts
@@ -540,10 +763,16 @@ type partInfo struct {
 	ETag         string    `xml:"ETag"`
 	Size         int64     `xml:"Size"`
 	LastModified time.Time `xml:"LastModified"`
+	checksums
 }
 
 // ListParts lists the parts that have been uploaded for a multipart upload
 func (s3 *S3) ListParts(input ListPartsInput) (ListPartsOutput, error) {
+	return s3.ListPartsWithContext(context.Background(), input)
+}
+
+// ListPartsWithContext is like ListParts but uses ctx for the request.
+func (s3 *S3) ListPartsWithContext(ctx context.Context, input ListPartsInput) (ListPartsOutput, error) {
 	if input.Bucket == "" {
 		return ListPartsOutput{}, fmt.Errorf("bucket name is required")
 	}
@@ -554,10 +783,6 @@ func (s3 *S3) ListParts(input ListPartsInput) (ListPartsOutput, error) {
 		return ListPartsOutput{}, fmt.Errorf("upload ID is required")
 	}
 
-	if err := s3.renewIAMToken(); err != nil {
-		return ListPartsOutput{}, err
-	}
-
 	// Build URL with query parameters
 	urlStr := s3.getURL(input.Bucket, input.ObjectKey)
 	params := url.Values{}
@@ -570,7 +795,7 @@ func (s3 *S3) ListParts(input ListPartsInput) (ListPartsOutput, error) {
 	}
 	urlStr += "?" + params.Encode()
 
-	req, err := http.NewRequest(http.MethodGet, urlStr, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
 	if err != nil {
 		return ListPartsOutput{}, err
 	}
@@ -578,12 +803,7 @@ func (s3 *S3) ListParts(input ListPartsInput) (ListPartsOutput, error) {
 	// Empty body hash
 	req.Header.Set("x-amz-content-sha256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
 
-	if err := s3.signRequest(req); err != nil {
-		return ListPartsOutput{}, err
-	}
-
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return ListPartsOutput{}, err
 	}
@@ -598,7 +818,7 @@ func (s3 *S3) ListParts(input ListPartsInput) (ListPartsOutput, error) {
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return ListPartsOutput{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return ListPartsOutput{}, newResponseError("ListParts", input.Bucket, input.ObjectKey, res, body)
 	}
 
 	var result listPartsResult
@@ -613,6 +833,7 @@ func (s3 *S3) ListParts(input ListPartsInput) (ListPartsOutput, error) {
 			ETag:         p.ETag,
 			Size:         p.Size,
 			LastModified: p.LastModified,
+			Checksum:     p.value(),
 		}
 	}
 
@@ -627,15 +848,276 @@ func (s3 *S3) ListParts(input ListPartsInput) (ListPartsOutput, error) {
 	}, nil
 }
 
-// ProgressFunc is called during upload with progress information
+// ListMultipartUploadsInput contains parameters for listing in-progress multipart uploads
+type ListMultipartUploadsInput struct {
+	Bucket         string // Required: bucket name
+	Prefix         string // Optional: only list uploads of keys starting with this prefix
+	Delimiter      string // Optional: group keys by delimiter (commonly "/")
+	MaxUploads     int    // Optional: default 1000, max 1000
+	KeyMarker      string // Optional: for pagination
+	UploadIDMarker string // Optional: for pagination, used with KeyMarker
+}
+
+// MultipartUpload represents an in-progress multipart upload
+type MultipartUpload struct {
+	Key          string
+	UploadID     string
+	Initiated    time.Time
+	StorageClass string
+}
+
+// ListMultipartUploadsOutput contains the response from listing multipart uploads
+type ListMultipartUploadsOutput struct {
+	Bucket             string
+	Uploads            []MultipartUpload
+	CommonPrefixes     []string
+	IsTruncated        bool
+	NextKeyMarker      string
+	NextUploadIDMarker string
+	MaxUploads         int
+}
+
+// listMultipartUploadsResult is the XML response structure
+type listMultipartUploadsResult struct {
+	XMLName            xml.Name `xml:"ListMultipartUploadsResult"`
+	Bucket             string   `xml:"Bucket"`
+	IsTruncated        bool     `xml:"IsTruncated"`
+	NextKeyMarker      string   `xml:"NextKeyMarker"`
+	NextUploadIDMarker string   `xml:"NextUploadIdMarker"`
+	MaxUploads         int      `xml:"MaxUploads"`
+	Uploads            []struct {
+		Key          string    `xml:"Key"`
+		UploadID     string    `xml:"UploadId"`
+		Initiated    time.Time `xml:"Initiated"`
+		StorageClass string    `xml:"StorageClass"`
+	} `xml:"Upload"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+// ListMultipartUploads lists the multipart uploads in progress in a bucket,
+// i.e. uploads that have been initiated but not completed or aborted
+func (s3 *S3) ListMultipartUploads(input ListMultipartUploadsInput) (ListMultipartUploadsOutput, error) {
+	return s3.ListMultipartUploadsWithContext(context.Background(), input)
+}
+
+// ListMultipartUploadsWithContext is like ListMultipartUploads but uses ctx for the request.
+func (s3 *S3) ListMultipartUploadsWithContext(ctx context.Context, input ListMultipartUploadsInput) (ListMultipartUploadsOutput, error) {
+	if input.Bucket == "" {
+		return ListMultipartUploadsOutput{}, fmt.Errorf("bucket name is required")
+	}
+	if input.MaxUploads < 0 || input.MaxUploads > 1000 {
+		return ListMultipartUploadsOutput{}, fmt.Errorf("max uploads must be between 0 and 1000")
+	}
+
+	// Build URL with query parameters
+	urlStr := s3.getURL(input.Bucket)
+	params := url.Values{}
+	params.Set("uploads", "")
+	if input.Prefix != "" {
+		params.Set("prefix", input.Prefix)
+	}
+	if input.Delimiter != "" {
+		params.Set("delimiter", input.Delimiter)
+	}
+	if input.MaxUploads > 0 {
+		params.Set("max-uploads", strconv.Itoa(input.MaxUploads))
+	}
+	if input.KeyMarker != "" {
+		params.Set("key-marker", input.KeyMarker)
+	}
+	if input.UploadIDMarker != "" {
+		params.Set("upload-id-marker", input.UploadIDMarker)
+	}
+	urlStr += "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return ListMultipartUploadsOutput{}, err
+	}
+
+	// Empty body hash
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+
+	res, err := s3.do(req)
+	if err != nil {
+		return ListMultipartUploadsOutput{}, err
+	}
+	defer func() {
+		res.Body.Close()
+		io.Copy(io.Discard, res.Body)
+	}()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return ListMultipartUploadsOutput{}, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return ListMultipartUploadsOutput{}, newResponseError("ListMultipartUploads", input.Bucket, "", res, body)
+	}
+
+	var result listMultipartUploadsResult
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return ListMultipartUploadsOutput{}, err
+	}
+
+	output := ListMultipartUploadsOutput{
+		Bucket:             result.Bucket,
+		Uploads:            make([]MultipartUpload, len(result.Uploads)),
+		IsTruncated:        result.IsTruncated,
+		NextKeyMarker:      result.NextKeyMarker,
+		NextUploadIDMarker: result.NextUploadIDMarker,
+		MaxUploads:         result.MaxUploads,
+	}
+	for i, u := range result.Uploads {
+		output.Uploads[i] = MultipartUpload{
+			Key:          u.Key,
+			UploadID:     u.UploadID,
+			Initiated:    u.Initiated,
+			StorageClass: u.StorageClass,
+		}
+	}
+	for _, p := range result.CommonPrefixes {
+		output.CommonPrefixes = append(output.CommonPrefixes, p.Prefix)
+	}
+
+	return output, nil
+}
+
+// ListAllMultipartUploads returns an iterator that yields all multipart
+// uploads in progress in the bucket, automatically handling pagination.
+// It also returns a finish callback that should be called after iteration
+// to check for any errors.
+func (s3 *S3) ListAllMultipartUploads(input ListMultipartUploadsInput) (iter.Seq[MultipartUpload], func() error) {
+	return s3.ListAllMultipartUploadsWithContext(context.Background(), input)
+}
+
+// ListAllMultipartUploadsWithContext is like ListAllMultipartUploads but uses ctx for every page request.
+func (s3 *S3) ListAllMultipartUploadsWithContext(ctx context.Context, input ListMultipartUploadsInput) (iter.Seq[MultipartUpload], func() error) {
+	var iterErr error
+
+	seq := func(yield func(MultipartUpload) bool) {
+		currentInput := input
+
+		for {
+			output, err := s3.ListMultipartUploadsWithContext(ctx, currentInput)
+			if err != nil {
+				iterErr = err
+				return
+			}
+
+			for _, upload := range output.Uploads {
+				if !yield(upload) {
+					return // Early termination requested
+				}
+			}
+
+			if !output.IsTruncated || (output.NextKeyMarker == "" && output.NextUploadIDMarker == "") {
+				return // No more results
+			}
+
+			currentInput.KeyMarker = output.NextKeyMarker
+			currentInput.UploadIDMarker = output.NextUploadIDMarker
+		}
+	}
+
+	finish := func() error {
+		return iterErr
+	}
+
+	return seq, finish
+}
+
+// AbortStaleMultipartUploadsInput contains parameters for aborting stale multipart uploads
+type AbortStaleMultipartUploadsInput struct {
+	Bucket    string        // Required: bucket name
+	Prefix    string        // Optional: only abort uploads of keys starting with this prefix
+	OlderThan time.Duration // Required: abort uploads initiated longer ago than this
+	DryRun    bool          // Optional: only report the uploads that would be aborted
+}
+
+// AbortStaleMultipartUploadsOutput reports the stale multipart uploads
+type AbortStaleMultipartUploadsOutput struct {
+	// Uploads that were aborted, or would be in a dry run
+	Uploads []MultipartUpload
+	// Failed holds the uploads that could not be aborted, with the error
+	Failed map[string]error
+}
+
+// AbortStaleMultipartUploads aborts the multipart uploads in a bucket that
+// were initiated longer ago than OlderThan, such as those left behind by
+// crashed jobs, whose parts are billed as storage until they are aborted.
+// With DryRun set, the stale uploads are only reported. An upload that
+// fails to be aborted doesn't stop the others: its error is reported in
+// Failed, keyed by upload ID, and the first one is returned.
+func (s3 *S3) AbortStaleMultipartUploads(input AbortStaleMultipartUploadsInput) (AbortStaleMultipartUploadsOutput, error) {
+	return s3.AbortStaleMultipartUploadsWithContext(context.Background(), input)
+}
+
+// AbortStaleMultipartUploadsWithContext is like AbortStaleMultipartUploads but uses ctx for the requests.
+func (s3 *S3) AbortStaleMultipartUploadsWithContext(ctx context.Context, input AbortStaleMultipartUploadsInput) (AbortStaleMultipartUploadsOutput, error) {
+	if input.Bucket == "" {
+		return AbortStaleMultipartUploadsOutput{}, fmt.Errorf("bucket name is required")
+	}
+	if input.OlderThan <= 0 {
+		return AbortStaleMultipartUploadsOutput{}, fmt.Errorf("age must be greater than 0")
+	}
+
+	var (
+		output   AbortStaleMultipartUploadsOutput
+		firstErr error
+		cutoff   = time.Now().Add(-input.OlderThan)
+	)
+
+	uploads, finish := s3.ListAllMultipartUploadsWithContext(ctx, ListMultipartUploadsInput{
+		Bucket: input.Bucket,
+		Prefix: input.Prefix,
+	})
+	for upload := range uploads {
+		if !upload.Initiated.Before(cutoff) {
+			continue
+		}
+		if input.DryRun {
+			output.Uploads = append(output.Uploads, upload)
+			continue
+		}
+
+		err := s3.AbortMultipartUploadWithContext(ctx, AbortMultipartUploadInput{
+			Bucket:    input.Bucket,
+			ObjectKey: upload.Key,
+			UploadID:  upload.UploadID,
+		})
+		// Uploads completed or aborted since they were listed are gone already.
+		if err != nil && !IsNotFound(err) {
+			if output.Failed == nil {
+				output.Failed = map[string]error{}
+			}
+			output.Failed[upload.UploadID] = err
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		output.Uploads = append(output.Uploads, upload)
+	}
+	if err := finish(); err != nil {
+		return output, err
+	}
+
+	return output, firstErr
+}
+
+// ProgressFunc is called during upload or download with progress information
 type ProgressFunc func(info ProgressInfo)
 
-// ProgressInfo contains progress information for a multipart upload
+// ProgressInfo contains progress information for a multipart upload or parallel download
 type ProgressInfo struct {
-	TotalBytes     int64 // Total bytes to upload (if known)
-	UploadedBytes  int64 // Bytes uploaded so far
+	TotalBytes     int64 // Total bytes to transfer (0 if unknown)
+	UploadedBytes  int64 // Bytes transferred so far
 	CurrentPart    int   // Current part number
-	TotalParts     int   // Total parts (if known)
+	TotalParts     int   // Total parts (0 if unknown)
 	BytesPerSecond int64 // Current upload speed
 }
 
@@ -643,19 +1125,62 @@ type ProgressInfo struct {
 type MultipartUploadInput struct {
 	Bucket         string            // Required: bucket name
 	ObjectKey      string            // Required: object key
-	Body           io.Reader         // Required: file/data to upload
+	Body           io.Reader         // Required: file/data to upload, read part by part
+	Size           int64             // Optional: size of Body, if known (detected for an io.Seeker)
 	ContentType    string            // Optional: content type
 	CustomMetadata map[string]string // Optional: x-amz-meta-* headers
 	ACL            string            // Optional: x-amz-acl
+	StorageClass   string            // Optional: storage class, e.g. "STANDARD_IA"
 	PartSize       int64             // Optional: default 5MB, min 5MB
 	MaxRetries     int               // Optional: default 3
 	Concurrency    int               // Optional: default 1 (sequential)
 	OnProgress     ProgressFunc      // Optional: progress callback
+	Checkpoint     CheckpointStore   // Optional: makes the upload resumable
+
+	// Optional: Standard headers of the object (see UploadInput).
+	// ContentEncoding is set by Compression, and can't be different.
+	ContentDisposition      string
+	CacheControl            string
+	ContentEncoding         string
+	ContentLanguage         string
+	Expires                 time.Time
+	WebsiteRedirectLocation string
+
+	// Optional: Grants of permissions to the object (see UploadInput)
+	GrantRead        string
+	GrantReadACP     string
+	GrantWriteACP    string
+	GrantFullControl string
 
 	// Optional: Server-side encryption (e.g. "AES256" or "aws:kms")
 	ServerSideEncryption string
 	// Optional: KMS Key ID (ARN or ID) when ServerSideEncryption is "aws:kms"
 	SSEKMSKeyId string
+	// Optional: 32 byte key to encrypt the object with (SSE-C)
+	SSECustomerKey []byte
+
+	// Optional: Conditional write, checked when the upload is completed
+	// (see CompleteMultipartUploadInput)
+	IfNoneMatch string
+	IfMatch     string
+
+	// Optional: checksum algorithm of the parts (e.g. ChecksumCRC32C).
+	// With ChecksumTypeFullObject, the checksum of the whole body is
+	// computed as well, and checked by S3 when the upload is completed.
+	ChecksumAlgorithm string
+	ChecksumType      string
+
+	// Optional: Object Lock retention and legal hold of the object.
+	// With a retention, the parts are sent with a checksum, which S3
+	// requires, using ChecksumCRC32 if ChecksumAlgorithm isn't set.
+	ObjectLockMode            string
+	ObjectLockRetainUntilDate time.Time
+	ObjectLockLegalHoldStatus string
+
+	// Optional: compression of the body, e.g. CompressionGzip, recorded
+	// like UploadInput.Compression. The body is compressed as it is
+	// read, so Size is ignored. Checkpoint can't be set.
+	Compression string
 }
 
 // MultipartUploadOutput contains the response from a multipart upload
@@ -665,10 +1190,19 @@ type MultipartUploadOutput struct {
 	Key      string
 	ETag     string
 	UploadID string
+
+	// Checksum of the object, if ChecksumAlgorithm was set
+	Checksum     string
+	ChecksumType string
 }
 
 // FileUploadMultipart handles the entire multipart upload workflow
 func (s3 *S3) FileUploadMultipart(input MultipartUploadInput) (MultipartUploadOutput, error) {
+	return s3.FileUploadMultipartWithContext(context.Background(), input)
+}
+
+// FileUploadMultipartWithContext is like FileUploadMultipart but uses ctx for the request.
+func (s3 *S3) FileUploadMultipartWithContext(ctx context.Context, input MultipartUploadInput) (MultipartUploadOutput, error) {
 	if input.Bucket == "" {
 		return MultipartUploadOutput{}, fmt.Errorf("bucket name is required")
 	}
@@ -679,6 +1213,46 @@ func (s3 *S3) FileUploadMultipart(input MultipartUploadInput) (MultipartUploadOu
 		return MultipartUploadOutput{}, fmt.Errorf("body is required")
 	}
 
+	encoding, err := contentEncoding(input.ContentEncoding, input.Compression)
+	if err != nil {
+		return MultipartUploadOutput{}, err
+	}
+
+	// The compressed body is streamed, as its size isn't known. It
+	// couldn't be resumed, as the parts uploaded earlier are skipped.
+	if input.Compression != "" {
+		if input.Checkpoint != nil {
+			return MultipartUploadOutput{}, fmt.Errorf("resumable uploads can't be compressed")
+		}
+		c, err := compressorFor(input.Compression)
+		if err != nil {
+			return MultipartUploadOutput{}, err
+		}
+		body, err := compressReader(c, input.Body)
+		if err != nil {
+			return MultipartUploadOutput{}, err
+		}
+		defer body.Close()
+		input.Body, input.Size = body, 0
+		input.CustomMetadata = withCompression(input.CustomMetadata, input.Compression)
+	}
+
+	if input.ObjectLockMode != "" && input.ChecksumAlgorithm == "" {
+		input.ChecksumAlgorithm = ChecksumCRC32
+	}
+
+	// The checksum of the whole body is computed as it is read.
+	var fullChecksum hash.Hash
+	if input.ChecksumAlgorithm != "" {
+		sum, err := newChecksum(input.ChecksumAlgorithm)
+		if err != nil {
+			return MultipartUploadOutput{}, err
+		}
+		if input.ChecksumType == ChecksumTypeFullObject {
+			fullChecksum = sum
+		}
+	}
+
 	// Set defaults
 	partSize := input.PartSize
 	if partSize == 0 {
@@ -698,278 +1272,718 @@ func (s3 *S3) FileUploadMultipart(input MultipartUploadInput) (MultipartUploadOu
 		concurrency = 1
 	}
 
-	// Initiate multipart upload
-	initOutput, err := s3.InitiateMultipartUpload(InitiateMultipartUploadInput{
-		Bucket:               input.Bucket,
-		ObjectKey:            input.ObjectKey,
-		ContentType:          input.ContentType,
-		CustomMetadata:       input.CustomMetadata,
-		ACL:                  input.ACL,
-		ServerSideEncryption: input.ServerSideEncryption,
-		SSEKMSKeyId:          input.SSEKMSKeyId,
-	})
-	if err != nil {
-		return MultipartUploadOutput{}, err
+	totalSize := input.Size
+	if totalSize <= 0 {
+		totalSize = bodySize(input.Body)
 	}
-
-	// Read entire body to determine size and split into parts
-	bodyData, err := io.ReadAll(input.Body)
-	if err != nil {
-		s3.AbortMultipartUpload(AbortMultipartUploadInput{
-			Bucket:    input.Bucket,
-			ObjectKey: input.ObjectKey,
-			UploadID:  initOutput.UploadID,
-		})
-		return MultipartUploadOutput{}, err
+	if totalSize >= 0 {
+		// Use larger parts if needed to stay within MaxParts.
+		if min := minPartSize(totalSize); partSize < min {
+			partSize = min
+		}
+		if partSize > MaxPartSize {
+			return MultipartUploadOutput{}, fmt.Errorf("file too large: %d bytes would need parts larger than %d bytes", totalSize, MaxPartSize)
+		}
 	}
 
-	totalSize := int64(len(bodyData))
-	totalParts := int(math.Ceil(float64(totalSize) / float64(partSize)))
-
-	if totalParts > MaxParts {
-		s3.AbortMultipartUpload(AbortMultipartUploadInput{
-			Bucket:    input.Bucket,
-			ObjectKey: input.ObjectKey,
-			UploadID:  initOutput.UploadID,
+	// Resume the upload saved in the checkpoint, if any
+	var cp *MultipartCheckpoint
+	if input.Checkpoint != nil {
+		var err error
+		cp, err = s3.resumeCheckpoint(ctx, input.Checkpoint, MultipartCheckpoint{
+			Bucket:            input.Bucket,
+			ObjectKey:         input.ObjectKey,
+			Size:              totalSize,
+			ChecksumAlgorithm: input.ChecksumAlgorithm,
+			ChecksumType:      input.ChecksumType,
 		})
-		return MultipartUploadOutput{}, fmt.Errorf("file too large: requires %d parts, maximum is %d", totalParts, MaxParts)
+		if err != nil {
+			return MultipartUploadOutput{}, err
+		}
 	}
 
-	startTime := time.Now()
-	var uploadedBytes int64
+	if cp == nil {
+		// Initiate multipart upload
+		initOutput, err := s3.InitiateMultipartUploadWithContext(ctx, InitiateMultipartUploadInput{
+			Bucket:               input.Bucket,
+			ObjectKey:            input.ObjectKey,
+			ContentType:          input.ContentType,
+			ContentEncoding:      encoding,
+			StorageClass:         input.StorageClass,
+			CustomMetadata:       input.CustomMetadata,
+			ACL:                  input.ACL,
+			ServerSideEncryption: input.ServerSideEncryption,
+			SSEKMSKeyId:          input.SSEKMSKeyId,
+			SSECustomerKey:       input.SSECustomerKey,
+			ChecksumAlgorithm:    input.ChecksumAlgorithm,
+			ChecksumType:         input.ChecksumType,
+
+			ObjectLockMode:            input.ObjectLockMode,
+			ObjectLockRetainUntilDate: input.ObjectLockRetainUntilDate,
+			ObjectLockLegalHoldStatus: input.ObjectLockLegalHoldStatus,
+
+			ContentDisposition:      input.ContentDisposition,
+			CacheControl:            input.CacheControl,
+			ContentLanguage:         input.ContentLanguage,
+			Expires:                 input.Expires,
+			WebsiteRedirectLocation: input.WebsiteRedirectLocation,
+			GrantRead:               input.GrantRead,
+			GrantReadACP:            input.GrantReadACP,
+			GrantWriteACP:           input.GrantWriteACP,
+			GrantFullControl:        input.GrantFullControl,
+		})
+		if err != nil {
+			return MultipartUploadOutput{}, err
+		}
 
-	// Upload parts
-	var completedParts []CompletedPart
-	var err2 error
+		cp = &MultipartCheckpoint{
+			Bucket:            input.Bucket,
+			ObjectKey:         input.ObjectKey,
+			UploadID:          initOutput.UploadID,
+			PartSize:          partSize,
+			Size:              totalSize,
+			ChecksumAlgorithm: input.ChecksumAlgorithm,
+			ChecksumType:      input.ChecksumType,
+		}
+		if input.Checkpoint != nil {
+			if err := input.Checkpoint.Save(cp); err != nil {
+				s3.abortUpload(ctx, cp.Bucket, cp.ObjectKey, cp.UploadID)
+				return MultipartUploadOutput{}, fmt.Errorf("error saving checkpoint: %w", err)
+			}
+		}
+	}
 
-	if concurrency <= 1 {
-		// Sequential upload
-		completedParts, err2 = s3.uploadPartsSequential(bodyData, partSize, totalParts, initOutput.UploadID, input.Bucket, input.ObjectKey, maxRetries, input.OnProgress, &uploadedBytes, totalSize, startTime)
-	} else {
-		// Parallel upload
-		completedParts, err2 = s3.uploadPartsParallel2(bodyData, partSize, totalParts, initOutput.UploadID, input.Bucket, input.ObjectKey, maxRetries, concurrency, input.OnProgress, &uploadedBytes, totalSize, startTime)
+	// Upload parts. Parts uploaded earlier are then read rather than
+	// seeked past, so that they are part of the checksum.
+	body := input.Body
+	if fullChecksum != nil {
+		body = io.TeeReader(body, fullChecksum)
+	}
+	completedParts, err := s3.uploadParts(ctx, body, uploadPartsInput{
+		Checkpoint:     input.Checkpoint,
+		State:          cp,
+		MaxRetries:     maxRetries,
+		Concurrency:    concurrency,
+		OnProgress:     input.OnProgress,
+		SSECustomerKey: input.SSECustomerKey,
+		VerifyParts:    len(input.SSECustomerKey) == 0 && !strings.HasPrefix(input.ServerSideEncryption, "aws:kms"),
+	})
+	if err != nil {
+		// A checkpointed upload is kept so that it can be resumed.
+		if input.Checkpoint == nil {
+			s3.abortUpload(ctx, cp.Bucket, cp.ObjectKey, cp.UploadID)
+		}
+		return MultipartUploadOutput{}, err
 	}
 
-	if err2 != nil {
-		s3.AbortMultipartUpload(AbortMultipartUploadInput{
-			Bucket:    input.Bucket,
-			ObjectKey: input.ObjectKey,
-			UploadID:  initOutput.UploadID,
-		})
-		return MultipartUploadOutput{}, err2
+	var fullChecksumValue string
+	if fullChecksum != nil {
+		fullChecksumValue = encodeChecksum(fullChecksum)
 	}
 
 	// Complete multipart upload
-	completeOutput, err := s3.CompleteMultipartUpload(CompleteMultipartUploadInput{
-		Bucket:    input.Bucket,
-		ObjectKey: input.ObjectKey,
-		UploadID:  initOutput.UploadID,
-		Parts:     completedParts,
+	completeOutput, err := s3.CompleteMultipartUploadWithContext(ctx, CompleteMultipartUploadInput{
+		Bucket:      input.Bucket,
+		ObjectKey:   input.ObjectKey,
+		UploadID:    cp.UploadID,
+		Parts:       completedParts,
+		IfNoneMatch: input.IfNoneMatch,
+		IfMatch:     input.IfMatch,
+
+		ChecksumAlgorithm: input.ChecksumAlgorithm,
+		ChecksumType:      input.ChecksumType,
+		Checksum:          fullChecksumValue,
 	})
 	if err != nil {
-		s3.AbortMultipartUpload(AbortMultipartUploadInput{
-			Bucket:    input.Bucket,
-			ObjectKey: input.ObjectKey,
-			UploadID:  initOutput.UploadID,
-		})
+		if input.Checkpoint == nil {
+			s3.abortUpload(ctx, cp.Bucket, cp.ObjectKey, cp.UploadID)
+		}
 		return MultipartUploadOutput{}, err
 	}
 
+	if input.Checkpoint != nil {
+		// The object is uploaded: failing to clean up the checkpoint
+		// only means a later attempt won't find the upload to resume.
+		input.Checkpoint.Delete()
+	}
+
 	return MultipartUploadOutput{
 		Location: completeOutput.Location,
 		Bucket:   completeOutput.Bucket,
 		Key:      completeOutput.Key,
 		ETag:     completeOutput.ETag,
-		UploadID: initOutput.UploadID,
+		UploadID: cp.UploadID,
+
+		Checksum:     completeOutput.Checksum,
+		ChecksumType: completeOutput.ChecksumType,
 	}, nil
 }
 
-// uploadPartsSequential uploads parts sequentially
-func (s3 *S3) uploadPartsSequential(bodyData []byte, partSize int64, totalParts int, uploadID, bucket, objectKey string, maxRetries int, onProgress ProgressFunc, uploadedBytes *int64, totalSize int64, startTime time.Time) ([]CompletedPart, error) {
-	completedParts := make([]CompletedPart, 0, totalParts)
-
-	for partNum := 1; partNum <= totalParts; partNum++ {
-		start := int64(partNum-1) * partSize
-		end := start + partSize
-		if end > totalSize {
-			end = totalSize
-		}
-
-		partData := bodyData[start:end]
-
-		output, err := s3.uploadPartWithRetry(UploadPartInput{
-			Bucket:     bucket,
-			ObjectKey:  objectKey,
-			UploadID:   uploadID,
-			PartNumber: partNum,
-			Body:       bytes.NewReader(partData),
-			Size:       int64(len(partData)),
-		}, maxRetries)
+// abortUpload aborts an upload after a failure, even if ctx is cancelled.
+func (s3 *S3) abortUpload(ctx context.Context, bucket, objectKey, uploadID string) {
+	s3.AbortMultipartUploadWithContext(context.WithoutCancel(ctx), AbortMultipartUploadInput{
+		Bucket:    bucket,
+		ObjectKey: objectKey,
+		UploadID:  uploadID,
+	})
+}
 
-		if err != nil {
-			return nil, err
-		}
+// bodySize returns the number of bytes left in body if it is an
+// io.Seeker, or -1 if its size is not known.
+func bodySize(body io.Reader) int64 {
+	seeker, ok := body.(io.Seeker)
+	if !ok {
+		return -1
+	}
 
-		completedParts = append(completedParts, CompletedPart{
-			PartNumber: output.PartNumber,
-			ETag:       output.ETag,
-		})
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+	end, err := detectFileSize(seeker)
+	if err != nil {
+		return -1
+	}
+	return end - start
+}
 
-		*uploadedBytes += int64(len(partData))
+// minPartSize returns the smallest part size, rounded up to a MiB,
+// that fits an upload of totalSize bytes within MaxParts.
+func minPartSize(totalSize int64) int64 {
+	const mib = 1024 * 1024
+	size := (totalSize + MaxParts - 1) / MaxParts
+	return (size + mib - 1) / mib * mib
+}
 
-		// Call progress callback
-		if onProgress != nil {
-			elapsed := time.Since(startTime).Seconds()
-			bytesPerSecond := int64(0)
-			if elapsed > 0 {
-				bytesPerSecond = int64(float64(*uploadedBytes) / elapsed)
-			}
+// partSizeGrowthInterval is the number of parts after which the part
+// size doubles when the size of the upload is not known. Starting with
+// the 5 MiB default, this allows uploads up to ~4.8 TiB within MaxParts.
+const partSizeGrowthInterval = 1000
 
-			onProgress(ProgressInfo{
-				TotalBytes:     totalSize,
-				UploadedBytes:  *uploadedBytes,
-				CurrentPart:    partNum,
-				TotalParts:     totalParts,
-				BytesPerSecond: bytesPerSecond,
-			})
-		}
+// grownPartSize returns the size of part partNum of an upload of
+// unknown size, whose first parts are base bytes.
+func grownPartSize(base int64, partNum int) int64 {
+	size := base
+	for i := partSizeGrowthInterval; i < partNum && size < MaxPartSize; i += partSizeGrowthInterval {
+		size *= 2
+	}
+	if size > MaxPartSize {
+		size = MaxPartSize
 	}
+	return size
+}
 
-	return completedParts, nil
+// uploadPart is a part read from the body of an upload.
+type uploadPart struct {
+	number int
+	data   []byte
 }
 
-// partData represents a part to be uploaded
-type partData struct {
-	partNumber int
-	data       []byte
+// uploadPartsInput contains the parameters of uploadParts.
+type uploadPartsInput struct {
+	// State is the upload to add the parts to. Parts it already holds
+	// are skipped, and parts are added to it as they are uploaded.
+	State *MultipartCheckpoint
+	// Checkpoint, if set, is where State is saved after each part.
+	Checkpoint CheckpointStore
+
+	MaxRetries  int
+	Concurrency int
+	OnProgress  ProgressFunc
+
+	// SSECustomerKey is the SSE-C key the upload was initiated with.
+	// It isn't saved in the checkpoint.
+	SSECustomerKey []byte
+
+	// VerifyParts has the parts held by State read from body and
+	// checked against their ETag, the MD5 of their data, instead of
+	// skipped. Parts that don't match are uploaded again. The ETags of
+	// parts encrypted with SSE-KMS or SSE-C aren't an MD5.
+	VerifyParts bool
 }
 
-// uploadPartsParallel2 uploads parts in parallel using a worker pool
-func (s3 *S3) uploadPartsParallel2(bodyData []byte, partSize int64, totalParts int, uploadID, bucket, objectKey string, maxRetries, concurrency int, onProgress ProgressFunc, uploadedBytes *int64, totalSize int64, startTime time.Time) ([]CompletedPart, error) {
-	ctx, cancel := context.WithCancel(context.Background())
+// uploadParts reads body part by part and uploads the parts using
+// concurrency workers. Parts are read into a bounded pool of buffers,
+// which are reused once their part is uploaded, so at most concurrency
+// parts are held in memory. If the size of the upload is not known, the
+// part size grows as the upload progresses to stay within MaxParts.
+func (s3 *S3) uploadParts(ctx context.Context, body io.Reader, input uploadPartsInput) ([]CompletedPart, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	// Create channels
-	partsChan := make(chan partData, concurrency)
-	resultsChan := make(chan CompletedPart, totalParts)
-	errChan := make(chan error, concurrency)
+	var (
+		cp          = input.State
+		concurrency = input.Concurrency
+		totalParts  = 0
+	)
+	if cp.Size >= 0 {
+		totalParts = int((cp.Size + cp.PartSize - 1) / cp.PartSize)
+	}
+
+	var (
+		pool  = make(chan []byte, concurrency)
+		parts = make(chan uploadPart)
+		wg    sync.WaitGroup
+
+		mu            sync.Mutex
+		uploadedBytes int64
+		uploadErr     error
+		startTime     = time.Now()
+
+		// Parts uploaded earlier, which are skipped.
+		done = make(map[int]Part, len(cp.Parts))
+	)
+	for _, part := range cp.Parts {
+		done[part.PartNumber] = part
+		uploadedBytes += part.Size
+	}
+
+	// The pool holds one buffer per worker. Buffers are allocated
+	// when first needed, and grown along with the part size.
+	for i := 0; i < concurrency; i++ {
+		pool <- nil
+	}
 
-	// Start workers
-	var wg sync.WaitGroup
 	for i := 0; i < concurrency; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case part, ok := <-partsChan:
-					if !ok {
-						return
-					}
-
-					output, err := s3.uploadPartWithRetry(UploadPartInput{
-						Bucket:     bucket,
-						ObjectKey:  objectKey,
-						UploadID:   uploadID,
-						PartNumber: part.partNumber,
-						Body:       bytes.NewReader(part.data),
-						Size:       int64(len(part.data)),
-					}, maxRetries)
-
-					if err != nil {
-						select {
-						case errChan <- err:
-							cancel()
-						default:
+			for part := range parts {
+				output, err := s3.uploadPartWithRetry(ctx, UploadPartInput{
+					Bucket:     cp.Bucket,
+					ObjectKey:  cp.ObjectKey,
+					UploadID:   cp.UploadID,
+					PartNumber: part.number,
+					Body:       bytes.NewReader(part.data),
+					Size:       int64(len(part.data)),
+
+					ChecksumAlgorithm: cp.ChecksumAlgorithm,
+					SSECustomerKey:    input.SSECustomerKey,
+				}, input.MaxRetries)
+				pool <- part.data
+
+				mu.Lock()
+				if err == nil {
+					cp.Parts = append(cp.Parts, Part{
+						PartNumber:   output.PartNumber,
+						ETag:         output.ETag,
+						Size:         int64(len(part.data)),
+						LastModified: time.Now(),
+						Checksum:     output.Checksum,
+					})
+					if input.Checkpoint != nil {
+						if err = input.Checkpoint.Save(cp); err != nil {
+							err = fmt.Errorf("error saving checkpoint: %w", err)
 						}
-						return
 					}
+				}
+				if err != nil {
+					if uploadErr == nil {
+						uploadErr = err
+					}
+					mu.Unlock()
+					cancel()
+					continue
+				}
 
-					resultsChan <- CompletedPart{
-						PartNumber: output.PartNumber,
-						ETag:       output.ETag,
+				uploadedBytes += int64(len(part.data))
+
+				// Call progress callback
+				if input.OnProgress != nil {
+					elapsed := time.Since(startTime).Seconds()
+					bytesPerSecond := int64(0)
+					if elapsed > 0 {
+						bytesPerSecond = int64(float64(uploadedBytes) / elapsed)
 					}
+
+					input.OnProgress(ProgressInfo{
+						TotalBytes:     max(cp.Size, 0),
+						UploadedBytes:  uploadedBytes,
+						CurrentPart:    part.number,
+						TotalParts:     totalParts,
+						BytesPerSecond: bytesPerSecond,
+					})
 				}
+				mu.Unlock()
 			}
 		}()
 	}
 
-	// Send parts to workers
-	go func() {
-		for partNum := 1; partNum <= totalParts; partNum++ {
-			start := int64(partNum-1) * partSize
-			end := start + partSize
-			if end > totalSize {
-				end = totalSize
+	// Read parts into buffers from the pool and hand them to the workers.
+	readErr := func() error {
+		for partNum := 1; ; partNum++ {
+			size := cp.PartSize
+			if cp.Size < 0 {
+				size = grownPartSize(cp.PartSize, partNum)
+			}
+
+			part, uploaded := done[partNum]
+			if uploaded && !input.VerifyParts {
+				if err := skipBytes(body, part.Size); err != nil {
+					return err
+				}
+				if part.Size < size {
+					return nil
+				}
+				continue
 			}
 
+			var buf []byte
 			select {
 			case <-ctx.Done():
-				return
-			case partsChan <- partData{
-				partNumber: partNum,
-				data:       bodyData[start:end],
-			}:
+				return nil
+			case buf = <-pool:
+			}
+			if int64(cap(buf)) < size {
+				buf = make([]byte, size)
 			}
-		}
-		close(partsChan)
-	}()
-
-	// Collect results
-	go func() {
-		wg.Wait()
-		close(resultsChan)
-		close(errChan)
-	}()
-
-	completedParts := make([]CompletedPart, 0, totalParts)
-	partsCompleted := 0
 
-	for {
-		select {
-		case err := <-errChan:
-			if err != nil {
-				cancel()
-				return nil, err
+			n, err := io.ReadFull(body, buf[:size])
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				pool <- buf
+				return err
 			}
-		case part, ok := <-resultsChan:
-			if !ok {
-				// Sort by part number
-				for i := 0; i < len(completedParts); i++ {
-					for j := i + 1; j < len(completedParts); j++ {
-						if completedParts[i].PartNumber > completedParts[j].PartNumber {
-							completedParts[i], completedParts[j] = completedParts[j], completedParts[i]
-						}
+
+			if uploaded {
+				if int64(n) != part.Size {
+					pool <- buf
+					return fmt.Errorf("part %d has %d bytes, but was uploaded earlier with %d bytes", partNum, n, part.Size)
+				}
+				sum := md5.Sum(buf[:n])
+				if strings.Trim(part.ETag, `"`) == hex.EncodeToString(sum[:]) {
+					pool <- buf
+					if int64(n) < size {
+						return nil
 					}
+					continue
 				}
-				return completedParts, nil
+
+				// The body changed since the part was uploaded.
+				mu.Lock()
+				cp.Parts = slices.DeleteFunc(cp.Parts, func(p Part) bool { return p.PartNumber == partNum })
+				uploadedBytes -= part.Size
+				mu.Unlock()
 			}
 
-			completedParts = append(completedParts, part)
-			partsCompleted++
-			*uploadedBytes = int64(partsCompleted) * partSize
-			if *uploadedBytes > totalSize {
-				*uploadedBytes = totalSize
+			// An empty body is uploaded as a single empty part.
+			if n == 0 && partNum > 1 {
+				pool <- buf
+				return nil
+			}
+			if partNum > MaxParts {
+				pool <- buf
+				return fmt.Errorf("file too large: requires more than %d parts", MaxParts)
 			}
 
-			// Call progress callback
-			if onProgress != nil {
-				elapsed := time.Since(startTime).Seconds()
-				bytesPerSecond := int64(0)
-				if elapsed > 0 {
-					bytesPerSecond = int64(float64(*uploadedBytes) / elapsed)
-				}
+			parts <- uploadPart{number: partNum, data: buf[:n]}
+			if int64(n) < size {
+				return nil
+			}
+		}
+	}()
+
+	close(parts)
+	wg.Wait()
 
-				onProgress(ProgressInfo{
-					TotalBytes:     totalSize,
-					UploadedBytes:  *uploadedBytes,
-					CurrentPart:    partsCompleted,
-					TotalParts:     totalParts,
-					BytesPerSecond: bytesPerSecond,
+	if readErr != nil {
+		return nil, readErr
+	}
+	if uploadErr != nil {
+		return nil, uploadErr
+	}
+	// Workers may have stopped because the caller's context was cancelled.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	completedParts := make([]CompletedPart, 0, len(cp.Parts))
+	for _, part := range cp.Parts {
+		completedParts = append(completedParts, CompletedPart{
+			PartNumber: part.PartNumber,
+			ETag:       part.ETag,
+			Checksum:   part.Checksum,
+		})
+	}
+	sort.Slice(completedParts, func(i, j int) bool {
+		return completedParts[i].PartNumber < completedParts[j].PartNumber
+	})
+	return completedParts, nil
+}
+
+// skipBytes skips the next n bytes of body, which were uploaded
+// earlier, seeking past them if possible.
+func skipBytes(body io.Reader, n int64) error {
+	if seeker, ok := body.(io.Seeker); ok {
+		_, err := seeker.Seek(n, io.SeekCurrent)
+		return err
+	}
+
+	skipped, err := io.CopyN(io.Discard, body, n)
+	if err == io.EOF {
+		return fmt.Errorf("body ended after %d bytes of a part uploaded earlier", skipped)
+	}
+	return err
+}
+
+// CopyObjectMultipartInput contains parameters for a multipart copy.
+// The copy is configured like CopyObject, with the metadata, tags and
+// server-side encryption settings of the embedded CopyObjectInput.
+type CopyObjectMultipartInput struct {
+	CopyObjectInput
+
+	PartSize    int64 // Optional: default 100MB, min 5MB, max 5GB
+	MaxRetries  int   // Optional: default 3
+	Concurrency int   // Optional: default 1 (sequential)
+}
+
+// CopyObjectMultipart copies an object using a multipart upload whose
+// parts are copied from ranges of the source with UploadPartCopy. Unlike
+// CopyObject, it can copy objects larger than 5GB.
+//
+// With the default COPY MetadataDirective, the content type, standard
+// headers (Cache-Control, Content-Disposition, Content-Encoding,
+// Content-Language and Expires) and custom metadata of the source are
+// copied, as CopyObject does, and so are its tags unless Tags is set.
+// The copy fails if the source changes while it is being copied.
+func (s3 *S3) CopyObjectMultipart(input CopyObjectMultipartInput) (CopyObjectOutput, error) {
+	return s3.CopyObjectMultipartWithContext(context.Background(), input)
+}
+
+// CopyObjectMultipartWithContext is like CopyObjectMultipart but uses ctx for the requests.
+func (s3 *S3) CopyObjectMultipartWithContext(ctx context.Context, input CopyObjectMultipartInput) (CopyObjectOutput, error) {
+	if input.SourceBucket == "" || input.SourceKey == "" {
+		return CopyObjectOutput{}, fmt.Errorf("source bucket and key are required")
+	}
+	if input.DestBucket == "" || input.DestKey == "" {
+		return CopyObjectOutput{}, fmt.Errorf("destination bucket and key are required")
+	}
+
+	partSize := input.PartSize
+	if partSize == 0 {
+		partSize = DefaultCopyPartSize
+	}
+	if partSize < MinPartSize || partSize > MaxPartSize {
+		return CopyObjectOutput{}, fmt.Errorf("part size must be between %d and %d bytes", MinPartSize, MaxPartSize)
+	}
+
+	concurrency := input.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	source, err := s3.FileDetailsWithContext(ctx, DetailsInput{
+		Bucket:         input.SourceBucket,
+		ObjectKey:      input.SourceKey,
+		SSECustomerKey: input.CopySourceSSECustomerKey,
+	})
+	if err != nil {
+		return CopyObjectOutput{}, err
+	}
+	size := source.Size
+	if size < 0 {
+		return CopyObjectOutput{}, fmt.Errorf("invalid source content length %q", source.ContentLength)
+	}
+
+	// Parts are copied only if the source keeps the ETag it has now,
+	// which CopySourceIfMatch can be checked against once.
+	if input.CopySourceIfMatch != "" && strings.Trim(input.CopySourceIfMatch, `"`) != strings.Trim(source.Etag, `"`) {
+		return CopyObjectOutput{}, &S3Error{
+			StatusCode: http.StatusPreconditionFailed,
+			Code:       "PreconditionFailed",
+			Message:    "At least one of the pre-conditions you specified did not hold",
+			Operation:  "CopyObjectMultipart",
+			Bucket:     input.SourceBucket,
+			Key:        input.SourceKey,
+		}
+	}
+
+	// Empty objects can't be copied in parts.
+	if size == 0 {
+		return s3.CopyObjectWithContext(ctx, input.CopyObjectInput)
+	}
+
+	if min := minPartSize(size); partSize < min {
+		partSize = min
+	}
+	if partSize > MaxPartSize {
+		return CopyObjectOutput{}, fmt.Errorf("object too large: %d bytes would need parts larger than %d bytes", size, MaxPartSize)
+	}
+
+	initInput := InitiateMultipartUploadInput{
+		Bucket:               input.DestBucket,
+		ObjectKey:            input.DestKey,
+		ContentType:          source.ContentType,
+		ServerSideEncryption: input.ServerSideEncryption,
+		SSEKMSKeyId:          input.SSEKMSKeyId,
+		SSECustomerKey:       input.SSECustomerKey,
+
+		ObjectLockMode:            input.ObjectLockMode,
+		ObjectLockRetainUntilDate: input.ObjectLockRetainUntilDate,
+		ObjectLockLegalHoldStatus: input.ObjectLockLegalHoldStatus,
+
+		WebsiteRedirectLocation: input.WebsiteRedirectLocation,
+		GrantRead:               input.GrantRead,
+		GrantReadACP:            input.GrantReadACP,
+		GrantWriteACP:           input.GrantWriteACP,
+		GrantFullControl:        input.GrantFullControl,
+	}
+	if input.MetadataDirective == "REPLACE" {
+		initInput.ContentType = input.ContentType
+		initInput.CustomMetadata = input.CustomMetadata
+		initInput.ContentDisposition = input.ContentDisposition
+		initInput.CacheControl = input.CacheControl
+		initInput.ContentEncoding = input.ContentEncoding
+		initInput.ContentLanguage = input.ContentLanguage
+		initInput.Expires = input.Expires
+	} else {
+		initInput.ContentDisposition = source.ContentDisposition
+		initInput.CacheControl = source.CacheControl
+		initInput.ContentEncoding = source.ContentEncoding
+		initInput.ContentLanguage = source.ContentLanguage
+		initInput.Expires = source.Expires
+		for k, v := range source.AmzMeta {
+			if initInput.CustomMetadata == nil {
+				initInput.CustomMetadata = map[string]string{}
+			}
+			initInput.CustomMetadata[strings.ToLower(k[len(AMZMetaPrefix):])] = v
+		}
+	}
+
+	// Like CopyObject, the copy is STANDARD unless StorageClass is set.
+	initInput.StorageClass = input.StorageClass
+
+	tags := input.Tags
+	if tags == nil {
+		sourceTags, err := s3.GetObjectTaggingWithContext(ctx, GetObjectTaggingInput{
+			Bucket:    input.SourceBucket,
+			ObjectKey: input.SourceKey,
+		})
+		if err != nil {
+			return CopyObjectOutput{}, err
+		}
+		tags = sourceTags.Tags
+	}
+
+	initOutput, err := s3.InitiateMultipartUploadWithContext(ctx, initInput)
+	if err != nil {
+		return CopyObjectOutput{}, err
+	}
+	completedParts, err := s3.copyParts(ctx, input, source.Etag, size, partSize, initOutput.UploadID, concurrency)
+	if err != nil {
+		s3.abortUpload(ctx, input.DestBucket, input.DestKey, initOutput.UploadID)
+		return CopyObjectOutput{}, err
+	}
+
+	completeOutput, err := s3.CompleteMultipartUploadWithContext(ctx, CompleteMultipartUploadInput{
+		Bucket:      input.DestBucket,
+		ObjectKey:   input.DestKey,
+		UploadID:    initOutput.UploadID,
+		Parts:       completedParts,
+		IfNoneMatch: input.IfNoneMatch,
+		IfMatch:     input.IfMatch,
+	})
+	if err != nil {
+		s3.abortUpload(ctx, input.DestBucket, input.DestKey, initOutput.UploadID)
+		return CopyObjectOutput{}, err
+	}
+
+	// CompleteMultipartUpload doesn't return when the object was
+	// last modified, so LastModified is left unset.
+	output := CopyObjectOutput{ETag: completeOutput.ETag}
+
+	// Apply tags (as CopyObject does, after the copy)
+	if len(tags) > 0 {
+		err := s3.PutObjectTaggingWithContext(ctx, PutObjectTaggingInput{
+			Bucket:    input.DestBucket,
+			ObjectKey: input.DestKey,
+			Tags:      tags,
+		})
+		if err != nil {
+			return output, fmt.Errorf("copy succeeded but tagging failed: %v", err)
+		}
+	}
+
+	return output, nil
+}
+
+// copyParts copies the size bytes of the source of input to the upload
+// in parts of partSize bytes, using concurrency workers. Each part is
+// only copied if the source still has the given ETag.
+func (s3 *S3) copyParts(ctx context.Context, input CopyObjectMultipartInput, etag string, size, partSize int64, uploadID string, concurrency int) ([]CompletedPart, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		totalParts = int((size + partSize - 1) / partSize)
+		partNums   = make(chan int)
+		wg         sync.WaitGroup
+
+		mu             sync.Mutex
+		completedParts = make([]CompletedPart, 0, totalParts)
+		copyErr        error
+	)
+
+	retryCtx := s3.withMaxRetries(ctx, input.MaxRetries)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for partNum := range partNums {
+				first := int64(partNum-1) * partSize
+				output, err := s3.UploadPartCopyWithContext(retryCtx, UploadPartCopyInput{
+					Bucket:                      input.DestBucket,
+					ObjectKey:                   input.DestKey,
+					UploadID:                    uploadID,
+					PartNumber:                  partNum,
+					SourceBucket:                input.SourceBucket,
+					SourceKey:                   input.SourceKey,
+					SourceFirstByte:             first,
+					SourceLastByte:              min(first+partSize, size) - 1,
+					CopySourceIfMatch:           etag,
+					CopySourceIfNoneMatch:       input.CopySourceIfNoneMatch,
+					CopySourceIfModifiedSince:   input.CopySourceIfModifiedSince,
+					CopySourceIfUnmodifiedSince: input.CopySourceIfUnmodifiedSince,
+					SSECustomerKey:              input.SSECustomerKey,
+					CopySourceSSECustomerKey:    input.CopySourceSSECustomerKey,
 				})
+
+				mu.Lock()
+				if err != nil {
+					if copyErr == nil {
+						copyErr = err
+					}
+					cancel()
+				} else {
+					completedParts = append(completedParts, CompletedPart{
+						PartNumber: output.PartNumber,
+						ETag:       output.ETag,
+					})
+				}
+				mu.Unlock()
 			}
+		}()
+	}
+
+feed:
+	for partNum := 1; partNum <= totalParts; partNum++ {
+		select {
+		case partNums <- partNum:
+		case <-ctx.Done():
+			break feed
 		}
 	}
+	close(partNums)
+	wg.Wait()
+
+	if copyErr != nil {
+		return nil, copyErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(completedParts, func(i, j int) bool {
+		return completedParts[i].PartNumber < completedParts[j].PartNumber
+	})
+	return completedParts, nil
 }
@@ -2,11 +2,22 @@ package simples3
 
 import (
 	"bytes"
+	"crypto/md5"
 	"crypto/rand"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func TestMultipartUploadWorkflow(t *testing.T) {
@@ -571,7 +582,7 @@ func TestGeneratePresignedUploadPartURL(t *testing.T) {
 	}
 
 	for _, param := range requiredParams {
-		if !contains(url, param) {
+		if !strings.Contains(url, param) {
 			t.Errorf("URL should contain %s", param)
 		}
 	}
@@ -612,3 +623,613 @@ func BenchmarkFileUploadMultipart(b *testing.B) {
 		})
 	}
 }
+
+// multipartStub is a fake S3 server implementing the multipart upload
+// API, which checks request signatures and keeps the uploaded parts.
+type multipartStub struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	parts     map[int][]byte
+	puts      int
+	failPart  int
+	active    int
+	maxActive int
+	completed []byte
+	aborted   bool
+
+	// Checksums of the parts, and the requests initiating and
+	// completing the upload.
+	checksums     map[int]string
+	initiate      *http.Request
+	complete      *http.Request
+	completeParts []completePart
+}
+
+// partETag returns the ETag S3 gives to a part of data.
+func partETag(data []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(data))
+}
+
+func newMultipartStub(t *testing.T) *multipartStub {
+	m := &multipartStub{parts: map[int][]byte{}, checksums: map[int]string{}}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := verifySigV4(r, "SuperSecretKey")
+		if err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		q := r.URL.Query()
+
+		switch {
+		case r.Method == http.MethodPost && q.Has("uploads"):
+			m.mu.Lock()
+			m.initiate = r
+			m.mu.Unlock()
+			fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><UploadId>upload</UploadId></InitiateMultipartUploadResult>`)
+
+		case r.Method == http.MethodPut && q.Has("partNumber"):
+			m.mu.Lock()
+			m.active++
+			m.maxActive = max(m.maxActive, m.active)
+			m.mu.Unlock()
+
+			// Let other parts be uploaded concurrently.
+			time.Sleep(10 * time.Millisecond)
+
+			n, _ := strconv.Atoi(q.Get("partNumber"))
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.active--
+			m.puts++
+			if n == m.failPart {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+				return
+			}
+			if algorithm, sum := responseChecksum(r.Header); algorithm != "" {
+				h, _ := newChecksum(algorithm)
+				h.Write(body)
+				if encodeChecksum(h) != sum {
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, `<Error><Code>BadDigest</Code></Error>`)
+					return
+				}
+				m.checksums[n] = sum
+				w.Header().Set(checksumHeader(algorithm), sum)
+			}
+			m.parts[n] = body
+			w.Header().Set("ETag", partETag(body))
+
+		case r.Method == http.MethodGet && q.Has("uploadId"):
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if m.aborted || m.completed != nil {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `<Error><Code>NoSuchUpload</Code><Message>The specified upload does not exist</Message></Error>`)
+				return
+			}
+			fmt.Fprint(w, `<ListPartsResult><Bucket>bucket</Bucket><Key>key</Key><UploadId>upload</UploadId>`)
+			for n, data := range m.parts {
+				fmt.Fprintf(w, `<Part><PartNumber>%d</PartNumber><ETag>%s</ETag><Size>%d</Size></Part>`, n, partETag(data), len(data))
+			}
+			fmt.Fprint(w, `</ListPartsResult>`)
+
+		case r.Method == http.MethodPost && q.Has("uploadId"):
+			var req completeMultipartUploadRequest
+			if err := xml.Unmarshal(body, &req); err != nil {
+				t.Error(err)
+			}
+
+			m.mu.Lock()
+			m.complete, m.completeParts = r, req.Parts
+			m.completed = []byte{}
+			for i, p := range req.Parts {
+				if p.PartNumber != i+1 || p.ETag != partETag(m.parts[p.PartNumber]) {
+					t.Errorf("unexpected part %d: %+v", i+1, p)
+				}
+				m.completed = append(m.completed, m.parts[p.PartNumber]...)
+			}
+			m.mu.Unlock()
+			fmt.Fprint(w, `<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`)
+
+		case r.Method == http.MethodDelete && q.Has("uploadId"):
+			m.mu.Lock()
+			m.aborted = true
+			m.mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestFileUploadMultipart_Streaming(t *testing.T) {
+	content := make([]byte, 3*MinPartSize+1234)
+	rand.Read(content)
+
+	tests := []struct {
+		name        string
+		body        io.Reader
+		concurrency int
+		totalBytes  int64
+	}{
+		{"UnknownSize", iotest.HalfReader(bytes.NewReader(content)), 2, 0},
+		{"Sequential", iotest.HalfReader(bytes.NewReader(content)), 1, 0},
+		{"Seeker", bytes.NewReader(content), 3, int64(len(content))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newMultipartStub(t)
+			s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+			s3.SetEndpoint(stub.URL)
+
+			var last ProgressInfo
+			_, err := s3.FileUploadMultipart(MultipartUploadInput{
+				Bucket:      "bucket",
+				ObjectKey:   "key",
+				Body:        tt.body,
+				PartSize:    MinPartSize,
+				Concurrency: tt.concurrency,
+				OnProgress:  func(info ProgressInfo) { last = info },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(stub.completed, content) {
+				t.Errorf("uploaded %d bytes, want %d", len(stub.completed), len(content))
+			}
+			if len(stub.parts) != 4 || len(stub.parts[1]) != MinPartSize || len(stub.parts[4]) != 1234 {
+				t.Errorf("unexpected parts: %d", len(stub.parts))
+			}
+			if stub.maxActive > tt.concurrency {
+				t.Errorf("%d parts uploaded at once, want at most %d", stub.maxActive, tt.concurrency)
+			}
+			if last.UploadedBytes != int64(len(content)) || last.TotalBytes != tt.totalBytes {
+				t.Errorf("unexpected progress: %+v", last)
+			}
+		})
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		stub := newMultipartStub(t)
+		s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+		s3.SetEndpoint(stub.URL)
+
+		_, err := s3.FileUploadMultipart(MultipartUploadInput{
+			Bucket:    "bucket",
+			ObjectKey: "key",
+			Body:      iotest.HalfReader(strings.NewReader("")),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stub.parts) != 1 || len(stub.completed) != 0 {
+			t.Errorf("expected a single empty part, got %d parts", len(stub.parts))
+		}
+	})
+
+	t.Run("ReadError", func(t *testing.T) {
+		stub := newMultipartStub(t)
+		s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+		s3.SetEndpoint(stub.URL)
+
+		_, err := s3.FileUploadMultipart(MultipartUploadInput{
+			Bucket:    "bucket",
+			ObjectKey: "key",
+			Body:      io.MultiReader(bytes.NewReader(content), iotest.ErrReader(io.ErrClosedPipe)),
+		})
+		if !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("expected the read error, got %v", err)
+		}
+		if !stub.aborted || stub.completed != nil {
+			t.Errorf("expected the upload to be aborted")
+		}
+	})
+}
+
+func TestFileUploadMultipart_Resume(t *testing.T) {
+	content := make([]byte, 3*MinPartSize+1234)
+	rand.Read(content)
+
+	stub := newMultipartStub(t)
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(stub.URL)
+
+	store := FileCheckpointStore{Path: filepath.Join(t.TempDir(), "upload.json")}
+	upload := func(body io.Reader, onProgress ProgressFunc) error {
+		_, err := s3.FileUploadMultipart(MultipartUploadInput{
+			Bucket:     "bucket",
+			ObjectKey:  "key",
+			Body:       body,
+			PartSize:   MinPartSize,
+			Checkpoint: store,
+			OnProgress: onProgress,
+		})
+		return err
+	}
+
+	// The upload fails on the third part, and is kept.
+	stub.failPart = 3
+	if err := upload(iotest.HalfReader(bytes.NewReader(content)), nil); !IsAccessDenied(err) {
+		t.Fatalf("expected the upload to fail, got %v", err)
+	}
+	if stub.aborted {
+		t.Fatal("expected a checkpointed upload not to be aborted")
+	}
+	cp, err := store.Load()
+	if err != nil || cp == nil {
+		t.Fatalf("expected a checkpoint, got %v", err)
+	}
+	if cp.UploadID != "upload" || len(cp.Parts) != 2 || cp.PartSize != MinPartSize || cp.Size != -1 {
+		t.Errorf("unexpected checkpoint: %+v", cp)
+	}
+
+	// Parts S3 doesn't have any more are uploaded again.
+	stub.failPart = 0
+	delete(stub.parts, 2)
+	stub.puts = 0
+
+	var last ProgressInfo
+	if err := upload(iotest.HalfReader(bytes.NewReader(content)), func(info ProgressInfo) { last = info }); err != nil {
+		t.Fatal(err)
+	}
+	if stub.puts != 3 {
+		t.Errorf("expected parts 2 to 4 to be uploaded, got %d parts", stub.puts)
+	}
+	if !bytes.Equal(stub.completed, content) {
+		t.Errorf("uploaded %d bytes, want %d", len(stub.completed), len(content))
+	}
+	if last.UploadedBytes != int64(len(content)) {
+		t.Errorf("unexpected progress: %+v", last)
+	}
+	if cp, err := store.Load(); err != nil || cp != nil {
+		t.Errorf("expected the checkpoint to be deleted, got %+v, %v", cp, err)
+	}
+
+	// Parts that changed since they were uploaded are uploaded again,
+	// and so are parts of a body changing size.
+	store.Save(&MultipartCheckpoint{Bucket: "bucket", ObjectKey: "key", UploadID: "upload", PartSize: MinPartSize, Size: -1,
+		Parts: []Part{{PartNumber: 1, ETag: partETag(content[:MinPartSize]), Size: MinPartSize}}})
+	stub.completed = nil
+	stub.parts = map[int][]byte{1: content[:MinPartSize]}
+	stub.puts = 0
+	changed := bytes.Clone(content)
+	changed[10] ^= 0xff
+	if err := upload(iotest.HalfReader(bytes.NewReader(changed)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if stub.puts != 4 || !bytes.Equal(stub.completed, changed) {
+		t.Errorf("expected the changed part to be uploaded again, got %d parts", stub.puts)
+	}
+
+	store.Save(&MultipartCheckpoint{Bucket: "bucket", ObjectKey: "key", UploadID: "upload", PartSize: MinPartSize, Size: -1,
+		Parts: []Part{{PartNumber: 1, ETag: partETag(content[:MinPartSize]), Size: MinPartSize}}})
+	stub.completed = nil
+	stub.parts = map[int][]byte{1: content[:MinPartSize]}
+	if err := upload(iotest.HalfReader(bytes.NewReader(content[:100])), nil); err == nil {
+		t.Errorf("expected a body shorter than its parts uploaded earlier to be rejected")
+	}
+
+	// A checkpoint of an upload that no longer exists starts over.
+	stub.completed = []byte{}
+	store.Save(&MultipartCheckpoint{Bucket: "bucket", ObjectKey: "key", UploadID: "upload", PartSize: MinPartSize, Size: int64(len(content))})
+	stub.puts = 0
+	if err := upload(bytes.NewReader(content), nil); err != nil {
+		t.Fatal(err)
+	}
+	if stub.puts != 4 || !bytes.Equal(stub.completed, content) {
+		t.Errorf("expected the upload to start over, got %d parts", stub.puts)
+	}
+}
+
+func TestAbortStaleMultipartUploads(t *testing.T) {
+	now := time.Now().UTC()
+	pages := []string{
+		fmt.Sprintf(`<ListMultipartUploadsResult><Bucket>bucket</Bucket><IsTruncated>true</IsTruncated><NextKeyMarker>b</NextKeyMarker><NextUploadIdMarker>2</NextUploadIdMarker>
+<Upload><Key>a</Key><UploadId>1</UploadId><Initiated>%s</Initiated></Upload>
+<Upload><Key>b</Key><UploadId>2</UploadId><Initiated>%s</Initiated></Upload></ListMultipartUploadsResult>`,
+			now.Add(-48*time.Hour).Format(time.RFC3339), now.Add(-time.Hour).Format(time.RFC3339)),
+		fmt.Sprintf(`<ListMultipartUploadsResult><Bucket>bucket</Bucket><IsTruncated>false</IsTruncated>
+<Upload><Key>c</Key><UploadId>3</UploadId><Initiated>%[1]s</Initiated></Upload>
+<Upload><Key>d</Key><UploadId>4</UploadId><Initiated>%[1]s</Initiated></Upload>
+<Upload><Key>e</Key><UploadId>5</UploadId><Initiated>%[1]s</Initiated></Upload></ListMultipartUploadsResult>`,
+			now.Add(-72*time.Hour).Format(time.RFC3339)),
+	}
+
+	var (
+		mu      sync.Mutex
+		aborted []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifySigV4(r, "SuperSecretKey"); err != nil {
+			t.Error(err)
+		}
+		q := r.URL.Query()
+
+		switch {
+		case r.Method == http.MethodGet && q.Has("uploads"):
+			if q.Get("prefix") != "tmp/" {
+				t.Errorf("unexpected prefix %q", q.Get("prefix"))
+			}
+			page := 0
+			if q.Get("key-marker") == "b" && q.Get("upload-id-marker") == "2" {
+				page = 1
+			}
+			fmt.Fprint(w, pages[page])
+
+		case r.Method == http.MethodDelete:
+			switch q.Get("uploadId") {
+			case "4":
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+			case "5":
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `<Error><Code>NoSuchUpload</Code><Message>The specified upload does not exist</Message></Error>`)
+			default:
+				mu.Lock()
+				aborted = append(aborted, q.Get("uploadId"))
+				mu.Unlock()
+				w.WriteHeader(http.StatusNoContent)
+			}
+
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	var keys []string
+	uploads, finish := s3.ListAllMultipartUploads(ListMultipartUploadsInput{Bucket: "bucket", Prefix: "tmp/"})
+	for upload := range uploads {
+		keys = append(keys, upload.Key)
+	}
+	if err := finish(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(keys, ",") != "a,b,c,d,e" {
+		t.Errorf("unexpected uploads: %v", keys)
+	}
+
+	input := AbortStaleMultipartUploadsInput{
+		Bucket:    "bucket",
+		Prefix:    "tmp/",
+		OlderThan: 24 * time.Hour,
+		DryRun:    true,
+	}
+	out, err := s3.AbortStaleMultipartUploads(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Uploads) != 4 || len(aborted) != 0 {
+		t.Errorf("dry run: expected 4 stale uploads and none aborted, got %d and %v", len(out.Uploads), aborted)
+	}
+
+	input.DryRun = false
+	out, err = s3.AbortStaleMultipartUploads(input)
+	if !IsAccessDenied(err) {
+		t.Errorf("expected the failed abort to be returned, got %v", err)
+	}
+	if strings.Join(aborted, ",") != "1,3" || len(out.Uploads) != 3 {
+		t.Errorf("unexpected aborted uploads: %v, reported %d", aborted, len(out.Uploads))
+	}
+	if len(out.Failed) != 1 || !IsAccessDenied(out.Failed["4"]) {
+		t.Errorf("unexpected failures: %v", out.Failed)
+	}
+}
+
+func TestCopyObjectMultipart(t *testing.T) {
+	const size = 2*MinPartSize + 100
+
+	var (
+		mu     sync.Mutex
+		ranges = map[int]string{}
+		init   http.Header
+		tags   string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := verifySigV4(r, "SuperSecretKey")
+		if err != nil {
+			t.Error(err)
+		}
+		q := r.URL.Query()
+
+		switch {
+		case r.Method == http.MethodHead && r.URL.Path == "/src/big.bin":
+			w.Header().Set("Content-Length", strconv.Itoa(size))
+			w.Header().Set("Content-Type", "application/x-big")
+			w.Header().Set("ETag", `"source-etag"`)
+			w.Header().Set("X-Amz-Meta-Owner", "alice")
+
+		case r.Method == http.MethodGet && q.Has("tagging"):
+			fmt.Fprint(w, `<Tagging><TagSet><Tag><Key>env</Key><Value>prod</Value></Tag></TagSet></Tagging>`)
+
+		case r.Method == http.MethodPost && q.Has("uploads"):
+			init = r.Header.Clone()
+			fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>dst</Bucket><Key>copy.bin</Key><UploadId>upload</UploadId></InitiateMultipartUploadResult>`)
+
+		case r.Method == http.MethodPut && q.Has("partNumber"):
+			if r.Header.Get("x-amz-copy-source") != "/src/big.bin" || r.Header.Get("x-amz-copy-source-if-match") != `"source-etag"` {
+				t.Errorf("unexpected copy source headers: %v", r.Header)
+			}
+			n, _ := strconv.Atoi(q.Get("partNumber"))
+			mu.Lock()
+			ranges[n] = r.Header.Get("x-amz-copy-source-range")
+			mu.Unlock()
+			fmt.Fprintf(w, `<CopyPartResult><ETag>"part-%d"</ETag><LastModified>2024-01-01T00:00:00.000Z</LastModified></CopyPartResult>`, n)
+
+		case r.Method == http.MethodPost && q.Has("uploadId"):
+			var req completeMultipartUploadRequest
+			xml.Unmarshal(body, &req)
+			if len(req.Parts) != 3 || req.Parts[2].ETag != `"part-3"` {
+				t.Errorf("unexpected parts: %+v", req.Parts)
+			}
+			fmt.Fprint(w, `<CompleteMultipartUploadResult><Bucket>dst</Bucket><Key>copy.bin</Key><ETag>"copy-etag"</ETag></CompleteMultipartUploadResult>`)
+
+		case r.Method == http.MethodPut && q.Has("tagging"):
+			tags = string(body)
+
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	out, err := s3.CopyObjectMultipart(CopyObjectMultipartInput{
+		CopyObjectInput: CopyObjectInput{
+			SourceBucket:         "src",
+			SourceKey:            "big.bin",
+			DestBucket:           "dst",
+			DestKey:              "copy.bin",
+			ServerSideEncryption: "AES256",
+		},
+		PartSize:    MinPartSize,
+		Concurrency: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ETag != `"copy-etag"` {
+		t.Errorf("unexpected ETag %q", out.ETag)
+	}
+
+	want := map[int]string{
+		1: fmt.Sprintf("bytes=0-%d", MinPartSize-1),
+		2: fmt.Sprintf("bytes=%d-%d", MinPartSize, 2*MinPartSize-1),
+		3: fmt.Sprintf("bytes=%d-%d", 2*MinPartSize, size-1),
+	}
+	if fmt.Sprint(ranges) != fmt.Sprint(want) {
+		t.Errorf("got ranges %v, want %v", ranges, want)
+	}
+	if init.Get("Content-Type") != "application/x-big" || init.Get("x-amz-meta-owner") != "alice" ||
+		init.Get("x-amz-server-side-encryption") != "AES256" {
+		t.Errorf("metadata not preserved: %v", init)
+	}
+	if !strings.Contains(tags, "<Key>env</Key><Value>prod</Value>") {
+		t.Errorf("source tags not copied: %s", tags)
+	}
+}
+
+func TestMultipartPartSize(t *testing.T) {
+	if got := grownPartSize(DefaultPartSize, partSizeGrowthInterval); got != DefaultPartSize {
+		t.Errorf("part %d: got %d, want %d", partSizeGrowthInterval, got, DefaultPartSize)
+	}
+	if got := grownPartSize(DefaultPartSize, partSizeGrowthInterval+1); got != 2*DefaultPartSize {
+		t.Errorf("part %d: got %d, want %d", partSizeGrowthInterval+1, got, 2*DefaultPartSize)
+	}
+	if got := grownPartSize(MaxPartSize/2, MaxParts); got != MaxPartSize {
+		t.Errorf("part size grew past the maximum: %d", got)
+	}
+
+	// An upload of unknown size can reach ~4.8 TiB within MaxParts.
+	var total int64
+	for n := 1; n <= MaxParts; n++ {
+		total += grownPartSize(DefaultPartSize, n)
+	}
+	if total < 48<<40/10 {
+		t.Errorf("uploads of unknown size limited to %d bytes", total)
+	}
+
+	if got := minPartSize(MaxParts*DefaultPartSize + 1); got != DefaultPartSize+1024*1024 {
+		t.Errorf("got minimum part size %d", got)
+	}
+}
+
+// newCopyStub returns a server for CopyObjectMultipart whose source
+// has the headers source, and which records the headers of the
+// InitiateMultipartUpload request in init.
+func newCopyStub(t *testing.T, source map[string]string, init *http.Header) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifySigV4(r, "SuperSecretKey"); err != nil {
+			t.Error(err)
+		}
+		q := r.URL.Query()
+
+		switch {
+		case r.Method == http.MethodHead:
+			w.Header().Set("Content-Length", strconv.Itoa(MinPartSize+1))
+			w.Header().Set("ETag", `"source-etag"`)
+			for k, v := range source {
+				w.Header().Set(k, v)
+			}
+		case r.Method == http.MethodGet && q.Has("tagging"):
+			fmt.Fprint(w, `<Tagging><TagSet></TagSet></Tagging>`)
+		case r.Method == http.MethodPost && q.Has("uploads"):
+			*init = r.Header.Clone()
+			fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>upload</UploadId></InitiateMultipartUploadResult>`)
+		case r.Method == http.MethodPut && q.Has("partNumber"):
+			fmt.Fprint(w, `<CopyPartResult><ETag>"part"</ETag></CopyPartResult>`)
+		case r.Method == http.MethodPost && q.Has("uploadId"):
+			fmt.Fprint(w, `<CompleteMultipartUploadResult><ETag>"copy-etag"</ETag></CompleteMultipartUploadResult>`)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestCopyObjectMultipart_Headers(t *testing.T) {
+	var init http.Header
+	ts := newCopyStub(t, map[string]string{
+		"Cache-Control":       "max-age=60",
+		"Content-Disposition": "inline",
+		"Content-Encoding":    "gzip",
+		"Content-Language":    "fr",
+		"Expires":             "Wed, 02 Jan 2030 03:04:05 GMT",
+	}, &init)
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	tests := []struct {
+		name  string
+		input CopyObjectInput
+		want  map[string]string
+	}{
+		{"Copy", CopyObjectInput{}, map[string]string{
+			"Cache-Control":       "max-age=60",
+			"Content-Disposition": "inline",
+			"Content-Encoding":    "gzip",
+			"Content-Language":    "fr",
+			"Expires":             "Wed, 02 Jan 2030 03:04:05 GMT",
+		}},
+		{"Replace", CopyObjectInput{
+			MetadataDirective:  "REPLACE",
+			CacheControl:       "no-cache",
+			ContentDisposition: "attachment",
+			ContentLanguage:    "en",
+		}, map[string]string{
+			"Cache-Control":       "no-cache",
+			"Content-Disposition": "attachment",
+			"Content-Encoding":    "",
+			"Content-Language":    "en",
+			"Expires":             "",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.SourceBucket, tt.input.SourceKey = "src", "big.bin"
+			tt.input.DestBucket, tt.input.DestKey = "dst", "copy.bin"
+			if _, err := s3.CopyObjectMultipart(CopyObjectMultipartInput{CopyObjectInput: tt.input}); err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.want {
+				if got := init.Get(k); got != v {
+					t.Errorf("%s = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
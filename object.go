@@ -5,23 +5,96 @@ package simples3
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/xml"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// DownloadInput is passed to FileDownload and GetObject as a parameter.
 type DownloadInput struct {
 	Bucket    string
 	ObjectKey string
 	VersionId string // Optional: Version ID of the object to download
+
+	// Optional: 32 byte key the object is encrypted with (SSE-C)
+	SSECustomerKey []byte
+
+	// Optional: Byte range to download, e.g. "bytes=0-1023" or "bytes=-512"
+	Range string
+	// Optional: Part of a multipart object to download (1-10000)
+	PartNumber int
+
+	// Optional: Conditions the object must match to be downloaded.
+	// A failed IfMatch or IfUnmodifiedSince returns a precondition
+	// error; a failed IfNoneMatch or IfModifiedSince returns a
+	// 304 Not Modified response without a body.
+	IfMatch           string
+	IfNoneMatch       string
+	IfModifiedSince   time.Time
+	IfUnmodifiedSince time.Time
+
+	// Optional: Override the headers of the response
+	ResponseContentType        string
+	ResponseContentDisposition string
+	ResponseContentEncoding    string
+	ResponseContentLanguage    string
+	ResponseCacheControl       string
+	ResponseExpires            string
+
+	// Optional: Have S3 return the checksum of the object, if it has
+	// one, and check the body against it. Reading the body returns an
+	// error wrapping ErrChecksumMismatch if it is corrupted. Composite
+	// checksums of multipart objects and ranges can't be checked.
+	ValidateChecksum bool
+}
+
+// DownloadResponse is returned by GetObject.
+type DownloadResponse struct {
+	// Body of the object, or of the requested range. It must be closed.
+	// It is empty if StatusCode is 304 (Not Modified).
+	Body io.ReadCloser
+
+	// StatusCode is 200 (OK), 206 (Partial Content) for a range or
+	// part, or 304 (Not Modified) if IfNoneMatch or IfModifiedSince
+	// did not match.
+	StatusCode int
+
+	ContentLength int64
+	// ContentRange is the range returned, e.g. "bytes 0-1023/4096"
+	ContentRange string
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+	VersionId    string
+	// PartsCount is the number of parts of a multipart object,
+	// returned when PartNumber is set
+	PartsCount int
+
+	// Checksum of the object and its algorithm and type, returned
+	// when ValidateChecksum is set
+	Checksum          string
+	ChecksumAlgorithm string
+	ChecksumType      string
+
+	// Compression of the object, if it was uploaded with Compression.
+	// The body is then decompressed, and ContentLength is -1, unless a
+	// range or part was requested.
+	Compression string
+
+	AmzMeta map[string]string
+	Headers http.Header
 }
 
 // DetailsInput is passed to FileDetails as a parameter.
@@ -29,6 +102,15 @@ type DetailsInput struct {
 	Bucket    string
 	ObjectKey string
 	VersionId string // Optional: Version ID of the object to retrieve details for
+
+	// Optional: 32 byte key the object is encrypted with (SSE-C)
+	SSECustomerKey []byte
+
+	// Optional: Part of a multipart object to get the details of
+	// (1-10000), which has the number of parts returned in PartsCount
+	PartNumber int
+	// Optional: Have S3 return the checksum of the object, if it has one
+	IncludeChecksum bool
 }
 
 // DetailsResponse is returned by FileDetails.
@@ -45,9 +127,54 @@ type DetailsResponse struct {
 	// Server-side encryption (e.g. "AES256" or "aws:kms")
 	ServerSideEncryption string
 	// KMS Key ID (ARN or ID) when ServerSideEncryption is "aws:kms"
-	SSEKMSKeyId  string
+	SSEKMSKeyId string
+	// Algorithm and MD5 of the key the object is encrypted with (SSE-C)
+	SSECustomerAlgorithm string
+	SSECustomerKeyMD5    string
+	// Storage class of the object (x-amz-storage-class), which S3
+	// doesn't report for STANDARD objects
+	StorageClass string
+	// Status of the restore of an archived object (x-amz-restore), nil
+	// if no restore was requested
+	Restore *RestoreStatus
+	// Standard headers set when the object was written. Expires is zero
+	// if it isn't set or isn't a valid date.
+	CacheControl            string
+	ContentDisposition      string
+	ContentEncoding         string
+	ContentLanguage         string
+	Expires                 time.Time
+	WebsiteRedirectLocation string
+
+	// Parsed values of ContentLength, LastModified and Date. Size is -1
+	// if the length is unknown, and the times are zero if missing.
+	Size             int64
+	LastModifiedTime time.Time
+	DateTime         time.Time
+	// Version of the object, if versioning is enabled on the bucket, and
+	// whether it is a delete marker (see FileDetails)
+	VersionId    string
+	DeleteMarker bool
+	// Checksum of the object and its algorithm and type, returned when
+	// IncludeChecksum is set
+	Checksum          string
+	ChecksumAlgorithm string
+	ChecksumType      string
+	// Object Lock retention and legal hold of the object
+	ObjectLockMode            string
+	ObjectLockRetainUntilDate time.Time
+	ObjectLockLegalHoldStatus string
+	// Replication status (PENDING, COMPLETED, FAILED or REPLICA), if a
+	// replication rule applies to the object
+	ReplicationStatus string
+	// PartsCount is the number of parts of a multipart object,
+	// returned when PartNumber is set
+	PartsCount int
+
 	AmzMeta      map[string]string
 	ExtraHeaders map[string]string
+	// Headers of the response, including those parsed above
+	Headers http.Header
 }
 
 // UploadInput is passed to FileUpload as a parameter.
@@ -67,12 +194,74 @@ type UploadInput struct {
 	// Setting key/value pairs adds tags to the object (max 10 tags)
 	Tags map[string]string
 
+	// Standard headers returned when the object is downloaded.
+	// ContentEncoding is set by Compression, and can't be different.
+	CacheControl    string
+	ContentEncoding string
+	ContentLanguage string
+	Expires         time.Time
+	// Page requests for the object are redirected to, if the bucket is
+	// configured as a website
+	WebsiteRedirectLocation string
+
+	// Grants of permissions to the object (FilePut only), instead of
+	// ACL, as comma-separated grantees, e.g. `id="canonical-user-id"`
+	// or `uri="http://acs.amazonaws.com/groups/global/AllUsers"`
+	GrantRead        string
+	GrantReadACP     string
+	GrantWriteACP    string
+	GrantFullControl string
+
 	// Server-side encryption (e.g. "AES256" or "aws:kms")
 	ServerSideEncryption string
 	// KMS Key ID (ARN or ID) when ServerSideEncryption is "aws:kms"
 	SSEKMSKeyId string
+	// 32 byte key to encrypt the object with (SSE-C), instead of
+	// ServerSideEncryption. The same key is needed to read it.
+	SSECustomerKey []byte
+
+	// Conditional writes (FilePut only): IfNoneMatch "*" only creates
+	// the object if it doesn't exist, IfMatch only replaces it if its
+	// ETag matches. Failed conditions return an error for which
+	// IsPreconditionFailed is true, and concurrent conflicting writes
+	// one for which IsConditionalConflict is true.
+	IfNoneMatch string
+	IfMatch     string
+
+	// Storage class of the object, e.g. StorageClassStandardIA
+	StorageClass string
+
+	// Object Lock (FilePut only): retention mode (ObjectLockModeGovernance
+	// or ObjectLockModeCompliance) and date, and legal hold status
+	// (ObjectLockLegalHoldOn). Uploads with a retention are sent with a
+	// checksum, which S3 requires, using ChecksumCRC32 if
+	// ChecksumAlgorithm isn't set.
+	ObjectLockMode            string
+	ObjectLockRetainUntilDate time.Time
+	ObjectLockLegalHoldStatus string
+
+	// ChecksumAlgorithm (FilePut only), e.g. ChecksumCRC32C, has the
+	// checksum of the body computed and sent along with it, so that
+	// S3 rejects the upload if the data it receives is corrupted.
+	ChecksumAlgorithm string
+
+	// Compression (FilePut only), e.g. CompressionGzip, compresses the
+	// body before it is uploaded. The Content-Encoding of the object is
+	// set, and the compression is recorded in its metadata so that
+	// GetObject and FileDownload decompress it. The compressed body is
+	// buffered in memory.
+	Compression string
 
 	Body io.ReadSeeker
+
+	// Reader can be used by FilePut instead of Body to upload from a
+	// stream, such as a pipe or an HTTP request body, without buffering
+	// it. ContentLength must be set to the number of bytes it returns,
+	// unless it is an io.ReaderAt with a Size or Stat method.
+	// Readers that don't implement io.Seeker or io.ReaderAt are sent using
+	// aws-chunked encoding with signed chunks, and the request is not retried.
+	Reader        io.Reader
+	ContentLength int64
 }
 
 // UploadResponse receives the following XML
@@ -96,8 +285,10 @@ type UploadResponse struct {
 // and the service sends back an HTTP 200 response. The response
 // returns ETag along with HTTP headers.
 type PutResponse struct {
-	ETag    string
-	Headers http.Header
+	ETag string
+	// Checksum of the object, if ChecksumAlgorithm was set
+	Checksum string
+	Headers  http.Header
 }
 
 // DeleteInput is passed to FileDelete as a parameter.
@@ -105,79 +296,202 @@ type DeleteInput struct {
 	Bucket    string
 	ObjectKey string
 	VersionId string // Optional: Version ID of the object to delete
+
+	// Optional: Allow deleting a version retained in GOVERNANCE mode
+	BypassGovernanceRetention bool
 }
 
 // FileDownload makes a GET call and returns a io.ReadCloser.
 // After reading the response body, ensure closing the response.
+// Use GetObject to get the status and headers of the response, e.g.
+// to tell a 304 Not Modified response (with an empty body) apart.
 func (s3 *S3) FileDownload(u DownloadInput) (io.ReadCloser, error) {
+	return s3.FileDownloadWithContext(context.Background(), u)
+}
+
+// FileDownloadWithContext is like FileDownload but uses ctx for the request.
+func (s3 *S3) FileDownloadWithContext(ctx context.Context, u DownloadInput) (io.ReadCloser, error) {
+	res, err := s3.GetObjectWithContext(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+// GetObject makes a GET call like FileDownload, with support for ranges
+// and conditional requests, and returns the body along with the
+// response's status and headers.
+func (s3 *S3) GetObject(u DownloadInput) (DownloadResponse, error) {
+	return s3.GetObjectWithContext(context.Background(), u)
+}
+
+// GetObjectWithContext is like GetObject but uses ctx for the request.
+func (s3 *S3) GetObjectWithContext(ctx context.Context, u DownloadInput) (DownloadResponse, error) {
 	urlStr := s3.getURL(u.Bucket, u.ObjectKey)
 
+	q := url.Values{}
 	if u.VersionId != "" {
-		parsed, err := url.Parse(urlStr)
-		if err != nil {
-			return nil, err
-		}
-		q := parsed.Query()
 		q.Set("versionId", u.VersionId)
-		parsed.RawQuery = q.Encode()
-		urlStr = parsed.String()
+	}
+	if u.PartNumber > 0 {
+		q.Set("partNumber", strconv.Itoa(u.PartNumber))
+	}
+	for k, v := range map[string]string{
+		"response-content-type":        u.ResponseContentType,
+		"response-content-disposition": u.ResponseContentDisposition,
+		"response-content-encoding":    u.ResponseContentEncoding,
+		"response-content-language":    u.ResponseContentLanguage,
+		"response-cache-control":       u.ResponseCacheControl,
+		"response-expires":             u.ResponseExpires,
+	} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if len(q) > 0 {
+		urlStr += "?" + q.Encode()
 	}
 
-	req, err := http.NewRequest(
-		http.MethodGet, urlStr, nil,
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, urlStr, nil,
 	)
 	if err != nil {
-		return nil, err
+		return DownloadResponse{}, err
 	}
 
-	if err := s3.renewIAMToken(); err != nil {
-		return nil, err
+	if u.Range != "" {
+		req.Header.Set("Range", u.Range)
 	}
-	if err := s3.signRequest(req); err != nil {
-		return nil, err
+	if u.IfMatch != "" {
+		req.Header.Set("If-Match", u.IfMatch)
+	}
+	if u.IfNoneMatch != "" {
+		req.Header.Set("If-None-Match", u.IfNoneMatch)
+	}
+	if !u.IfModifiedSince.IsZero() {
+		req.Header.Set("If-Modified-Since", u.IfModifiedSince.UTC().Format(http.TimeFormat))
+	}
+	if !u.IfUnmodifiedSince.IsZero() {
+		req.Header.Set("If-Unmodified-Since", u.IfUnmodifiedSince.UTC().Format(http.TimeFormat))
+	}
+	if u.ValidateChecksum {
+		req.Header.Set("x-amz-checksum-mode", "ENABLED")
+		// The checksum is of the stored data: don't let the transport
+		// decompress it.
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+	if err := setSSECustomerKey(req.Header, u.SSECustomerKey); err != nil {
+		return DownloadResponse{}, err
 	}
 
-	res, err := s3.getClient().Do(req)
+	res, err := s3.do(req)
 	if err != nil {
-		return nil, err
+		return DownloadResponse{}, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status code: %s", res.Status)
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+	case http.StatusNotModified:
+		res.Body.Close()
+		res.Body = http.NoBody
+	default:
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return DownloadResponse{}, newResponseError("FileDownload", u.Bucket, u.ObjectKey, res, data)
+	}
+
+	out := DownloadResponse{
+		Body:          res.Body,
+		StatusCode:    res.StatusCode,
+		ContentLength: res.ContentLength,
+		ContentRange:  res.Header.Get("Content-Range"),
+		ContentType:   res.Header.Get("Content-Type"),
+		ETag:          res.Header.Get("ETag"),
+		VersionId:     res.Header.Get("x-amz-version-id"),
+		Headers:       res.Header,
+	}
+	if t, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil {
+		out.LastModified = t
+	}
+	if n, err := strconv.Atoi(res.Header.Get("x-amz-mp-parts-count")); err == nil {
+		out.PartsCount = n
+	}
+	out.ChecksumAlgorithm, out.Checksum = responseChecksum(res.Header)
+	out.ChecksumType = res.Header.Get("x-amz-checksum-type")
+	// Composite checksums, which end with the number of parts
+	// (e.g. "-3"), can't be checked against the body.
+	if u.ValidateChecksum && out.StatusCode == http.StatusOK && out.Checksum != "" &&
+		out.ChecksumType != ChecksumTypeComposite && !strings.Contains(out.Checksum, "-") {
+		sum, _ := newChecksum(out.ChecksumAlgorithm)
+		out.Body = &checksumReader{body: res.Body, hash: sum, expected: out.Checksum}
+	}
+	// Objects uploaded with Compression are decompressed, unless the
+	// transport already did (see http.Response.Uncompressed).
+	out.Compression = objectCompression(res.Header)
+	if out.Compression != "" && out.StatusCode == http.StatusOK && !res.Uncompressed {
+		c, err := compressorFor(out.Compression)
+		if err == nil {
+			out.Body, err = newDecompressReader(c, out.Body)
+		}
+		if err != nil {
+			res.Body.Close()
+			return DownloadResponse{}, fmt.Errorf("error decompressing %s/%s: %w", u.Bucket, u.ObjectKey, err)
+		}
+		out.ContentLength = -1
+	}
+	for k, v := range res.Header {
+		if strings.HasPrefix(strings.ToLower(k), AMZMetaPrefix) {
+			if out.AmzMeta == nil {
+				out.AmzMeta = map[string]string{}
+			}
+			out.AmzMeta[k] = getFirstString(v)
+		}
 	}
 
-	return res.Body, nil
+	return out, nil
 }
 
 // FilePut makes a PUT call to S3.
 func (s3 *S3) FilePut(u UploadInput) (PutResponse, error) {
-	fSize, err := detectFileSize(u.Body)
+	return s3.FilePutWithContext(context.Background(), u)
+}
+
+// FilePutWithContext is like FilePut but uses ctx for the request.
+func (s3 *S3) FilePutWithContext(ctx context.Context, u UploadInput) (PutResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s3.getURL(u.Bucket, u.ObjectKey), nil)
 	if err != nil {
 		return PutResponse{}, err
 	}
 
-	content := make([]byte, fSize)
-	_, err = u.Body.Read(content)
-	if err != nil {
-		return PutResponse{}, err
+	if u.ContentType == "" {
+		u.ContentType = "application/octet-stream"
 	}
-	u.Body.Seek(0, 0)
 
-	req, er := http.NewRequest(http.MethodPut, s3.getURL(u.Bucket, u.ObjectKey), u.Body)
-	if er != nil {
-		return PutResponse{}, err
+	if u.ObjectLockMode != "" && u.ChecksumAlgorithm == "" {
+		u.ChecksumAlgorithm = ChecksumCRC32
 	}
 
-	if u.ContentType == "" {
-		u.ContentType = "application/octet-stream"
+	encoding, err := contentEncoding(u.ContentEncoding, u.Compression)
+	if err != nil {
+		return PutResponse{}, err
+	}
+	if u.Compression != "" {
+		if err := compressPutBody(&u); err != nil {
+			return PutResponse{}, err
+		}
+	}
+	if encoding != "" {
+		// Set before the body, which may add aws-chunked to it.
+		req.Header.Set("Content-Encoding", encoding)
 	}
 
-	h := sha256.New()
-	h.Write(content)
-	req.Header.Set("x-amz-content-sha256", fmt.Sprintf("%x", h.Sum(nil)))
+	if err := setPutBody(req, u); err != nil {
+		return PutResponse{}, err
+	}
 
 	req.Header.Set("Content-Type", u.ContentType)
-	req.Header.Set("Content-Length", fmt.Sprintf("%d", fSize))
+	req.Header.Set("Content-Length", fmt.Sprintf("%d", req.ContentLength))
 	req.Header.Set("Host", req.URL.Host)
 
 	for k, v := range u.CustomMetadata {
@@ -187,35 +501,43 @@ func (s3 *S3) FilePut(u UploadInput) (PutResponse, error) {
 	if u.ContentDisposition != "" {
 		req.Header.Set("Content-Disposition", u.ContentDisposition)
 	}
+	setObjectHeaders(req.Header, u.CacheControl, u.ContentLanguage, u.Expires, u.WebsiteRedirectLocation)
 
 	if u.ACL != "" {
 		req.Header.Set("x-amz-acl", u.ACL)
 	}
+	setGrantHeaders(req.Header, u.GrantRead, u.GrantReadACP, u.GrantWriteACP, u.GrantFullControl)
 
 	if len(u.Tags) > 0 {
 		req.Header.Set("x-amz-tagging", encodeTagsHeader(u.Tags))
 	}
 
+	if u.StorageClass != "" {
+		req.Header.Set("x-amz-storage-class", u.StorageClass)
+	}
+
 	if u.ServerSideEncryption != "" {
 		req.Header.Set("x-amz-server-side-encryption", u.ServerSideEncryption)
 	}
 	if u.SSEKMSKeyId != "" {
 		req.Header.Set("x-amz-server-side-encryption-aws-kms-key-id", u.SSEKMSKeyId)
 	}
-
-	req.ContentLength = fSize
-
-	if err := s3.renewIAMToken(); err != nil {
+	if err := setSSECustomerKey(req.Header, u.SSECustomerKey); err != nil {
 		return PutResponse{}, err
 	}
-	if err := s3.signRequest(req); err != nil {
-		return PutResponse{}, err
+
+	if u.IfNoneMatch != "" {
+		req.Header.Set("If-None-Match", u.IfNoneMatch)
 	}
+	if u.IfMatch != "" {
+		req.Header.Set("If-Match", u.IfMatch)
+	}
+
+	setObjectLockHeaders(req.Header, u.ObjectLockMode, u.ObjectLockRetainUntilDate, u.ObjectLockLegalHoldStatus)
 
 	// debug(httputil.DumpRequest(req, true))
 	// Submit the request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return PutResponse{}, err
 	}
@@ -232,18 +554,209 @@ func (s3 *S3) FilePut(u UploadInput) (PutResponse, error) {
 
 	// Check the response
 	if res.StatusCode != http.StatusOK {
-		return PutResponse{}, fmt.Errorf("status code: %s: %q", res.Status, data)
+		return PutResponse{}, newResponseError("FilePut", u.Bucket, u.ObjectKey, res, data)
 	}
 
+	_, checksum := responseChecksum(res.Header)
 	return PutResponse{
-		ETag:    res.Header.Get("ETag"),
-		Headers: res.Header.Clone(),
+		ETag:     res.Header.Get("ETag"),
+		Checksum: checksum,
+		Headers:  res.Header.Clone(),
 	}, nil
 }
 
+// setObjectHeaders sets the standard headers of an object being written.
+// Content-Encoding is set apart, as it depends on its compression.
+func setObjectHeaders(h http.Header, cacheControl, contentLanguage string, expires time.Time, redirect string) {
+	if cacheControl != "" {
+		h.Set("Cache-Control", cacheControl)
+	}
+	if contentLanguage != "" {
+		h.Set("Content-Language", contentLanguage)
+	}
+	if !expires.IsZero() {
+		h.Set("Expires", expires.UTC().Format(http.TimeFormat))
+	}
+	if redirect != "" {
+		h.Set("x-amz-website-redirect-location", redirect)
+	}
+}
+
+// setGrantHeaders sets the x-amz-grant-* headers of an object being written.
+func setGrantHeaders(h http.Header, read, readACP, writeACP, fullControl string) {
+	if read != "" {
+		h.Set("x-amz-grant-read", read)
+	}
+	if readACP != "" {
+		h.Set("x-amz-grant-read-acp", readACP)
+	}
+	if writeACP != "" {
+		h.Set("x-amz-grant-write-acp", writeACP)
+	}
+	if fullControl != "" {
+		h.Set("x-amz-grant-full-control", fullControl)
+	}
+}
+
+// compressPutBody replaces the body of u with its compressed data, and
+// records the compression in its metadata.
+func compressPutBody(u *UploadInput) error {
+	c, err := compressorFor(u.Compression)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader
+	switch {
+	case u.Body != nil:
+		r = u.Body
+	case u.Reader != nil && u.ContentLength > 0:
+		r = io.LimitReader(u.Reader, u.ContentLength)
+	case u.Reader != nil:
+		// The body is buffered anyway, so its length isn't needed.
+		r = u.Reader
+	default:
+		return fmt.Errorf("body is required")
+	}
+
+	body, err := compressBody(c, r)
+	if err != nil {
+		return fmt.Errorf("error compressing body: %w", err)
+	}
+	u.Body, u.Reader = body, nil
+	u.CustomMetadata = withCompression(u.CustomMetadata, u.Compression)
+	return nil
+}
+
+// setPutBody sets the body of a FilePut request from u. Seekable bodies
+// are hashed in a first pass and streamed from disk, so they never have
+// to fit in memory. Other readers are streamed as they are, using
+// aws-chunked encoding with signed chunks, and their checksum, if any,
+// is sent in the trailer.
+func setPutBody(req *http.Request, u UploadInput) error {
+	var checksum hash.Hash
+	if u.ChecksumAlgorithm != "" {
+		var err error
+		if checksum, err = newChecksum(u.ChecksumAlgorithm); err != nil {
+			return err
+		}
+	}
+
+	body := u.Body
+	if body == nil && u.Reader != nil {
+		switch r := u.Reader.(type) {
+		case io.ReadSeeker:
+			body = r
+		case io.ReaderAt:
+			size, err := readerAtSize(r, u.ContentLength)
+			if err != nil {
+				return err
+			}
+			body = io.NewSectionReader(r, 0, size)
+		}
+	}
+
+	if body == nil {
+		if u.Reader == nil {
+			return fmt.Errorf("body is required")
+		}
+		if err := checkReaderLength(u.Reader, u.ContentLength); err != nil {
+			return err
+		}
+
+		// The body can't be rewound, so the request is sent only once.
+		req.Body = io.NopCloser(u.Reader)
+		trailer := ""
+		if checksum != nil {
+			trailer = checksumHeader(u.ChecksumAlgorithm)
+		}
+		return setStreamingPayload(req, u.ContentLength, trailer)
+	}
+
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	end, err := detectFileSize(body)
+	if err != nil {
+		return err
+	}
+	fSize := end - start
+
+	payloadHash, err := hashReadSeeker(body, checksum)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if checksum != nil {
+		req.Header.Set(checksumHeader(u.ChecksumAlgorithm), encodeChecksum(checksum))
+	}
+	if fSize == 0 {
+		req.Body = http.NoBody
+		return nil
+	}
+
+	// Let the body be rewound when the request is retried.
+	if err := setSeekableBody(req, body); err != nil {
+		return err
+	}
+	req.ContentLength = fSize
+	return nil
+}
+
+// readerAtSize returns the number of bytes to upload from r: length if
+// it is set, or else the size r reports. Readers that don't report
+// their size can only be uploaded without a length if they are empty.
+// checkReaderLength checks that length is set for a reader that can't
+// seek. A length of 0 is most likely unset: it is only accepted if the
+// reader is empty.
+func checkReaderLength(r io.Reader, length int64) error {
+	if length > 0 {
+		return nil
+	}
+	if length == 0 {
+		var p [1]byte
+		n, err := io.ReadFull(r, p[:])
+		if err == io.EOF {
+			return nil
+		}
+		if n == 0 && err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("content length is required when uploading from a reader")
+}
+
+func readerAtSize(r io.ReaderAt, length int64) (int64, error) {
+	if length > 0 {
+		return length, nil
+	}
+	switch r := r.(type) {
+	case interface{ Size() int64 }:
+		return r.Size(), nil
+	case interface{ Stat() (fs.FileInfo, error) }:
+		fi, err := r.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	}
+	var p [1]byte
+	if n, err := r.ReadAt(p[:], 0); n == 0 && err == io.EOF {
+		return 0, nil
+	}
+	return 0, fmt.Errorf("content length is required when uploading from a reader")
+}
+
 // FileUpload makes a POST call with the file written as multipart
 // and on successful upload, checks for 200 OK.
 func (s3 *S3) FileUpload(u UploadInput) (UploadResponse, error) {
+	return s3.FileUploadWithContext(context.Background(), u)
+}
+
+// FileUploadWithContext is like FileUpload but uses ctx for the request.
+func (s3 *S3) FileUploadWithContext(ctx context.Context, u UploadInput) (UploadResponse, error) {
 	fSize, err := detectFileSize(u.Body)
 	if err != nil {
 		return UploadResponse{}, err
@@ -257,6 +770,12 @@ func (s3 *S3) FileUpload(u UploadInput) (UploadResponse, error) {
 		ContentDisposition: u.ContentDisposition,
 		ACL:                u.ACL,
 		FileSize:           fSize,
+
+		CacheControl:            u.CacheControl,
+		ContentEncoding:         u.ContentEncoding,
+		ContentLanguage:         u.ContentLanguage,
+		Expires:                 u.Expires,
+		WebsiteRedirectLocation: u.WebsiteRedirectLocation,
 		MetaData: map[string]string{
 			"success_action_status": "201", // returns XML doc on success
 		},
@@ -276,6 +795,10 @@ func (s3 *S3) FileUpload(u UploadInput) (UploadResponse, error) {
 		uc.MetaData["x-amz-tagging"] = encodeTagsHeader(u.Tags)
 	}
 
+	if u.StorageClass != "" {
+		uc.MetaData["x-amz-storage-class"] = u.StorageClass
+	}
+
 	// Set server-side encryption
 	if u.ServerSideEncryption != "" {
 		uc.MetaData["x-amz-server-side-encryption"] = u.ServerSideEncryption
@@ -283,12 +806,16 @@ func (s3 *S3) FileUpload(u UploadInput) (UploadResponse, error) {
 	if u.SSEKMSKeyId != "" {
 		uc.MetaData["x-amz-server-side-encryption-aws-kms-key-id"] = u.SSEKMSKeyId
 	}
-
-	if err := s3.renewIAMToken(); err != nil {
-		return UploadResponse{}, err
+	if u.SSECustomerKey != nil {
+		if err := checkSSECustomerKey(u.SSECustomerKey); err != nil {
+			return UploadResponse{}, err
+		}
+		for k, v := range sseCustomerKeyHeaders("x-amz-", u.SSECustomerKey) {
+			uc.MetaData[k] = v
+		}
 	}
 
-	policies, err := s3.CreateUploadPolicies(uc)
+	policies, err := s3.CreateUploadPoliciesWithContext(ctx, uc)
 	if err != nil {
 		return UploadResponse{}, err
 	}
@@ -318,7 +845,7 @@ func (s3 *S3) FileUpload(u UploadInput) (UploadResponse, error) {
 	}
 
 	// Now that you have a form, you can submit it to your handler.
-	req, err := http.NewRequest(http.MethodPost, policies.URL, &b)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, policies.URL, &b)
 	if err != nil {
 		return UploadResponse{}, err
 	}
@@ -326,8 +853,8 @@ func (s3 *S3) FileUpload(u UploadInput) (UploadResponse, error) {
 	req.Header.Set("Content-Type", w.FormDataContentType())
 
 	// Submit the request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	// The form is signed by the policy, the request itself is not.
+	res, err := s3.send(req, false)
 	if err != nil {
 		return UploadResponse{}, err
 	}
@@ -344,7 +871,7 @@ func (s3 *S3) FileUpload(u UploadInput) (UploadResponse, error) {
 
 	// Check the response
 	if res.StatusCode != http.StatusCreated {
-		return UploadResponse{}, fmt.Errorf("status code: %s: %q", res.Status, data)
+		return UploadResponse{}, newResponseError("FileUpload", u.Bucket, u.ObjectKey, res, data)
 	}
 
 	var ur UploadResponse
@@ -355,6 +882,11 @@ func (s3 *S3) FileUpload(u UploadInput) (UploadResponse, error) {
 // FileDelete makes a DELETE call with the file written as multipart
 // and on successful upload, checks for 204 No Content.
 func (s3 *S3) FileDelete(u DeleteInput) error {
+	return s3.FileDeleteWithContext(context.Background(), u)
+}
+
+// FileDeleteWithContext is like FileDelete but uses ctx for the request.
+func (s3 *S3) FileDeleteWithContext(ctx context.Context, u DeleteInput) error {
 	urlStr := s3.getURL(u.Bucket, u.ObjectKey)
 
 	if u.VersionId != "" {
@@ -368,24 +900,18 @@ func (s3 *S3) FileDelete(u DeleteInput) error {
 		urlStr = parsed.String()
 	}
 
-	req, err := http.NewRequest(
-		http.MethodDelete, urlStr, nil,
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodDelete, urlStr, nil,
 	)
 	if err != nil {
 		return err
 	}
-
-	if err := s3.renewIAMToken(); err != nil {
-		return err
-	}
-
-	if err := s3.signRequest(req); err != nil {
-		return err
+	if u.BypassGovernanceRetention {
+		req.Header.Set("x-amz-bypass-governance-retention", "true")
 	}
 
 	// Submit the request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return err
 	}
@@ -397,42 +923,51 @@ func (s3 *S3) FileDelete(u DeleteInput) error {
 
 	// Check the response
 	if res.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("status code: %s", res.Status)
+		data, _ := io.ReadAll(res.Body)
+		return newResponseError("FileDelete", u.Bucket, u.ObjectKey, res, data)
 	}
 
 	return nil
 }
 
+// FileDetails makes a HEAD call and returns the object's headers.
+// If the object, or the requested version, is a delete marker, the
+// error, a *S3Error with DeleteMarker set (see IsDeleteMarker), is
+// returned along with details in which DeleteMarker, VersionId and
+// LastModifiedTime are set.
 func (s3 *S3) FileDetails(u DetailsInput) (DetailsResponse, error) {
+	return s3.FileDetailsWithContext(context.Background(), u)
+}
+
+// FileDetailsWithContext is like FileDetails but uses ctx for the request.
+func (s3 *S3) FileDetailsWithContext(ctx context.Context, u DetailsInput) (DetailsResponse, error) {
 	urlStr := s3.getURL(u.Bucket, u.ObjectKey)
 
+	q := url.Values{}
 	if u.VersionId != "" {
-		parsed, err := url.Parse(urlStr)
-		if err != nil {
-			return DetailsResponse{}, err
-		}
-		q := parsed.Query()
 		q.Set("versionId", u.VersionId)
-		parsed.RawQuery = q.Encode()
-		urlStr = parsed.String()
+	}
+	if u.PartNumber > 0 {
+		q.Set("partNumber", strconv.Itoa(u.PartNumber))
+	}
+	if len(q) > 0 {
+		urlStr += "?" + q.Encode()
 	}
 
-	req, err := http.NewRequest(
-		http.MethodHead, urlStr, nil,
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodHead, urlStr, nil,
 	)
 	if err != nil {
 		return DetailsResponse{}, err
 	}
-
-	if err := s3.renewIAMToken(); err != nil {
-		return DetailsResponse{}, err
+	if u.IncludeChecksum {
+		req.Header.Set("x-amz-checksum-mode", "ENABLED")
 	}
-
-	if err := s3.signRequest(req); err != nil {
+	if err := setSSECustomerKey(req.Header, u.SSECustomerKey); err != nil {
 		return DetailsResponse{}, err
 	}
 
-	res, err := s3.getClient().Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return DetailsResponse{}, err
 	}
@@ -443,13 +978,67 @@ func (s3 *S3) FileDetails(u DetailsInput) (DetailsResponse, error) {
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return DetailsResponse{}, fmt.Errorf("status code: %s", res.Status)
+		err := newResponseError("FileDetails", u.Bucket, u.ObjectKey, res, nil)
+		// HEAD of a delete marker fails (404, or 405 for a version), but
+		// tells it is one.
+		if res.Header.Get("x-amz-delete-marker") == "true" {
+			out := DetailsResponse{
+				VersionId:    res.Header.Get("x-amz-version-id"),
+				DeleteMarker: true,
+				Headers:      res.Header,
+			}
+			out.LastModifiedTime, _ = http.ParseTime(res.Header.Get("Last-Modified"))
+			return out, err
+		}
+		return DetailsResponse{}, err
+	}
+
+	out := DetailsResponse{
+		Size:                      res.ContentLength,
+		VersionId:                 res.Header.Get("x-amz-version-id"),
+		ChecksumType:              res.Header.Get("x-amz-checksum-type"),
+		ObjectLockMode:            res.Header.Get("x-amz-object-lock-mode"),
+		ObjectLockLegalHoldStatus: res.Header.Get("x-amz-object-lock-legal-hold"),
+		ReplicationStatus:         res.Header.Get("x-amz-replication-status"),
+		Headers:                   res.Header,
+	}
+	out.LastModifiedTime, _ = http.ParseTime(res.Header.Get("Last-Modified"))
+	out.DateTime, _ = http.ParseTime(res.Header.Get("Date"))
+	out.ChecksumAlgorithm, out.Checksum = responseChecksum(res.Header)
+	if t, err := time.Parse(time.RFC3339, res.Header.Get("x-amz-object-lock-retain-until-date")); err == nil {
+		out.ObjectLockRetainUntilDate = t
+	}
+	if n, err := strconv.Atoi(res.Header.Get("x-amz-mp-parts-count")); err == nil {
+		out.PartsCount = n
 	}
 
-	var out DetailsResponse
 	for k, v := range res.Header {
 		lk := strings.ToLower(k)
 
+		// These headers have a field, but are kept in ExtraHeaders too.
+		switch lk {
+		case "x-amz-server-side-encryption-customer-algorithm":
+			out.SSECustomerAlgorithm = getFirstString(v)
+		case "x-amz-server-side-encryption-customer-key-md5":
+			out.SSECustomerKeyMD5 = getFirstString(v)
+		case "x-amz-storage-class":
+			out.StorageClass = getFirstString(v)
+		case "x-amz-restore":
+			out.Restore = parseRestoreStatus(getFirstString(v))
+		case "cache-control":
+			out.CacheControl = getFirstString(v)
+		case "content-disposition":
+			out.ContentDisposition = getFirstString(v)
+		case "content-encoding":
+			out.ContentEncoding = getFirstString(v)
+		case "content-language":
+			out.ContentLanguage = getFirstString(v)
+		case "expires":
+			out.Expires, _ = http.ParseTime(getFirstString(v))
+		case "x-amz-website-redirect-location":
+			out.WebsiteRedirectLocation = getFirstString(v)
+		}
+
 		switch lk {
 		case "content-type":
 			out.ContentType = getFirstString(v)
@@ -518,6 +1107,23 @@ type CopyObjectInput struct {
 	// Optional: Custom metadata (only used when MetadataDirective = REPLACE)
 	CustomMetadata map[string]string
 
+	// Optional: Standard headers (only used when MetadataDirective = REPLACE)
+	ContentDisposition string
+	CacheControl       string
+	ContentEncoding    string
+	ContentLanguage    string
+	Expires            time.Time
+
+	// Optional: Website redirect of the destination, which isn't copied
+	WebsiteRedirectLocation string
+
+	// Optional: Grants of permissions to the destination, which doesn't
+	// get the ACL of the source (see UploadInput)
+	GrantRead        string
+	GrantReadACP     string
+	GrantWriteACP    string
+	GrantFullControl string
+
 	// Optional: Tags to set on the destination object (max 10 tags)
 	// When set, uses x-amz-tagging-directive: REPLACE
 	Tags map[string]string
@@ -527,6 +1133,33 @@ type CopyObjectInput struct {
 
 	// Optional: KMS Key ID (ARN or ID) when ServerSideEncryption is "aws:kms"
 	SSEKMSKeyId string
+
+	// Optional: 32 byte key to encrypt the destination with (SSE-C)
+	SSECustomerKey []byte
+	// Optional: 32 byte key the source is encrypted with (SSE-C)
+	CopySourceSSECustomerKey []byte
+
+	// Optional: Conditional write of the destination. IfNoneMatch "*"
+	// only copies if the destination doesn't exist, IfMatch only if
+	// its ETag matches.
+	IfNoneMatch string
+	IfMatch     string
+
+	// Optional: Copy only if the source matches these conditions
+	CopySourceIfMatch           string
+	CopySourceIfNoneMatch       string
+	CopySourceIfModifiedSince   time.Time
+	CopySourceIfUnmodifiedSince time.Time
+
+	// Optional: Storage class of the destination. It isn't copied from
+	// the source: the copy is STANDARD unless it is set. Copying an
+	// object onto itself with another storage class changes its class.
+	StorageClass string
+
+	// Optional: Object Lock retention and legal hold of the destination
+	ObjectLockMode            string
+	ObjectLockRetainUntilDate time.Time
+	ObjectLockLegalHoldStatus string
 }
 
 // CopyObjectOutput is returned by CopyObject.
@@ -541,10 +1174,37 @@ type copyObjectResult struct {
 	LastModified time.Time `xml:"LastModified"`
 }
 
+// setCopyConditions sets the headers of the conditions of a copy.
+func setCopyConditions(h http.Header, input CopyObjectInput) {
+	if input.IfNoneMatch != "" {
+		h.Set("If-None-Match", input.IfNoneMatch)
+	}
+	if input.IfMatch != "" {
+		h.Set("If-Match", input.IfMatch)
+	}
+	if input.CopySourceIfMatch != "" {
+		h.Set("x-amz-copy-source-if-match", input.CopySourceIfMatch)
+	}
+	if input.CopySourceIfNoneMatch != "" {
+		h.Set("x-amz-copy-source-if-none-match", input.CopySourceIfNoneMatch)
+	}
+	if !input.CopySourceIfModifiedSince.IsZero() {
+		h.Set("x-amz-copy-source-if-modified-since", input.CopySourceIfModifiedSince.UTC().Format(http.TimeFormat))
+	}
+	if !input.CopySourceIfUnmodifiedSince.IsZero() {
+		h.Set("x-amz-copy-source-if-unmodified-since", input.CopySourceIfUnmodifiedSince.UTC().Format(http.TimeFormat))
+	}
+}
+
 // CopyObject copies an object from source to destination.
 // Can copy within the same bucket or across buckets.
 // This operation is server-side, avoiding download/upload cycle.
 func (s3 *S3) CopyObject(input CopyObjectInput) (CopyObjectOutput, error) {
+	return s3.CopyObjectWithContext(context.Background(), input)
+}
+
+// CopyObjectWithContext is like CopyObject but uses ctx for the request.
+func (s3 *S3) CopyObjectWithContext(ctx context.Context, input CopyObjectInput) (CopyObjectOutput, error) {
 	// Validate required fields
 	if input.SourceBucket == "" || input.SourceKey == "" {
 		return CopyObjectOutput{}, fmt.Errorf("source bucket and key are required")
@@ -553,16 +1213,11 @@ func (s3 *S3) CopyObject(input CopyObjectInput) (CopyObjectOutput, error) {
 		return CopyObjectOutput{}, fmt.Errorf("destination bucket and key are required")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return CopyObjectOutput{}, err
-	}
-
 	// Build destination URL
 	url := s3.getURL(input.DestBucket, input.DestKey)
 
 	// Create PUT request
-	req, err := http.NewRequest(http.MethodPut, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, nil)
 	if err != nil {
 		return CopyObjectOutput{}, err
 	}
@@ -571,6 +1226,8 @@ func (s3 *S3) CopyObject(input CopyObjectInput) (CopyObjectOutput, error) {
 	copySource := "/" + input.SourceBucket + "/" + encodePath(input.SourceKey)
 	req.Header.Set("x-amz-copy-source", copySource)
 
+	setCopyConditions(req.Header, input)
+
 	// Optional metadata directive
 	if input.MetadataDirective != "" {
 		req.Header.Set("x-amz-metadata-directive", input.MetadataDirective)
@@ -584,7 +1241,18 @@ func (s3 *S3) CopyObject(input CopyObjectInput) (CopyObjectOutput, error) {
 		for k, v := range input.CustomMetadata {
 			req.Header.Set("x-amz-meta-"+k, v)
 		}
+		if input.ContentDisposition != "" {
+			req.Header.Set("Content-Disposition", input.ContentDisposition)
+		}
+		if input.ContentEncoding != "" {
+			req.Header.Set("Content-Encoding", input.ContentEncoding)
+		}
+		setObjectHeaders(req.Header, input.CacheControl, input.ContentLanguage, input.Expires, "")
 	}
+	if input.WebsiteRedirectLocation != "" {
+		req.Header.Set("x-amz-website-redirect-location", input.WebsiteRedirectLocation)
+	}
+	setGrantHeaders(req.Header, input.GrantRead, input.GrantReadACP, input.GrantWriteACP, input.GrantFullControl)
 
 	// Set tags if provided (with REPLACE directive to override source tags)
 	// Note: x-amz-tagging-directive causes signature errors in MinIO despite being supported in code
@@ -606,15 +1274,19 @@ func (s3 *S3) CopyObject(input CopyObjectInput) (CopyObjectOutput, error) {
 	if input.SSEKMSKeyId != "" {
 		req.Header.Set("x-amz-server-side-encryption-aws-kms-key-id", input.SSEKMSKeyId)
 	}
-
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
+	if err := setSSECustomerKey(req.Header, input.SSECustomerKey); err != nil {
+		return CopyObjectOutput{}, err
+	}
+	if err := setCopySourceSSECustomerKey(req.Header, input.CopySourceSSECustomerKey); err != nil {
 		return CopyObjectOutput{}, err
 	}
+	if input.StorageClass != "" {
+		req.Header.Set("x-amz-storage-class", input.StorageClass)
+	}
+	setObjectLockHeaders(req.Header, input.ObjectLockMode, input.ObjectLockRetainUntilDate, input.ObjectLockLegalHoldStatus)
 
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return CopyObjectOutput{}, err
 	}
@@ -632,7 +1304,7 @@ func (s3 *S3) CopyObject(input CopyObjectInput) (CopyObjectOutput, error) {
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		return CopyObjectOutput{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return CopyObjectOutput{}, newResponseError("CopyObject", input.DestBucket, input.DestKey, res, body)
 	}
 
 	// Parse XML response
@@ -643,7 +1315,7 @@ func (s3 *S3) CopyObject(input CopyObjectInput) (CopyObjectOutput, error) {
 
 	// Apply tags if provided (2-step workaround)
 	if len(input.Tags) > 0 {
-		err := s3.PutObjectTagging(PutObjectTaggingInput{
+		err := s3.PutObjectTaggingWithContext(ctx, PutObjectTaggingInput{
 			Bucket:    input.DestBucket,
 			ObjectKey: input.DestKey,
 			Tags:      input.Tags,
@@ -667,11 +1339,26 @@ type DeleteObjectsInput struct {
 	// Required: The name of the bucket
 	Bucket string
 
-	// Required: List of object keys to delete (max 1000)
+	// Required: List of object keys to delete (max 1000, along with
+	// Versions)
 	Objects []string
 
+	// Optional: Versions of objects to delete. Deleting an object
+	// without a version ID only adds a delete marker to it in a
+	// versioned bucket.
+	Versions []ObjectIdentifier
+
 	// Optional: Quiet mode - only return errors, not successes
 	Quiet bool
+
+	// Optional: Allow deleting versions retained in GOVERNANCE mode
+	BypassGovernanceRetention bool
+}
+
+// ObjectIdentifier identifies a version of an object to delete.
+type ObjectIdentifier struct {
+	Key       string `xml:"Key"`
+	VersionId string `xml:"VersionId,omitempty"`
 }
 
 // DeleteObjectsOutput is returned by DeleteObjects.
@@ -682,27 +1369,24 @@ type DeleteObjectsOutput struct {
 
 // DeletedObject represents a successfully deleted object.
 type DeletedObject struct {
-	Key string `xml:"Key"`
+	Key       string `xml:"Key"`
+	VersionId string `xml:"VersionId"`
 }
 
 // DeleteError represents a deletion error.
 type DeleteError struct {
-	Key     string `xml:"Key"`
-	Code    string `xml:"Code"`
-	Message string `xml:"Message"`
+	Key       string `xml:"Key"`
+	VersionId string `xml:"VersionId"`
+	Code      string `xml:"Code"`
+	Message   string `xml:"Message"`
 }
 
 // deleteRequest is the internal type for XML marshaling of the request.
 type deleteRequest struct {
-	XMLName xml.Name       `xml:"Delete"`
-	XMLNS   string         `xml:"xmlns,attr"`
-	Quiet   bool           `xml:"Quiet"`
-	Objects []deleteObject `xml:"Object"`
-}
-
-// deleteObject represents an object to delete in the XML request.
-type deleteObject struct {
-	Key string `xml:"Key"`
+	XMLName xml.Name           `xml:"Delete"`
+	XMLNS   string             `xml:"xmlns,attr"`
+	Quiet   bool               `xml:"Quiet"`
+	Objects []ObjectIdentifier `xml:"Object"`
 }
 
 // deleteResult is the internal type for XML parsing of the response.
@@ -715,31 +1399,32 @@ type deleteResult struct {
 // This is more efficient than calling FileDelete multiple times.
 // Returns both successful deletions and errors.
 func (s3 *S3) DeleteObjects(input DeleteObjectsInput) (DeleteObjectsOutput, error) {
+	return s3.DeleteObjectsWithContext(context.Background(), input)
+}
+
+// DeleteObjectsWithContext is like DeleteObjects but uses ctx for the request.
+func (s3 *S3) DeleteObjectsWithContext(ctx context.Context, input DeleteObjectsInput) (DeleteObjectsOutput, error) {
 	// Validate required fields
 	if input.Bucket == "" {
 		return DeleteObjectsOutput{}, fmt.Errorf("bucket name is required")
 	}
-	if len(input.Objects) == 0 {
+	if len(input.Objects)+len(input.Versions) == 0 {
 		return DeleteObjectsOutput{}, fmt.Errorf("at least one object key is required")
 	}
-	if len(input.Objects) > 1000 {
+	if len(input.Objects)+len(input.Versions) > 1000 {
 		return DeleteObjectsOutput{}, fmt.Errorf("cannot delete more than 1000 objects per request")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return DeleteObjectsOutput{}, err
-	}
-
 	// Build XML request body
 	deleteReq := deleteRequest{
 		XMLNS:   "http://s3.amazonaws.com/doc/2006-03-01/",
 		Quiet:   input.Quiet,
-		Objects: make([]deleteObject, len(input.Objects)),
+		Objects: make([]ObjectIdentifier, 0, len(input.Objects)+len(input.Versions)),
 	}
-	for i, key := range input.Objects {
-		deleteReq.Objects[i] = deleteObject{Key: key}
+	for _, key := range input.Objects {
+		deleteReq.Objects = append(deleteReq.Objects, ObjectIdentifier{Key: key})
 	}
+	deleteReq.Objects = append(deleteReq.Objects, input.Versions...)
 
 	xmlBody, err := xml.Marshal(deleteReq)
 	if err != nil {
@@ -761,7 +1446,7 @@ func (s3 *S3) DeleteObjects(input DeleteObjectsInput) (DeleteObjectsOutput, erro
 	parsedURL.RawQuery = "delete"
 
 	// Create POST request
-	req, err := http.NewRequest(http.MethodPost, parsedURL.String(), bytes.NewReader(xmlBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, parsedURL.String(), bytes.NewReader(xmlBody))
 	if err != nil {
 		return DeleteObjectsOutput{}, err
 	}
@@ -778,15 +1463,12 @@ func (s3 *S3) DeleteObjects(input DeleteObjectsInput) (DeleteObjectsOutput, erro
 	req.Header.Set("Content-MD5", contentMD5)
 	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(xmlBody)))
 	req.Header.Set("Host", req.URL.Host)
-
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return DeleteObjectsOutput{}, err
+	if input.BypassGovernanceRetention {
+		req.Header.Set("x-amz-bypass-governance-retention", "true")
 	}
 
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return DeleteObjectsOutput{}, err
 	}
@@ -804,7 +1486,7 @@ func (s3 *S3) DeleteObjects(input DeleteObjectsInput) (DeleteObjectsOutput, erro
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		return DeleteObjectsOutput{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return DeleteObjectsOutput{}, newResponseError("DeleteObjects", input.Bucket, "", res, body)
 	}
 
 	// Parse XML response
@@ -843,6 +1525,11 @@ type PutObjectAclInput struct {
 // PutObjectAcl sets the Access Control List (ACL) for an object.
 // You can either use a CannedACL OR provide a full AccessControlPolicy.
 func (s3 *S3) PutObjectAcl(input PutObjectAclInput) error {
+	return s3.PutObjectAclWithContext(context.Background(), input)
+}
+
+// PutObjectAclWithContext is like PutObjectAcl but uses ctx for the request.
+func (s3 *S3) PutObjectAclWithContext(ctx context.Context, input PutObjectAclInput) error {
 	// Validate input
 	if input.Bucket == "" {
 		return fmt.Errorf("bucket name is required")
@@ -854,11 +1541,6 @@ func (s3 *S3) PutObjectAcl(input PutObjectAclInput) error {
 		return fmt.Errorf("either CannedACL or AccessControlPolicy must be provided")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return err
-	}
-
 	// Build URL with ?acl query parameter
 	baseURL := s3.getURL(input.Bucket, input.ObjectKey)
 	parsedURL, err := url.Parse(baseURL)
@@ -901,7 +1583,7 @@ func (s3 *S3) PutObjectAcl(input PutObjectAclInput) error {
 		h.Write(xmlBody)
 		sha256Hash = fmt.Sprintf("%x", h.Sum(nil))
 
-		req, err = http.NewRequest(http.MethodPut, parsedURL.String(), bodyReader)
+		req, err = http.NewRequestWithContext(ctx, http.MethodPut, parsedURL.String(), bodyReader)
 		if err != nil {
 			return err
 		}
@@ -912,7 +1594,7 @@ func (s3 *S3) PutObjectAcl(input PutObjectAclInput) error {
 
 	} else {
 		// Use Canned ACL via header
-		req, err = http.NewRequest(http.MethodPut, parsedURL.String(), nil)
+		req, err = http.NewRequestWithContext(ctx, http.MethodPut, parsedURL.String(), nil)
 		if err != nil {
 			return err
 		}
@@ -925,14 +1607,8 @@ func (s3 *S3) PutObjectAcl(input PutObjectAclInput) error {
 
 	req.Header.Set("Host", req.URL.Host)
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return err
 	}
@@ -950,7 +1626,7 @@ func (s3 *S3) PutObjectAcl(input PutObjectAclInput) error {
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return newResponseError("PutObjectAcl", input.Bucket, input.ObjectKey, res, body)
 	}
 
 	return nil
@@ -970,6 +1646,11 @@ type GetObjectAclInput struct {
 
 // GetObjectAcl gets the Access Control List (ACL) for an object.
 func (s3 *S3) GetObjectAcl(input GetObjectAclInput) (AccessControlPolicy, error) {
+	return s3.GetObjectAclWithContext(context.Background(), input)
+}
+
+// GetObjectAclWithContext is like GetObjectAcl but uses ctx for the request.
+func (s3 *S3) GetObjectAclWithContext(ctx context.Context, input GetObjectAclInput) (AccessControlPolicy, error) {
 	// Validate input
 	if input.Bucket == "" {
 		return AccessControlPolicy{}, fmt.Errorf("bucket name is required")
@@ -978,11 +1659,6 @@ func (s3 *S3) GetObjectAcl(input GetObjectAclInput) (AccessControlPolicy, error)
 		return AccessControlPolicy{}, fmt.Errorf("object key is required")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return AccessControlPolicy{}, err
-	}
-
 	// Build URL with ?acl query parameter
 	baseURL := s3.getURL(input.Bucket, input.ObjectKey)
 	parsedURL, err := url.Parse(baseURL)
@@ -997,19 +1673,13 @@ func (s3 *S3) GetObjectAcl(input GetObjectAclInput) (AccessControlPolicy, error)
 	parsedURL.RawQuery = q.Encode()
 
 	// Create GET request
-	req, err := http.NewRequest(http.MethodGet, parsedURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
 	if err != nil {
 		return AccessControlPolicy{}, err
 	}
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return AccessControlPolicy{}, err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return AccessControlPolicy{}, err
 	}
@@ -1027,7 +1697,7 @@ func (s3 *S3) GetObjectAcl(input GetObjectAclInput) (AccessControlPolicy, error)
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		return AccessControlPolicy{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return AccessControlPolicy{}, newResponseError("GetObjectAcl", input.Bucket, input.ObjectKey, res, body)
 	}
 
 	// Parse XML response
@@ -0,0 +1,448 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Object Lock retention modes. Objects retained in GOVERNANCE mode can
+// be deleted or have their retention shortened by users allowed to
+// bypass it (see BypassGovernanceRetention); objects retained in
+// COMPLIANCE mode can't be until their retention expires.
+const (
+	ObjectLockModeGovernance = "GOVERNANCE"
+	ObjectLockModeCompliance = "COMPLIANCE"
+)
+
+// Object Lock legal hold statuses. An object under legal hold can't be
+// deleted until the hold is removed, regardless of its retention.
+const (
+	ObjectLockLegalHoldOn  = "ON"
+	ObjectLockLegalHoldOff = "OFF"
+)
+
+// ObjectLockConfiguration represents the Object Lock configuration of a
+// bucket, which can only be set on buckets created with Object Lock
+// enabled (see CreateBucketInput.ObjectLockEnabledForBucket).
+type ObjectLockConfiguration struct {
+	XMLName           xml.Name        `xml:"ObjectLockConfiguration"`
+	XMLNS             string          `xml:"xmlns,attr,omitempty"`
+	ObjectLockEnabled string          `xml:"ObjectLockEnabled,omitempty"` // Enabled
+	Rule              *ObjectLockRule `xml:"Rule,omitempty"`
+}
+
+// ObjectLockRule represents the retention applied by default to the new
+// objects of a bucket.
+type ObjectLockRule struct {
+	DefaultRetention DefaultRetention `xml:"DefaultRetention"`
+}
+
+// DefaultRetention represents a default retention period, of either
+// Days or Years.
+type DefaultRetention struct {
+	Mode  string `xml:"Mode"` // GOVERNANCE or COMPLIANCE
+	Days  int    `xml:"Days,omitempty"`
+	Years int    `xml:"Years,omitempty"`
+}
+
+// PutObjectLockConfigurationInput is passed to PutObjectLockConfiguration.
+type PutObjectLockConfigurationInput struct {
+	// Required: The name of the bucket
+	Bucket string
+
+	// Required: The Object Lock configuration
+	Configuration *ObjectLockConfiguration
+}
+
+// objectRetention is the internal type for XML marshaling/unmarshaling.
+type objectRetention struct {
+	XMLName         xml.Name   `xml:"Retention"`
+	XMLNS           string     `xml:"xmlns,attr,omitempty"`
+	Mode            string     `xml:"Mode,omitempty"`
+	RetainUntilDate *time.Time `xml:"RetainUntilDate,omitempty"`
+}
+
+// PutObjectRetentionInput is passed to PutObjectRetention as a parameter.
+type PutObjectRetentionInput struct {
+	// Required: The name of the bucket
+	Bucket string
+
+	// Required: The object key
+	ObjectKey string
+
+	// Optional: Version ID of the object, defaults to the latest one
+	VersionId string
+
+	// Required: GOVERNANCE or COMPLIANCE
+	Mode string
+
+	// Required: Date until which the object is retained
+	RetainUntilDate time.Time
+
+	// Optional: Allow shortening or removing a GOVERNANCE retention
+	BypassGovernanceRetention bool
+}
+
+// GetObjectRetentionInput is passed to GetObjectRetention as a parameter.
+type GetObjectRetentionInput struct {
+	// Required: The name of the bucket
+	Bucket string
+
+	// Required: The object key
+	ObjectKey string
+
+	// Optional: Version ID of the object, defaults to the latest one
+	VersionId string
+}
+
+// GetObjectRetentionOutput is returned by GetObjectRetention.
+type GetObjectRetentionOutput struct {
+	Mode            string
+	RetainUntilDate time.Time
+}
+
+// objectLegalHold is the internal type for XML marshaling/unmarshaling.
+type objectLegalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+	XMLNS   string   `xml:"xmlns,attr,omitempty"`
+	Status  string   `xml:"Status"`
+}
+
+// PutObjectLegalHoldInput is passed to PutObjectLegalHold as a parameter.
+type PutObjectLegalHoldInput struct {
+	// Required: The name of the bucket
+	Bucket string
+
+	// Required: The object key
+	ObjectKey string
+
+	// Optional: Version ID of the object, defaults to the latest one
+	VersionId string
+
+	// Required: ON or OFF
+	Status string
+}
+
+// GetObjectLegalHoldInput is passed to GetObjectLegalHold as a parameter.
+type GetObjectLegalHoldInput struct {
+	// Required: The name of the bucket
+	Bucket string
+
+	// Required: The object key
+	ObjectKey string
+
+	// Optional: Version ID of the object, defaults to the latest one
+	VersionId string
+}
+
+// GetObjectLegalHoldOutput is returned by GetObjectLegalHold.
+type GetObjectLegalHoldOutput struct {
+	Status string
+}
+
+// PutObjectLockConfiguration sets the Object Lock configuration of a
+// bucket, e.g. to retain its new objects by default.
+func (s3 *S3) PutObjectLockConfiguration(input PutObjectLockConfigurationInput) error {
+	return s3.PutObjectLockConfigurationWithContext(context.Background(), input)
+}
+
+// PutObjectLockConfigurationWithContext is like PutObjectLockConfiguration but uses ctx for the request.
+func (s3 *S3) PutObjectLockConfigurationWithContext(ctx context.Context, input PutObjectLockConfigurationInput) error {
+	// Validate input
+	if input.Bucket == "" {
+		return fmt.Errorf("bucket name is required")
+	}
+	if input.Configuration == nil {
+		return fmt.Errorf("object lock configuration is required")
+	}
+
+	config := *input.Configuration
+	config.XMLNS = "http://s3.amazonaws.com/doc/2006-03-01/"
+	if config.ObjectLockEnabled == "" {
+		config.ObjectLockEnabled = "Enabled"
+	}
+
+	return s3.putObjectLockXML(ctx, "PutObjectLockConfiguration", "object-lock", input.Bucket, "", "", config, nil)
+}
+
+// GetObjectLockConfiguration gets the Object Lock configuration of a bucket.
+func (s3 *S3) GetObjectLockConfiguration(bucket string) (ObjectLockConfiguration, error) {
+	return s3.GetObjectLockConfigurationWithContext(context.Background(), bucket)
+}
+
+// GetObjectLockConfigurationWithContext is like GetObjectLockConfiguration but uses ctx for the request.
+func (s3 *S3) GetObjectLockConfigurationWithContext(ctx context.Context, bucket string) (ObjectLockConfiguration, error) {
+	// Validate input
+	if bucket == "" {
+		return ObjectLockConfiguration{}, fmt.Errorf("bucket name is required")
+	}
+
+	// S3 returns 404 ObjectLockConfigurationNotFoundError for buckets
+	// without Object Lock, which IsNotFound reports as true.
+	var config ObjectLockConfiguration
+	if err := s3.getObjectLockXML(ctx, "GetObjectLockConfiguration", "object-lock", bucket, "", "", &config); err != nil {
+		return ObjectLockConfiguration{}, err
+	}
+	return config, nil
+}
+
+// PutObjectRetention sets the retention of an object, in a bucket with
+// Object Lock enabled.
+func (s3 *S3) PutObjectRetention(input PutObjectRetentionInput) error {
+	return s3.PutObjectRetentionWithContext(context.Background(), input)
+}
+
+// PutObjectRetentionWithContext is like PutObjectRetention but uses ctx for the request.
+func (s3 *S3) PutObjectRetentionWithContext(ctx context.Context, input PutObjectRetentionInput) error {
+	// Validate input
+	if input.Bucket == "" {
+		return fmt.Errorf("bucket name is required")
+	}
+	if input.ObjectKey == "" {
+		return fmt.Errorf("object key is required")
+	}
+	if input.Mode != ObjectLockModeGovernance && input.Mode != ObjectLockModeCompliance {
+		return fmt.Errorf("mode must be %q or %q", ObjectLockModeGovernance, ObjectLockModeCompliance)
+	}
+	if input.RetainUntilDate.IsZero() {
+		return fmt.Errorf("retain until date is required")
+	}
+
+	until := input.RetainUntilDate.UTC()
+	retention := objectRetention{
+		XMLNS:           "http://s3.amazonaws.com/doc/2006-03-01/",
+		Mode:            input.Mode,
+		RetainUntilDate: &until,
+	}
+
+	var header http.Header
+	if input.BypassGovernanceRetention {
+		header = http.Header{}
+		header.Set("x-amz-bypass-governance-retention", "true")
+	}
+	return s3.putObjectLockXML(ctx, "PutObjectRetention", "retention", input.Bucket, input.ObjectKey, input.VersionId, retention, header)
+}
+
+// GetObjectRetention gets the retention of an object.
+func (s3 *S3) GetObjectRetention(input GetObjectRetentionInput) (GetObjectRetentionOutput, error) {
+	return s3.GetObjectRetentionWithContext(context.Background(), input)
+}
+
+// GetObjectRetentionWithContext is like GetObjectRetention but uses ctx for the request.
+func (s3 *S3) GetObjectRetentionWithContext(ctx context.Context, input GetObjectRetentionInput) (GetObjectRetentionOutput, error) {
+	// Validate input
+	if input.Bucket == "" {
+		return GetObjectRetentionOutput{}, fmt.Errorf("bucket name is required")
+	}
+	if input.ObjectKey == "" {
+		return GetObjectRetentionOutput{}, fmt.Errorf("object key is required")
+	}
+
+	var retention objectRetention
+	if err := s3.getObjectLockXML(ctx, "GetObjectRetention", "retention", input.Bucket, input.ObjectKey, input.VersionId, &retention); err != nil {
+		return GetObjectRetentionOutput{}, err
+	}
+
+	out := GetObjectRetentionOutput{Mode: retention.Mode}
+	if retention.RetainUntilDate != nil {
+		out.RetainUntilDate = *retention.RetainUntilDate
+	}
+	return out, nil
+}
+
+// PutObjectLegalHold places or removes a legal hold on an object, in a
+// bucket with Object Lock enabled.
+func (s3 *S3) PutObjectLegalHold(input PutObjectLegalHoldInput) error {
+	return s3.PutObjectLegalHoldWithContext(context.Background(), input)
+}
+
+// PutObjectLegalHoldWithContext is like PutObjectLegalHold but uses ctx for the request.
+func (s3 *S3) PutObjectLegalHoldWithContext(ctx context.Context, input PutObjectLegalHoldInput) error {
+	// Validate input
+	if input.Bucket == "" {
+		return fmt.Errorf("bucket name is required")
+	}
+	if input.ObjectKey == "" {
+		return fmt.Errorf("object key is required")
+	}
+	if input.Status != ObjectLockLegalHoldOn && input.Status != ObjectLockLegalHoldOff {
+		return fmt.Errorf("status must be %q or %q", ObjectLockLegalHoldOn, ObjectLockLegalHoldOff)
+	}
+
+	legalHold := objectLegalHold{
+		XMLNS:  "http://s3.amazonaws.com/doc/2006-03-01/",
+		Status: input.Status,
+	}
+	return s3.putObjectLockXML(ctx, "PutObjectLegalHold", "legal-hold", input.Bucket, input.ObjectKey, input.VersionId, legalHold, nil)
+}
+
+// GetObjectLegalHold gets the legal hold status of an object.
+func (s3 *S3) GetObjectLegalHold(input GetObjectLegalHoldInput) (GetObjectLegalHoldOutput, error) {
+	return s3.GetObjectLegalHoldWithContext(context.Background(), input)
+}
+
+// GetObjectLegalHoldWithContext is like GetObjectLegalHold but uses ctx for the request.
+func (s3 *S3) GetObjectLegalHoldWithContext(ctx context.Context, input GetObjectLegalHoldInput) (GetObjectLegalHoldOutput, error) {
+	// Validate input
+	if input.Bucket == "" {
+		return GetObjectLegalHoldOutput{}, fmt.Errorf("bucket name is required")
+	}
+	if input.ObjectKey == "" {
+		return GetObjectLegalHoldOutput{}, fmt.Errorf("object key is required")
+	}
+
+	var legalHold objectLegalHold
+	if err := s3.getObjectLockXML(ctx, "GetObjectLegalHold", "legal-hold", input.Bucket, input.ObjectKey, input.VersionId, &legalHold); err != nil {
+		return GetObjectLegalHoldOutput{}, err
+	}
+	return GetObjectLegalHoldOutput{Status: legalHold.Status}, nil
+}
+
+// objectLockURL returns the URL of an Object Lock subresource of a
+// bucket ("object-lock"), or of an object if objectKey is set
+// ("retention" or "legal-hold").
+func (s3 *S3) objectLockURL(subresource, bucket, objectKey, versionId string) (string, error) {
+	baseURL := s3.getURL(bucket)
+	if objectKey != "" {
+		baseURL = s3.getURL(bucket, objectKey)
+	}
+	parsedURL, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	query.Set(subresource, "")
+	if versionId != "" {
+		query.Set("versionId", versionId)
+	}
+	parsedURL.RawQuery = query.Encode()
+	return parsedURL.String(), nil
+}
+
+// putObjectLockXML makes a PUT request sending v as XML to an Object
+// Lock subresource (see objectLockURL), with the given extra headers.
+func (s3 *S3) putObjectLockXML(ctx context.Context, op, subresource, bucket, objectKey, versionId string, v any, header http.Header) error {
+	xmlBody, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// Calculate Content-MD5 (required by S3 for these operations)
+	md5Hash := md5.Sum(xmlBody)
+	contentMD5 := base64.StdEncoding.EncodeToString(md5Hash[:])
+
+	urlStr, err := s3.objectLockURL(subresource, bucket, objectKey, versionId)
+	if err != nil {
+		return err
+	}
+
+	// Create PUT request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, urlStr, bytes.NewReader(xmlBody))
+	if err != nil {
+		return err
+	}
+
+	// Set headers
+	req.ContentLength = int64(len(xmlBody))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Content-MD5", contentMD5)
+
+	// Calculate SHA256
+	h := sha256.New()
+	h.Write(xmlBody)
+	req.Header.Set("x-amz-content-sha256", fmt.Sprintf("%x", h.Sum(nil)))
+	req.Header.Set("Host", req.URL.Host)
+
+	// Execute request
+	res, err := s3.do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		res.Body.Close()
+		io.Copy(io.Discard, res.Body)
+	}()
+
+	// Read response body
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	// Handle non-OK status codes
+	if res.StatusCode != http.StatusOK {
+		return newResponseError(op, bucket, objectKey, res, body)
+	}
+
+	return nil
+}
+
+// getObjectLockXML makes a GET request to an Object Lock subresource,
+// and parses the XML response into v.
+func (s3 *S3) getObjectLockXML(ctx context.Context, op, subresource, bucket, objectKey, versionId string, v any) error {
+	urlStr, err := s3.objectLockURL(subresource, bucket, objectKey, versionId)
+	if err != nil {
+		return err
+	}
+
+	// Create GET request
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return err
+	}
+
+	// Execute request
+	res, err := s3.do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		res.Body.Close()
+		io.Copy(io.Discard, res.Body)
+	}()
+
+	// Read response body
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	// Handle non-OK status codes
+	if res.StatusCode != http.StatusOK {
+		return newResponseError(op, bucket, objectKey, res, body)
+	}
+
+	// Parse XML response
+	return xml.Unmarshal(body, v)
+}
+
+// setObjectLockHeaders sets the headers of a write placing the object
+// under the given retention and legal hold, if any.
+func setObjectLockHeaders(h http.Header, mode string, retainUntil time.Time, legalHold string) {
+	if mode != "" {
+		h.Set("x-amz-object-lock-mode", mode)
+	}
+	if !retainUntil.IsZero() {
+		h.Set("x-amz-object-lock-retain-until-date", retainUntil.UTC().Format(time.RFC3339))
+	}
+	if legalHold != "" {
+		h.Set("x-amz-object-lock-legal-hold", legalHold)
+	}
+}
@@ -0,0 +1,272 @@
+package simples3
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObjectLock(t *testing.T) {
+	var (
+		gotReq  *http.Request
+		gotBody []byte
+		resBody string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := verifySigV4(r, "SuperSecretKey")
+		if err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodPut && r.URL.RawQuery != "" && r.Header.Get("Content-MD5") == "" {
+			t.Errorf("%s: Content-MD5 is required", r.URL)
+		}
+		gotReq, gotBody = r, body
+		io.WriteString(w, resBody)
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+	until := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("Configuration", func(t *testing.T) {
+		err := s3.PutObjectLockConfiguration(PutObjectLockConfigurationInput{
+			Bucket: "bucket",
+			Configuration: &ObjectLockConfiguration{
+				Rule: &ObjectLockRule{DefaultRetention: DefaultRetention{Mode: ObjectLockModeCompliance, Years: 7}},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !gotReq.URL.Query().Has("object-lock") {
+			t.Errorf("unexpected URL %s", gotReq.URL)
+		}
+		var sent ObjectLockConfiguration
+		if err := xml.Unmarshal(gotBody, &sent); err != nil {
+			t.Fatal(err)
+		}
+		if sent.ObjectLockEnabled != "Enabled" || sent.Rule.DefaultRetention.Years != 7 {
+			t.Errorf("unexpected configuration %s", gotBody)
+		}
+
+		resBody = string(gotBody)
+		config, err := s3.GetObjectLockConfiguration("bucket")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Rule == nil || config.Rule.DefaultRetention.Mode != ObjectLockModeCompliance {
+			t.Errorf("unexpected configuration %+v", config)
+		}
+	})
+
+	t.Run("Retention", func(t *testing.T) {
+		err := s3.PutObjectRetention(PutObjectRetentionInput{
+			Bucket:                    "bucket",
+			ObjectKey:                 "key",
+			VersionId:                 "v1",
+			Mode:                      ObjectLockModeGovernance,
+			RetainUntilDate:           until,
+			BypassGovernanceRetention: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := gotReq.URL.Query()
+		if gotReq.URL.Path != "/bucket/key" || !q.Has("retention") || q.Get("versionId") != "v1" {
+			t.Errorf("unexpected URL %s", gotReq.URL)
+		}
+		if gotReq.Header.Get("x-amz-bypass-governance-retention") != "true" {
+			t.Errorf("bypass header not sent")
+		}
+		if !strings.Contains(string(gotBody), "<RetainUntilDate>2030-01-02T03:04:05Z</RetainUntilDate>") {
+			t.Errorf("unexpected retention %s", gotBody)
+		}
+
+		resBody = `<Retention><Mode>GOVERNANCE</Mode><RetainUntilDate>2030-01-02T03:04:05.000Z</RetainUntilDate></Retention>`
+		out, err := s3.GetObjectRetention(GetObjectRetentionInput{Bucket: "bucket", ObjectKey: "key"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Mode != ObjectLockModeGovernance || !out.RetainUntilDate.Equal(until) {
+			t.Errorf("unexpected retention %+v", out)
+		}
+
+		if err := s3.PutObjectRetention(PutObjectRetentionInput{Bucket: "bucket", ObjectKey: "key", Mode: "FOREVER", RetainUntilDate: until}); err == nil {
+			t.Errorf("expected an invalid mode to be rejected")
+		}
+	})
+
+	t.Run("LegalHold", func(t *testing.T) {
+		err := s3.PutObjectLegalHold(PutObjectLegalHoldInput{Bucket: "bucket", ObjectKey: "key", Status: ObjectLockLegalHoldOn})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !gotReq.URL.Query().Has("legal-hold") || !strings.Contains(string(gotBody), "<Status>ON</Status>") {
+			t.Errorf("unexpected request %s: %s", gotReq.URL, gotBody)
+		}
+
+		resBody = `<LegalHold><Status>OFF</Status></LegalHold>`
+		out, err := s3.GetObjectLegalHold(GetObjectLegalHoldInput{Bucket: "bucket", ObjectKey: "key"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Status != ObjectLockLegalHoldOff {
+			t.Errorf("Status = %q, want OFF", out.Status)
+		}
+	})
+
+	resBody = ""
+	checkLock := func(t *testing.T, legalHold string) {
+		t.Helper()
+		if got := gotReq.Header.Get("x-amz-object-lock-mode"); got != ObjectLockModeCompliance {
+			t.Errorf("lock mode = %q", got)
+		}
+		if got := gotReq.Header.Get("x-amz-object-lock-retain-until-date"); got != "2030-01-02T03:04:05Z" {
+			t.Errorf("retain until date = %q", got)
+		}
+		if got := gotReq.Header.Get("x-amz-object-lock-legal-hold"); got != legalHold {
+			t.Errorf("legal hold = %q, want %q", got, legalHold)
+		}
+	}
+
+	t.Run("FilePut", func(t *testing.T) {
+		_, err := s3.FilePut(UploadInput{
+			Bucket:                    "bucket",
+			ObjectKey:                 "key",
+			Body:                      strings.NewReader("content"),
+			ObjectLockMode:            ObjectLockModeCompliance,
+			ObjectLockRetainUntilDate: until,
+			ObjectLockLegalHoldStatus: ObjectLockLegalHoldOn,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkLock(t, ObjectLockLegalHoldOn)
+		if gotReq.Header.Get("x-amz-checksum-crc32") == "" {
+			t.Errorf("expected a checksum to be sent with a retention")
+		}
+	})
+
+	t.Run("InitiateMultipartUpload", func(t *testing.T) {
+		resBody = `<InitiateMultipartUploadResult><UploadId>upload</UploadId></InitiateMultipartUploadResult>`
+		_, err := s3.InitiateMultipartUpload(InitiateMultipartUploadInput{
+			Bucket:                    "bucket",
+			ObjectKey:                 "key",
+			ObjectLockMode:            ObjectLockModeCompliance,
+			ObjectLockRetainUntilDate: until,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkLock(t, "")
+	})
+
+	t.Run("CopyObject", func(t *testing.T) {
+		resBody = `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`
+		_, err := s3.CopyObject(CopyObjectInput{
+			SourceBucket: "bucket", SourceKey: "source",
+			DestBucket: "bucket", DestKey: "dest",
+			ObjectLockMode:            ObjectLockModeCompliance,
+			ObjectLockRetainUntilDate: until,
+			ObjectLockLegalHoldStatus: ObjectLockLegalHoldOff,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkLock(t, ObjectLockLegalHoldOff)
+	})
+
+	t.Run("DeleteObjects", func(t *testing.T) {
+		resBody = `<DeleteResult><Deleted><Key>key</Key><VersionId>v1</VersionId></Deleted></DeleteResult>`
+		out, err := s3.DeleteObjects(DeleteObjectsInput{
+			Bucket:                    "bucket",
+			Objects:                   []string{"other"},
+			Versions:                  []ObjectIdentifier{{Key: "key", VersionId: "v1"}},
+			BypassGovernanceRetention: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotReq.Header.Get("x-amz-bypass-governance-retention") != "true" {
+			t.Errorf("bypass header not sent")
+		}
+		var sent deleteRequest
+		if err := xml.Unmarshal(gotBody, &sent); err != nil {
+			t.Fatal(err)
+		}
+		want := []ObjectIdentifier{{Key: "other"}, {Key: "key", VersionId: "v1"}}
+		if fmt.Sprint(sent.Objects) != fmt.Sprint(want) {
+			t.Errorf("sent objects %+v, want %+v", sent.Objects, want)
+		}
+		if strings.Count(string(gotBody), "<VersionId>") != 1 {
+			t.Errorf("unexpected request body %s", gotBody)
+		}
+		if len(out.Deleted) != 1 || out.Deleted[0].VersionId != "v1" {
+			t.Errorf("unexpected result %+v", out)
+		}
+	})
+}
+
+func TestObjectLock_DeleteAndCreateBucket(t *testing.T) {
+	var gotReq *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifySigV4(r, "SuperSecretKey"); err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		gotReq = r
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	if err := s3.FileDelete(DeleteInput{Bucket: "bucket", ObjectKey: "key", VersionId: "v1", BypassGovernanceRetention: true}); err != nil {
+		t.Fatal(err)
+	}
+	if gotReq.Header.Get("x-amz-bypass-governance-retention") != "true" {
+		t.Errorf("bypass header not sent")
+	}
+
+	if _, err := s3.CreateBucket(CreateBucketInput{Bucket: "bucket", ObjectLockEnabledForBucket: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := gotReq.Header.Get("x-amz-bucket-object-lock-enabled"); got != "true" {
+		t.Errorf("object lock header = %q", got)
+	}
+}
+
+func TestObjectLock_CopyObjectMultipart(t *testing.T) {
+	var init http.Header
+	ts := newCopyStub(t, nil, &init)
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	_, err := s3.CopyObjectMultipart(CopyObjectMultipartInput{CopyObjectInput: CopyObjectInput{
+		SourceBucket: "src", SourceKey: "big.bin",
+		DestBucket: "dst", DestKey: "copy.bin",
+		ObjectLockMode:            ObjectLockModeCompliance,
+		ObjectLockRetainUntilDate: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		ObjectLockLegalHoldStatus: ObjectLockLegalHoldOn,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if init.Get("x-amz-object-lock-mode") != ObjectLockModeCompliance ||
+		init.Get("x-amz-object-lock-retain-until-date") != "2030-01-02T03:04:05Z" ||
+		init.Get("x-amz-object-lock-legal-hold") != ObjectLockLegalHoldOn {
+		t.Errorf("object lock not set on the copy: %v", init)
+	}
+}
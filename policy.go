@@ -9,12 +9,14 @@ package simples3
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"time"
 )
 
@@ -32,6 +34,12 @@ type UploadConfig struct {
 	UploadURL  string
 	Expiration time.Duration
 	MetaData   map[string]string
+	// Optional standard headers of the object
+	CacheControl            string
+	ContentEncoding         string
+	ContentLanguage         string
+	Expires                 time.Time
+	WebsiteRedirectLocation string
 }
 
 // UploadPolicies Amazon s3 upload policies.
@@ -68,16 +76,27 @@ var newLine = []byte{'\n'} //nolint
 // policy and signing keys with the signature returns the upload policy.
 // https://docs.aws.amazon.com/ja_jp/AmazonS3/latest/API/sigv4-authentication-HTTPPOST.html
 func (s3 *S3) CreateUploadPolicies(uploadConfig UploadConfig) (UploadPolicies, error) {
+	return s3.CreateUploadPoliciesWithContext(context.Background(), uploadConfig)
+}
+
+// CreateUploadPoliciesWithContext is like CreateUploadPolicies but uses
+// ctx if credentials need to be retrieved from the client's provider.
+func (s3 *S3) CreateUploadPoliciesWithContext(ctx context.Context, uploadConfig UploadConfig) (UploadPolicies, error) {
+	creds, err := s3.credentials(ctx)
+	if err != nil {
+		return UploadPolicies{}, err
+	}
+
 	nowTime := nowTime()
-	credential := string(s3.buildCredential(nowTime))
-	data, err := buildUploadSign(nowTime, credential, uploadConfig)
+	credential := string(s3.buildCredential(nowTime, creds.AccessKeyID))
+	data, err := buildUploadSign(nowTime, credential, creds.SessionToken, uploadConfig)
 	if err != nil {
 		return UploadPolicies{}, err
 	}
 	// 1. StringToSign
 	policy := base64.StdEncoding.EncodeToString(data)
 	// 2. Signing Key
-	hash := hmac.New(sha256.New, buildSignature(nowTime, s3.SecretKey, s3.Region, serviceName))
+	hash := hmac.New(sha256.New, buildSignature(nowTime, creds.SecretAccessKey, s3.Region, serviceName))
 	hash.Write([]byte(policy))
 	// 3. Signature
 	signature := hex.EncodeToString(hash.Sum(nil))
@@ -99,6 +118,10 @@ func (s3 *S3) CreateUploadPolicies(uploadConfig UploadConfig) (UploadPolicies, e
 	}
 
 	// optional fields
+	if creds.SessionToken != "" {
+		form["X-Amz-Security-Token"] = creds.SessionToken
+	}
+
 	if uploadConfig.ContentDisposition != "" {
 		form["Content-Disposition"] = uploadConfig.ContentDisposition
 	}
@@ -107,6 +130,10 @@ func (s3 *S3) CreateUploadPolicies(uploadConfig UploadConfig) (UploadPolicies, e
 		form["acl"] = uploadConfig.ACL
 	}
 
+	for k, v := range uploadConfig.headerFields() {
+		form[k] = v
+	}
+
 	for k, v := range uploadConfig.MetaData {
 		form[k] = v
 	}
@@ -116,7 +143,7 @@ func (s3 *S3) CreateUploadPolicies(uploadConfig UploadConfig) (UploadPolicies, e
 	}, nil
 }
 
-func buildUploadSign(nowTime time.Time, credential string, uploadConfig UploadConfig) ([]byte, error) {
+func buildUploadSign(nowTime time.Time, credential, token string, uploadConfig UploadConfig) ([]byte, error) {
 	// essential conditions
 	conditions := []interface{}{
 		map[string]string{"bucket": uploadConfig.BucketName},
@@ -129,6 +156,10 @@ func buildUploadSign(nowTime time.Time, credential string, uploadConfig UploadCo
 	}
 
 	// optional conditions
+	if token != "" {
+		conditions = append(conditions, map[string]string{"x-amz-security-token": token})
+	}
+
 	if uploadConfig.ContentDisposition != "" {
 		conditions = append(conditions, map[string]string{"Content-Disposition": uploadConfig.ContentDisposition})
 	}
@@ -137,6 +168,10 @@ func buildUploadSign(nowTime time.Time, credential string, uploadConfig UploadCo
 		conditions = append(conditions, map[string]string{"acl": uploadConfig.ACL})
 	}
 
+	for k, v := range uploadConfig.headerFields() {
+		conditions = append(conditions, map[string]string{k: v})
+	}
+
 	for k, v := range uploadConfig.MetaData {
 		conditions = append(conditions, map[string]string{k: v})
 	}
@@ -152,9 +187,30 @@ func buildUploadSign(nowTime time.Time, credential string, uploadConfig UploadCo
 	})
 }
 
-func (s3 *S3) buildCredential(nowTime time.Time) []byte {
+// headerFields returns the form fields of the optional standard headers.
+func (uc UploadConfig) headerFields() map[string]string {
+	fields := map[string]string{}
+	if uc.CacheControl != "" {
+		fields["Cache-Control"] = uc.CacheControl
+	}
+	if uc.ContentEncoding != "" {
+		fields["Content-Encoding"] = uc.ContentEncoding
+	}
+	if uc.ContentLanguage != "" {
+		fields["Content-Language"] = uc.ContentLanguage
+	}
+	if !uc.Expires.IsZero() {
+		fields["Expires"] = uc.Expires.UTC().Format(http.TimeFormat)
+	}
+	if uc.WebsiteRedirectLocation != "" {
+		fields["x-amz-website-redirect-location"] = uc.WebsiteRedirectLocation
+	}
+	return fields
+}
+
+func (s3 *S3) buildCredential(nowTime time.Time, accessKey string) []byte {
 	var b bytes.Buffer
-	b.WriteString(s3.AccessKey)
+	b.WriteString(accessKey)
 	b.WriteRune('/')
 	b.WriteString(nowTime.Format(shortTimeFormat))
 	b.WriteRune('/')
@@ -2,6 +2,7 @@ package simples3
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"net/url"
@@ -28,6 +29,11 @@ type PresignedInput struct {
 	ExtraHeaders               map[string]string
 	ExpirySeconds              int
 	ResponseContentDisposition string
+
+	// SSECustomerKey is the 32 byte key the object is encrypted with
+	// (SSE-C). Its headers are signed, and must be sent along with the
+	// request made using the URL (see SSECustomerKeyHeaders).
+	SSECustomerKey []byte
 }
 
 // awsURIEncode encodes a string per AWS S3 requirements (space as %20, not +, as Go's url.QueryEscape does)
@@ -40,7 +46,11 @@ func awsURIEncode(s string) string {
 // for Authentication using Query Parameters.
 // (https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html)
 func (s3 *S3) GeneratePresignedURL(in PresignedInput) string {
-	if err := s3.renewIAMToken(); err != nil {
+	creds, err := s3.credentials(context.Background())
+	if err != nil {
+		return ""
+	}
+	if err := checkSSECustomerKey(in.SSECustomerKey); err != nil {
 		return ""
 	}
 
@@ -58,7 +68,7 @@ func (s3 *S3) GeneratePresignedURL(in PresignedInput) string {
 
 	// Create cred
 	b := bytes.Buffer{}
-	b.WriteString(s3.AccessKey)
+	b.WriteString(creds.AccessKeyID)
 	b.WriteRune('/')
 	b.Write(s3.buildCredentialWithoutKey(nowTime))
 	cred := b.Bytes()
@@ -82,6 +92,11 @@ func (s3 *S3) GeneratePresignedURL(in PresignedInput) string {
 	for k, v := range in.ExtraHeaders {
 		signedHeaders[k] = []byte(v)
 	}
+	if in.SSECustomerKey != nil {
+		for k, v := range SSECustomerKeyHeaders(in.SSECustomerKey) {
+			signedHeaders[k] = []byte(v)
+		}
+	}
 	signedHeaders["host"] = []byte(hostname)
 
 	// Build signed headers string
@@ -126,8 +141,8 @@ func (s3 *S3) GeneratePresignedURL(in PresignedInput) string {
 	}
 
 	//  include the x-amz-security-token incase we are using IAM role or AWS STS
-	if s3.Token != "" {
-		queryString["X-Amz-Security-Token"] = s3.Token
+	if creds.SessionToken != "" {
+		queryString["X-Amz-Security-Token"] = creds.SessionToken
 	}
 
 	// We need to have a sorted order,
@@ -192,7 +207,7 @@ func (s3 *S3) GeneratePresignedURL(in PresignedInput) string {
 	sigKey := makeHMac(makeHMac(
 		makeHMac(
 			makeHMac(
-				[]byte("AWS4"+s3.SecretKey),
+				[]byte("AWS4"+creds.SecretAccessKey),
 				[]byte(nowTime.UTC().Format(shortTimeFormat))),
 			[]byte(s3.Region)),
 		[]byte("s3")),
@@ -248,6 +263,10 @@ type PresignedMultipartInput struct {
 	UploadID      string // Required: upload ID from InitiateMultipartUpload
 	PartNumber    int    // Required: part number (1-10000)
 	ExpirySeconds int    // Optional: default 3600
+
+	// Optional: SSE-C key the upload was initiated with. Its headers
+	// must be sent along with the part (see SSECustomerKeyHeaders).
+	SSECustomerKey []byte
 }
 
 // GeneratePresignedUploadPartURL generates a presigned URL for uploading a specific part
@@ -257,7 +276,11 @@ func (s3 *S3) GeneratePresignedUploadPartURL(in PresignedMultipartInput) string
 		in.ExpirySeconds = 3600
 	}
 
-	if err := s3.renewIAMToken(); err != nil {
+	creds, err := s3.credentials(context.Background())
+	if err != nil {
+		return ""
+	}
+	if err := checkSSECustomerKey(in.SSECustomerKey); err != nil {
 		return ""
 	}
 
@@ -273,7 +296,7 @@ func (s3 *S3) GeneratePresignedUploadPartURL(in PresignedMultipartInput) string
 
 	// Create cred
 	b := bytes.Buffer{}
-	b.WriteString(s3.AccessKey)
+	b.WriteString(creds.AccessKeyID)
 	b.WriteRune('/')
 	b.Write(s3.buildCredentialWithoutKey(nowTime))
 	cred := b.Bytes()
@@ -296,6 +319,11 @@ func (s3 *S3) GeneratePresignedUploadPartURL(in PresignedMultipartInput) string
 	signedHeaders := map[string][]byte{
 		"host": []byte(hostname),
 	}
+	if in.SSECustomerKey != nil {
+		for k, v := range SSECustomerKeyHeaders(in.SSECustomerKey) {
+			signedHeaders[k] = []byte(v)
+		}
+	}
 
 	// Build signed headers string
 	sortedSH := make([]string, 0, len(signedHeaders))
@@ -335,8 +363,8 @@ func (s3 *S3) GeneratePresignedUploadPartURL(in PresignedMultipartInput) string
 	}
 
 	// Include the x-amz-security-token in case we are using IAM role or AWS STS
-	if s3.Token != "" {
-		queryString["X-Amz-Security-Token"] = s3.Token
+	if creds.SessionToken != "" {
+		queryString["X-Amz-Security-Token"] = creds.SessionToken
 	}
 
 	// We need to have a sorted order for QueryStrings and SignedHeaders
@@ -395,7 +423,7 @@ func (s3 *S3) GeneratePresignedUploadPartURL(in PresignedMultipartInput) string
 	sigKey := makeHMac(makeHMac(
 		makeHMac(
 			makeHMac(
-				[]byte("AWS4"+s3.SecretKey),
+				[]byte("AWS4"+creds.SecretAccessKey),
 				[]byte(nowTime.UTC().Format(shortTimeFormat))),
 			[]byte(s3.Region)),
 		[]byte("s3")),
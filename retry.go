@@ -0,0 +1,327 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how requests that fail with a transient
+// error (throttling, 5xx responses, timeouts, connection resets)
+// are retried. It is applied to every operation made by the client.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts made for a
+	// single request, including the first one. Values <= 1 disable retries.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. It is doubled
+	// for every subsequent retry, up to MaxDelay.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the delay between two attempts. A request is not
+	// retried if the response asks, with Retry-After, to wait longer.
+	MaxDelay time.Duration
+
+	// NoJitter disables randomisation of the backoff delay. By default
+	// each delay is picked at random between half and all of its value
+	// to avoid retrying clients hitting S3 in lockstep.
+	NoJitter bool
+
+	// Retryable decides whether a failed attempt should be retried.
+	// Failed responses are passed in as *S3Error. Defaults to IsRetryable.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy returns the RetryPolicy used by clients that
+// have not been configured using SetRetryPolicy.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: DefaultMaxRetries + 1,
+		BaseDelay:   DefaultRetryBaseWait,
+		MaxDelay:    DefaultRetryMaxWait,
+	}
+}
+
+// SetRetryPolicy sets the retry policy used for all requests
+// made by the client. Use RetryPolicy{MaxAttempts: 1} to disable retries.
+func (s3 *S3) SetRetryPolicy(policy RetryPolicy) *S3 {
+	s3.retryPolicy = &policy
+	return s3
+}
+
+// getRetryPolicy returns the retry policy for a request made with ctx.
+func (s3 *S3) getRetryPolicy(ctx context.Context) RetryPolicy {
+	if p, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); ok {
+		return p
+	}
+	if s3.retryPolicy != nil {
+		return *s3.retryPolicy
+	}
+	return DefaultRetryPolicy()
+}
+
+// retryPolicyKey is the context key used to override the
+// client's retry policy for a single call.
+type retryPolicyKey struct{}
+
+// withRetryPolicy returns a context that makes requests use p
+// instead of the client's retry policy.
+func withRetryPolicy(ctx context.Context, p RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, p)
+}
+
+// backoff returns the delay before the given retry (starting at 1).
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if !p.NoJitter && d > 1 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	return d
+}
+
+// retryableCodes are S3 error codes that indicate a transient
+// condition, regardless of the HTTP status they are returned with.
+var retryableCodes = map[string]bool{
+	"SlowDown":                   true,
+	"Throttling":                 true,
+	"ThrottlingException":        true,
+	"RequestLimitExceeded":       true,
+	"RequestThrottled":           true,
+	"RequestTimeout":             true,
+	"RequestTimeTooSkewed":       true,
+	"InternalError":              true,
+	"ServiceUnavailable":         true,
+	"OperationAborted":           true,
+	"BandwidthLimitExceeded":     true,
+	"ProvisionedThroughputError": true,
+}
+
+// IsRetryable reports whether err is a transient failure that is
+// worth retrying: throttling and timeout error codes, 5xx and 429
+// responses, network timeouts and dropped connections.
+// It is the default RetryPolicy.Retryable.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if e, ok := asS3Error(err); ok {
+		if retryable, ok := retryableCodes[e.Code]; ok {
+			return retryable
+		}
+		return e.StatusCode == http.StatusTooManyRequests ||
+			e.StatusCode >= http.StatusInternalServerError
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryAfter parses the Retry-After header of res, which holds
+// either a number of seconds or an HTTP date.
+func retryAfter(res *http.Response) time.Duration {
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// setSeekableBody sets body as the body of req and lets it be
+// rewound for retries, from its current offset. The transport may
+// still be reading the body of a failed attempt when the next one
+// starts, so bodies implementing io.ReaderAt get a fresh reader for
+// every attempt, and others are only rewound once the body of the
+// previous attempt has been closed.
+func setSeekableBody(req *http.Request, body io.ReadSeeker) error {
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	if ra, ok := body.(io.ReaderAt); ok {
+		end, err := body.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		if _, err := body.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(io.NewSectionReader(ra, start, end-start))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(io.NewSectionReader(ra, start, end-start)), nil
+		}
+		return nil
+	}
+
+	current := newCloseNotifyBody(body)
+	req.Body = current
+	req.GetBody = func() (io.ReadCloser, error) {
+		<-current.closed
+		if _, err := body.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		current = newCloseNotifyBody(body)
+		return current, nil
+	}
+	return nil
+}
+
+// closeNotifyBody is a request body whose closed channel is closed
+// once the transport is done with it.
+type closeNotifyBody struct {
+	io.Reader
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newCloseNotifyBody(r io.Reader) *closeNotifyBody {
+	return &closeNotifyBody{Reader: r, closed: make(chan struct{})}
+}
+
+func (b *closeNotifyBody) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
+
+// do signs and sends req, retrying failed attempts according to the
+// client's retry policy. Requests whose body cannot be rewound
+// (req.GetBody is nil) are attempted only once.
+func (s3 *S3) do(req *http.Request) (*http.Response, error) {
+	return s3.send(req, true)
+}
+
+// send is like do, but only signs the request if sign is true.
+func (s3 *S3) send(req *http.Request, sign bool) (*http.Response, error) {
+	var (
+		ctx    = req.Context()
+		policy = s3.getRetryPolicy(ctx)
+
+		rewindable = req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	)
+
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		// Each attempt is made using a fresh copy of the original
+		// request, so that signing headers are computed from scratch.
+		r := req.Clone(ctx)
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+
+		if sign {
+			creds, err := s3.credentials(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if err := s3.signRequest(r, creds); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err := s3.getClient().Do(r)
+
+		last := attempt >= policy.MaxAttempts || !rewindable
+		if err != nil {
+			if last || !retryable(err) {
+				return nil, err
+			}
+			if err := sleepWithContext(ctx, policy.backoff(attempt)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if res.StatusCode < http.StatusBadRequest {
+			return res, nil
+		}
+
+		// Buffer the error document so that it can be both classified
+		// here and read by the caller. Error bodies are small.
+		body, readErr := io.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = io.NopCloser(bytes.NewReader(body))
+
+		failure := readErr
+		if failure == nil {
+			failure = newResponseError("", "", "", res, body)
+		}
+		if last || !retryable(failure) {
+			if readErr != nil {
+				return nil, readErr
+			}
+			return res, nil
+		}
+
+		delay := policy.backoff(attempt)
+		if d := retryAfter(res); d > delay {
+			// Give up rather than wait longer than MaxDelay.
+			if policy.MaxDelay > 0 && d > policy.MaxDelay {
+				if readErr != nil {
+					return nil, readErr
+				}
+				return res, nil
+			}
+			delay = d
+		}
+		if err := sleepWithContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleepWithContext waits for d to elapse or for ctx to be done,
+// whichever happens first. It returns ctx.Err() if ctx is done.
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
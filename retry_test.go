@@ -0,0 +1,264 @@
+package simples3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+		NoJitter:    true,
+	}
+}
+
+func TestRetryPolicy_FilePut(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "hello world" {
+			t.Errorf("attempt %d: got body %q, body was not rewound", atomic.LoadInt32(&calls)+1, body)
+		}
+
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, `<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>`)
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+	s3.SetRetryPolicy(testRetryPolicy())
+
+	resp, err := s3.FilePut(UploadInput{
+		Bucket:    "bucket",
+		ObjectKey: "key",
+		Body:      strings.NewReader("hello world"),
+	})
+	if err != nil {
+		t.Fatalf("FilePut() error = %v", err)
+	}
+	if resp.ETag != `"etag"` {
+		t.Errorf("FilePut() ETag = %q", resp.ETag)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestRetryPolicy_GiveUp(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if strings.Contains(r.URL.Path, "missing") {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `<Error><Code>RequestTimeout</Code><Message>Your socket connection to the server was not read from or written to within the timeout period.</Message></Error>`)
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+	s3.SetRetryPolicy(testRetryPolicy())
+
+	t.Run("retryable code", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		_, err := s3.CopyObject(CopyObjectInput{
+			SourceBucket: "bucket", SourceKey: "src",
+			DestBucket: "bucket", DestKey: "dst",
+		})
+
+		var s3Err *S3Error
+		if !errors.As(err, &s3Err) || s3Err.Code != "RequestTimeout" {
+			t.Fatalf("expected RequestTimeout error, got %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 attempts, got %d", calls)
+		}
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		_, err := s3.FileDownload(DownloadInput{Bucket: "bucket", ObjectKey: "missing"})
+		if !IsNotFound(err) {
+			t.Fatalf("expected not found error, got %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 attempt, got %d", calls)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+		s3.SetEndpoint(ts.URL)
+		s3.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+
+		_, err := s3.List(ListInput{Bucket: "bucket"})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 attempt, got %d", calls)
+		}
+	})
+
+	t.Run("custom classifier", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+		s3.SetEndpoint(ts.URL)
+		p := testRetryPolicy()
+		p.Retryable = func(err error) bool { return IsNotFound(err) }
+		s3.SetRetryPolicy(p)
+
+		s3.FileDelete(DeleteInput{Bucket: "bucket", ObjectKey: "missing"})
+		if calls != 3 {
+			t.Errorf("expected 3 attempts, got %d", calls)
+		}
+	})
+}
+
+func TestRetryPolicy_RetryAfter(t *testing.T) {
+	var (
+		calls int32
+		first time.Time
+		delay time.Duration
+	)
+	retryAfter := "1"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		delay = time.Since(first)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+	p := testRetryPolicy()
+	p.MaxDelay = 2 * time.Second
+	s3.SetRetryPolicy(p)
+
+	if err := s3.DeleteBucketLifecycle(DeleteBucketInput{Bucket: "bucket"}); err != nil {
+		t.Fatalf("DeleteBucketLifecycle() error = %v", err)
+	}
+	if delay < time.Second {
+		t.Errorf("expected Retry-After to be honored, retried after %s", delay)
+	}
+
+	// A delay beyond MaxDelay isn't waited for.
+	atomic.StoreInt32(&calls, 0)
+	retryAfter = "3600"
+	start := time.Now()
+	err := s3.DeleteBucketLifecycle(DeleteBucketInput{Bucket: "bucket"})
+	if e, ok := asS3Error(err); !ok || e.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the 503 to be returned, got %v", err)
+	}
+	if calls != 1 || time.Since(start) > time.Second {
+		t.Errorf("expected no retry, got %d attempts in %s", calls, time.Since(start))
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, NoJitter: true}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+
+	p.NoJitter = false
+	for i := 1; i < 10; i++ {
+		if got := p.backoff(3); got < 200*time.Millisecond || got > 400*time.Millisecond {
+			t.Errorf("backoff(3) with jitter = %s, want within [200ms, 400ms]", got)
+		}
+	}
+}
+
+func TestRetryPolicy_ContextOverride(t *testing.T) {
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetRetryPolicy(RetryPolicy{MaxAttempts: 2})
+
+	if p := s3.getRetryPolicy(context.Background()); p.MaxAttempts != 2 {
+		t.Errorf("expected client policy, got %+v", p)
+	}
+
+	ctx := withRetryPolicy(context.Background(), RetryPolicy{MaxAttempts: 7})
+	if p := s3.getRetryPolicy(ctx); p.MaxAttempts != 7 {
+		t.Errorf("expected context policy, got %+v", p)
+	}
+}
+
+// readSeeker hides the io.ReaderAt of the reader it wraps.
+type readSeeker struct{ io.ReadSeeker }
+
+func TestSetSeekableBody(t *testing.T) {
+	t.Run("ReaderAt", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, "http://example.com", nil)
+		body := strings.NewReader("skip:hello world")
+		body.Seek(5, io.SeekStart)
+		if err := setSeekableBody(req, body); err != nil {
+			t.Fatal(err)
+		}
+
+		// The body of an attempt isn't disturbed by the next one.
+		first := make([]byte, 5)
+		io.ReadFull(req.Body, first)
+		again, _ := req.GetBody()
+		second, _ := io.ReadAll(again)
+		rest, _ := io.ReadAll(req.Body)
+		if string(second) != "hello world" || string(first)+string(rest) != "hello world" {
+			t.Errorf("got bodies %q and %q", string(first)+string(rest), second)
+		}
+	})
+
+	t.Run("Seeker", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, "http://example.com", nil)
+		if err := setSeekableBody(req, readSeeker{strings.NewReader("hello world")}); err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(req.Body)
+
+		done := make(chan io.ReadCloser)
+		go func() {
+			body, _ := req.GetBody()
+			done <- body
+		}()
+		select {
+		case <-done:
+			t.Fatal("body rewound before the previous attempt closed it")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		req.Body.Close()
+		select {
+		case body := <-done:
+			if got, _ := io.ReadAll(body); string(got) != "hello world" {
+				t.Errorf("got body %q", got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("body not rewound once closed")
+		}
+	})
+}
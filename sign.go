@@ -8,9 +8,10 @@ package simples3
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path/filepath"
@@ -19,10 +20,10 @@ import (
 	"time"
 )
 
-func (s3 *S3) signKeys(t time.Time) []byte {
-	h := makeHMac([]byte("AWS4"+s3.SecretKey), []byte(t.Format(shortTimeFormat)))
+func (s3 *S3) signKeys(t time.Time, secretKey, service string) []byte {
+	h := makeHMac([]byte("AWS4"+secretKey), []byte(t.Format(shortTimeFormat)))
 	h = makeHMac(h, []byte(s3.Region))
-	h = makeHMac(h, []byte(serviceName))
+	h = makeHMac(h, []byte(service))
 	h = makeHMac(h, []byte("aws4_request"))
 	return h
 }
@@ -44,13 +45,13 @@ func (s3 *S3) writeRequest(w io.Writer, r *http.Request) {
 	writeBody(w, r)
 }
 
-func (s3 *S3) writeStringToSign(w io.Writer, t time.Time, r *http.Request) {
+func (s3 *S3) writeStringToSign(w io.Writer, t time.Time, r *http.Request, service string) {
 	w.Write([]byte(algorithm))
 	w.Write(newLine)
 	w.Write([]byte(t.Format(amzDateISO8601TimeFormat)))
 	w.Write(newLine)
 
-	w.Write([]byte(s3.creds(t)))
+	w.Write([]byte(s3.creds(t, service)))
 	w.Write(newLine)
 
 	h := sha256.New()
@@ -58,8 +59,8 @@ func (s3 *S3) writeStringToSign(w io.Writer, t time.Time, r *http.Request) {
 	fmt.Fprintf(w, "%x", h.Sum(nil))
 }
 
-func (s3 *S3) creds(t time.Time) string {
-	return t.Format(shortTimeFormat) + "/" + s3.Region + "/" + serviceName + "/aws4_request"
+func (s3 *S3) creds(t time.Time, service string) string {
+	return t.Format(shortTimeFormat) + "/" + s3.Region + "/" + service + "/aws4_request"
 }
 
 func writeURI(w io.Writer, r *http.Request) {
@@ -98,18 +99,23 @@ func writeQuery(w io.Writer, r *http.Request) {
 }
 
 func writeHeader(w io.Writer, r *http.Request) {
+	// Sort by name only: sorting "name:value" lines would put
+	// x-amz-copy-source-range before x-amz-copy-source.
 	i, a := 0, make([]string, len(r.Header))
-	for k, v := range r.Header {
-		sort.Strings(v)
-		a[i] = strings.ToLower(k) + ":" + strings.Join(v, ",")
+	for k := range r.Header {
+		a[i] = k
 		i++
 	}
-	sort.Strings(a)
-	for i, s := range a {
+	sort.Slice(a, func(i, j int) bool {
+		return strings.ToLower(a[i]) < strings.ToLower(a[j])
+	})
+	for i, k := range a {
 		if i > 0 {
 			w.Write(newLine)
 		}
-		io.WriteString(w, s)
+		v := r.Header[k]
+		sort.Strings(v)
+		io.WriteString(w, strings.ToLower(k)+":"+strings.Join(v, ","))
 	}
 }
 
@@ -128,24 +134,55 @@ func writeHeaderList(w io.Writer, r *http.Request) {
 	}
 }
 
+// writeBody writes the hash of the payload. signRequest makes sure it is
+// set in the x-amz-content-sha256 header, so the body is not read here.
 func writeBody(w io.Writer, r *http.Request) {
-	var (
-		b   []byte
-		err error
-	)
+	io.WriteString(w, r.Header.Get("x-amz-content-sha256"))
+}
+
+// hashBody returns the hex encoded SHA256 of the body of r. The body
+// is read in memory and replaced, so it must only be used for small
+// payloads such as XML documents.
+func hashBody(r *http.Request) (string, error) {
 	// If the payload is empty, use the empty string as the input to the SHA256 function
 	// http://docs.amazonwebservices.com/general/latest/gr/sigv4-create-canonical-request.html
-	if r.Body == nil {
-		b = []byte("")
-	} else {
-		b, err = ioutil.ReadAll(r.Body)
-		if err != nil {
-			panic(err)
-		}
-		r.Body = ioutil.NopCloser(bytes.NewBuffer(b))
+	if r.Body == nil || r.Body == http.NoBody {
+		return emptyPayloadHash, nil
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(b))
+
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// hashReadSeeker returns the hex encoded SHA256 of the remaining content
+// of body, which is streamed through the hash and rewound afterwards.
+// The content is also written to the checksums that are not nil.
+func hashReadSeeker(body io.ReadSeeker, checksums ...hash.Hash) (string, error) {
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
 	}
 
 	h := sha256.New()
-	h.Write(b)
-	fmt.Fprintf(w, "%x", h.Sum(nil))
+	w := io.Writer(h)
+	for _, sum := range checksums {
+		if sum != nil {
+			w = io.MultiWriter(w, sum)
+		}
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		return "", err
+	}
+	if _, err := body.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
@@ -0,0 +1,341 @@
+package simples3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// verifySigV4 checks the signature of r, made using secretKey, the way
+// S3 does, independently of the signing code. It returns the request
+// body, whose hash is checked as well unless the payload is unsigned.
+func verifySigV4(r *http.Request, secretKey string) ([]byte, error) {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), algorithm+" ")
+	fields := map[string]string{}
+	for _, f := range strings.Split(auth, ", ") {
+		k, v, _ := strings.Cut(f, "=")
+		fields[k] = v
+	}
+	scope := strings.SplitN(fields["Credential"], "/", 2)
+	if len(scope) != 2 {
+		return nil, fmt.Errorf("malformed authorization header %q", r.Header.Get("Authorization"))
+	}
+
+	var query []string
+	for k, vs := range r.URL.Query() {
+		for _, v := range vs {
+			query = append(query, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	sort.Strings(query)
+
+	var headers strings.Builder
+	for _, h := range strings.Split(fields["SignedHeaders"], ";") {
+		v := strings.Join(r.Header.Values(h), ",")
+		if h == "host" {
+			v = r.Host
+		}
+		headers.WriteString(h + ":" + v + "\n")
+	}
+
+	payloadHash := r.Header.Get("x-amz-content-sha256")
+	canonical := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		strings.Join(query, "&"),
+		headers.String(),
+		fields["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{
+		algorithm,
+		r.Header.Get("X-Amz-Date"),
+		scope[1],
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range strings.Split(scope[1], "/") {
+		key = makeHMac(key, []byte(part))
+	}
+	if sig := hex.EncodeToString(makeHMac(key, []byte(stringToSign))); sig != fields["Signature"] {
+		return nil, fmt.Errorf("signature mismatch: got %s, want %s", fields["Signature"], sig)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(payloadHash, streamingPayload) {
+		return decodeChunked(r, body, key, scope[1], fields["Signature"])
+	}
+	if h := sha256.Sum256(body); hex.EncodeToString(h[:]) != payloadHash {
+		return nil, fmt.Errorf("payload hash mismatch")
+	}
+	return body, nil
+}
+
+// decodeChunked decodes an aws-chunked body, checking the signature of
+// every chunk and of the trailer, if any, and returns the payload.
+func decodeChunked(r *http.Request, body, key []byte, scope, seed string) ([]byte, error) {
+	var (
+		payload []byte
+		prevSig = seed
+		date    = r.Header.Get("X-Amz-Date")
+		br      = bufio.NewReader(bytes.NewReader(body))
+	)
+	sign := func(parts ...string) string {
+		parts = append(parts[:1], append([]string{date, scope, prevSig}, parts[1:]...)...)
+		prevSig = hex.EncodeToString(makeHMac(key, []byte(strings.Join(parts, "\n"))))
+		return prevSig
+	}
+	readLine := func() (string, error) {
+		line, err := br.ReadString('\n')
+		if err != nil || !strings.HasSuffix(line, "\r\n") {
+			return "", fmt.Errorf("malformed chunk line %q: %v", line, err)
+		}
+		return strings.TrimSuffix(line, "\r\n"), nil
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Content-Encoding"), "aws-chunked") {
+		return nil, fmt.Errorf("unexpected content encoding %q", r.Header.Get("Content-Encoding"))
+	}
+
+	for {
+		line, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, sig, _ := strings.Cut(line, ";chunk-signature=")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return nil, err
+		}
+		chunkHash := sha256.Sum256(chunk)
+		if want := sign("AWS4-HMAC-SHA256-PAYLOAD", emptyPayloadHash, hex.EncodeToString(chunkHash[:])); sig != want {
+			return nil, fmt.Errorf("chunk signature mismatch after %d bytes", len(payload))
+		}
+		if size == 0 {
+			break
+		}
+		payload = append(payload, chunk...)
+
+		if line, err := readLine(); err != nil || line != "" {
+			return nil, fmt.Errorf("missing chunk terminator: %v", err)
+		}
+	}
+
+	if trailer := r.Header.Get("x-amz-trailer"); trailer != "" {
+		line, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		name, value, _ := strings.Cut(line, ":")
+		h := trailerChecksums[name]
+		if name != trailer || h == nil {
+			return nil, fmt.Errorf("unexpected trailer %q", line)
+		}
+		sum := h()
+		sum.Write(payload)
+		if value != base64.StdEncoding.EncodeToString(sum.Sum(nil)) {
+			return nil, fmt.Errorf("trailer checksum mismatch")
+		}
+
+		sigLine, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		trailerHash := sha256.Sum256([]byte(line + "\n"))
+		if want := sign("AWS4-HMAC-SHA256-TRAILER", hex.EncodeToString(trailerHash[:])); sigLine != "x-amz-trailer-signature:"+want {
+			return nil, fmt.Errorf("trailer signature mismatch")
+		}
+	}
+
+	if line, err := readLine(); err != nil || line != "" || br.Buffered() > 0 {
+		return nil, fmt.Errorf("malformed end of payload")
+	}
+	if n := r.Header.Get("x-amz-decoded-content-length"); n != strconv.Itoa(len(payload)) {
+		return nil, fmt.Errorf("decoded content length %s, got %d bytes", n, len(payload))
+	}
+	return payload, nil
+}
+
+// readerAt is an io.Reader and io.ReaderAt that can't seek.
+type readerAt struct {
+	io.Reader
+	io.ReaderAt
+}
+
+// sizedReaderAt is a readerAt that reports its size.
+type sizedReaderAt struct {
+	readerAt
+	size int64
+}
+
+func (r sizedReaderAt) Size() int64 { return r.size }
+
+func TestFilePut_Streaming(t *testing.T) {
+	var (
+		got     []byte
+		payload string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := verifySigV4(r, "SuperSecretKey")
+		if err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.ContentLength < 0 || len(r.TransferEncoding) > 0 {
+			t.Errorf("expected a fixed length body, got %v", r.TransferEncoding)
+		}
+		got, payload = body, r.Header.Get("x-amz-content-sha256")
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	content := bytes.Repeat([]byte("0123456789"), 100000)
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		name      string
+		input     UploadInput
+		streaming bool
+	}{
+		{"Body", UploadInput{Body: f}, false},
+		{"ReaderAt", UploadInput{Reader: readerAt{nil, bytes.NewReader(content)}, ContentLength: int64(len(content))}, false},
+		{"SizedReaderAt", UploadInput{Reader: sizedReaderAt{readerAt{nil, bytes.NewReader(content)}, int64(len(content))}}, false},
+		{"Reader", UploadInput{Reader: iotest.HalfReader(bytes.NewReader(content)), ContentLength: int64(len(content))}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			tt.input.Bucket, tt.input.ObjectKey = "bucket", "key"
+			if _, err := s3.FilePut(tt.input); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("uploaded %d bytes, want %d", len(got), len(content))
+			}
+			if (payload == streamingPayload) != tt.streaming {
+				t.Errorf("unexpected payload hash %q", payload)
+			}
+		})
+	}
+
+	if _, err := s3.FilePut(UploadInput{Bucket: "bucket", ObjectKey: "key"}); err == nil {
+		t.Errorf("expected an error without a body")
+	}
+
+	// A length of 0 is only taken as is for empty readers.
+	for _, r := range []io.Reader{readerAt{nil, bytes.NewReader(content)}, bytes.NewBuffer(content)} {
+		got = nil
+		if _, err := s3.FilePut(UploadInput{Bucket: "bucket", ObjectKey: "key", Reader: r}); err == nil {
+			t.Errorf("%T: expected an error without a content length", r)
+		}
+		if got != nil {
+			t.Errorf("%T: uploaded %d bytes without a content length", r, len(got))
+		}
+	}
+	for _, r := range []io.Reader{readerAt{nil, bytes.NewReader(nil)}, new(bytes.Buffer)} {
+		if _, err := s3.FilePut(UploadInput{Bucket: "bucket", ObjectKey: "key", Reader: r}); err != nil {
+			t.Errorf("%T: %v", r, err)
+		}
+	}
+}
+
+func TestStreamingPayload(t *testing.T) {
+	var got []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := verifySigV4(r, "SuperSecretKey")
+		if err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		got = body
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+	s3.SetToken("SessionToken")
+
+	for _, size := range []int{0, 100, streamingChunkSize, 2*streamingChunkSize + 1234} {
+		content := bytes.Repeat([]byte{'x'}, size)
+
+		for _, trailer := range []string{"", "x-amz-checksum-crc32", "x-amz-checksum-crc32c", "x-amz-checksum-crc64nvme", "x-amz-checksum-sha256"} {
+			t.Run(fmt.Sprintf("%d%s", size, trailer), func(t *testing.T) {
+				got = nil
+				req, _ := http.NewRequest(http.MethodPut, s3.getURL("bucket", "key"), nil)
+				req.Body = io.NopCloser(iotest.HalfReader(bytes.NewReader(content)))
+				if err := setStreamingPayload(req, int64(size), trailer); err != nil {
+					t.Fatal(err)
+				}
+
+				res, err := s3.do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res.Body.Close()
+				if res.StatusCode != http.StatusOK || !bytes.Equal(got, content) {
+					t.Errorf("upload failed: %s, got %d bytes", res.Status, len(got))
+				}
+			})
+		}
+	}
+
+	t.Run("UploadPart", func(t *testing.T) {
+		content := bytes.Repeat([]byte{'y'}, 3*streamingChunkSize)
+		out, err := s3.UploadPart(UploadPartInput{
+			Bucket:     "bucket",
+			ObjectKey:  "key",
+			UploadID:   "upload",
+			PartNumber: 1,
+			Body:       iotest.OneByteReader(bytes.NewReader(content)),
+			Size:       int64(len(content)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.ETag != `"etag"` || !bytes.Equal(got, content) {
+			t.Errorf("upload failed: got %d bytes", len(got))
+		}
+	})
+
+	if err := setStreamingPayload(&http.Request{Header: http.Header{}}, 1, "x-amz-checksum-md5"); err == nil {
+		t.Errorf("expected unsupported trailer to be rejected")
+	}
+}
@@ -17,6 +17,9 @@ import (
 const (
 	// AMZMetaPrefix to prefix metadata key.
 	AMZMetaPrefix = "x-amz-meta-"
+
+	// emptyPayloadHash is the SHA256 of an empty payload.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
 )
 
 // S3 provides a wrapper around your S3 credentials.
@@ -29,9 +32,14 @@ type S3 struct {
 	Token     string
 	Endpoint  string
 	URIFormat string
-	initMode  string
 	expiry    time.Time
 
+	provider      CredentialsProvider
+	refresh       *credentialsRefresh
+	refreshWindow *time.Duration
+
+	retryPolicy *RetryPolicy
+
 	mu sync.Mutex
 }
 
@@ -74,6 +82,9 @@ func (s3 *S3) SetEndpoint(uri string) *S3 {
 // SetToken can be used to set a Temporary Security Credential token obtained from
 // using an IAM role or AWS STS.
 func (s3 *S3) SetToken(token string) *S3 {
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+
 	if token != "" {
 		s3.Token = token
 	}
@@ -92,7 +103,13 @@ func (s3 *S3) SetClient(client *http.Client) *S3 {
 	return s3
 }
 
-func (s3 *S3) signRequest(req *http.Request) error {
+func (s3 *S3) signRequest(req *http.Request, creds Credentials) error {
+	return s3.signRequestForService(req, creds, serviceName)
+}
+
+// signRequestForService signs req for the given AWS service, such as
+// "sts", in the client's region.
+func (s3 *S3) signRequestForService(req *http.Request, creds Credentials, service string) error {
 	var (
 		err error
 
@@ -109,33 +126,44 @@ func (s3 *S3) signRequest(req *http.Request) error {
 	req.Header.Set("Date", t.Format(amzDateISO8601TimeFormat))
 	req.Header.Set("X-Amz-Date", t.Format(amzDateISO8601TimeFormat))
 
-	if s3.Token != "" {
-		req.Header.Set("X-Amz-Security-Token", s3.Token)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
 	}
 
 	// The x-amz-content-sha256 header is required for all AWS
 	// Signature Version 4 requests. It provides a hash of the
 	// request payload. If there is no payload, you must provide
-	// the hash of an empty string.
+	// the hash of an empty string. Large payloads are hashed (or
+	// left unsigned) by the caller, which sets the header itself.
 
 	if req.Header.Get("x-amz-content-sha256") == "" {
-		emptyhash := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
-		req.Header.Set("x-amz-content-sha256", emptyhash)
+		hash, err := hashBody(req)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("x-amz-content-sha256", hash)
 	}
 
-	k := s3.signKeys(t)
+	k := s3.signKeys(t, creds.SecretAccessKey, service)
 	h := hmac.New(sha256.New, k)
 
-	s3.writeStringToSign(h, t, req)
+	s3.writeStringToSign(h, t, req, service)
 
 	auth := bytes.NewBufferString(algorithm)
-	auth.Write([]byte(" Credential=" + s3.AccessKey + "/" + s3.creds(t)))
+	auth.Write([]byte(" Credential=" + creds.AccessKeyID + "/" + s3.creds(t, service)))
 	auth.Write([]byte{',', ' '})
 	auth.Write([]byte("SignedHeaders="))
 	writeHeaderList(auth, req)
 	auth.Write([]byte{',', ' '})
-	auth.Write([]byte("Signature=" + fmt.Sprintf("%x", h.Sum(nil))))
+	signature := fmt.Sprintf("%x", h.Sum(nil))
+	auth.Write([]byte("Signature=" + signature))
 
 	req.Header.Set("Authorization", auth.String())
+
+	// Streaming payloads are signed chunk by chunk as they are sent,
+	// starting from the signature of the request.
+	if isStreamingPayload(req) {
+		req.Body = newChunkedReader(req.Body, k, t, s3.creds(t, service), signature, req.Header.Get("x-amz-trailer"))
+	}
 	return nil
 }
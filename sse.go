@@ -0,0 +1,71 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// SSECustomerAlgorithm is the algorithm used to encrypt objects with
+// customer-provided keys (SSE-C), the only one S3 supports.
+const SSECustomerAlgorithm = "AES256"
+
+// SSECustomerKeySize is the size of SSE-C keys, in bytes.
+const SSECustomerKeySize = 32
+
+// SSECustomerKeyHeaders returns the headers of a request for an object
+// encrypted using key (SSE-C). They must be sent along with requests
+// made using presigned URLs generated with PresignedInput.SSECustomerKey.
+func SSECustomerKeyHeaders(key []byte) map[string]string {
+	return sseCustomerKeyHeaders("x-amz-", key)
+}
+
+// sseCustomerKeyHeaders returns the SSE-C headers for key, starting
+// with prefix: "x-amz-" for the object of a request, or
+// "x-amz-copy-source-" for the source of a copy.
+func sseCustomerKeyHeaders(prefix string, key []byte) map[string]string {
+	sum := md5.Sum(key)
+	return map[string]string{
+		prefix + "server-side-encryption-customer-algorithm": SSECustomerAlgorithm,
+		prefix + "server-side-encryption-customer-key":       base64.StdEncoding.EncodeToString(key),
+		prefix + "server-side-encryption-customer-key-md5":   base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
+
+// checkSSECustomerKey returns an error if key is set but isn't a valid
+// SSE-C key.
+func checkSSECustomerKey(key []byte) error {
+	if key != nil && len(key) != SSECustomerKeySize {
+		return fmt.Errorf("SSE-C key must be %d bytes, got %d", SSECustomerKeySize, len(key))
+	}
+	return nil
+}
+
+// setSSECustomerKey sets the SSE-C headers of a request for an object
+// encrypted using key, if any.
+func setSSECustomerKey(h http.Header, key []byte) error {
+	return setSSECustomerKeyHeaders(h, "x-amz-", key)
+}
+
+// setCopySourceSSECustomerKey sets the SSE-C headers of a copy whose
+// source is encrypted using key, if any.
+func setCopySourceSSECustomerKey(h http.Header, key []byte) error {
+	return setSSECustomerKeyHeaders(h, "x-amz-copy-source-", key)
+}
+
+func setSSECustomerKeyHeaders(h http.Header, prefix string, key []byte) error {
+	if key == nil {
+		return nil
+	}
+	if err := checkSSECustomerKey(key); err != nil {
+		return err
+	}
+	for k, v := range sseCustomerKeyHeaders(prefix, key) {
+		h.Set(k, v)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package simples3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSSECustomerKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, SSECustomerKeySize)
+	sum := md5.Sum(key)
+	keyMD5 := base64.StdEncoding.EncodeToString(sum[:])
+
+	sourceKey := bytes.Repeat([]byte{0x24}, SSECustomerKeySize)
+	sourceSum := md5.Sum(sourceKey)
+
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifySigV4(r, "SuperSecretKey"); err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		got = r.Header.Clone()
+
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("x-amz-server-side-encryption-customer-algorithm", r.Header.Get("x-amz-server-side-encryption-customer-algorithm"))
+		w.Header().Set("x-amz-server-side-encryption-customer-key-MD5", r.Header.Get("x-amz-server-side-encryption-customer-key-md5"))
+		switch {
+		case r.Header.Get("x-amz-copy-source") != "" && r.URL.Query().Has("partNumber"):
+			io.WriteString(w, `<CopyPartResult><ETag>"etag"</ETag></CopyPartResult>`)
+		case r.Header.Get("x-amz-copy-source") != "":
+			io.WriteString(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+		case r.Method == http.MethodPost:
+			io.WriteString(w, `<InitiateMultipartUploadResult><UploadId>upload</UploadId></InitiateMultipartUploadResult>`)
+		case r.Method == http.MethodGet:
+			io.WriteString(w, "content")
+		}
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	checkHeaders := func(t *testing.T, prefix string, wantMD5 string) {
+		t.Helper()
+		if v := got.Get(prefix + "server-side-encryption-customer-algorithm"); v != SSECustomerAlgorithm {
+			t.Errorf("%salgorithm = %q", prefix, v)
+		}
+		if v := got.Get(prefix + "server-side-encryption-customer-key-md5"); v != wantMD5 {
+			t.Errorf("%skey MD5 = %q, want %q", prefix, v, wantMD5)
+		}
+		if got.Get(prefix+"server-side-encryption-customer-key") == "" {
+			t.Errorf("%skey not sent", prefix)
+		}
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"FilePut", func() error {
+			_, err := s3.FilePut(UploadInput{Bucket: "bucket", ObjectKey: "key", Body: strings.NewReader("content"), SSECustomerKey: key})
+			return err
+		}},
+		{"GetObject", func() error {
+			res, err := s3.GetObject(DownloadInput{Bucket: "bucket", ObjectKey: "key", SSECustomerKey: key})
+			if err == nil {
+				res.Body.Close()
+			}
+			return err
+		}},
+		{"FileDetails", func() error {
+			details, err := s3.FileDetails(DetailsInput{Bucket: "bucket", ObjectKey: "key", SSECustomerKey: key})
+			if err == nil && (details.SSECustomerAlgorithm != SSECustomerAlgorithm || details.SSECustomerKeyMD5 != keyMD5) {
+				t.Errorf("unexpected details: %+v", details)
+			}
+			return err
+		}},
+		{"InitiateMultipartUpload", func() error {
+			_, err := s3.InitiateMultipartUpload(InitiateMultipartUploadInput{Bucket: "bucket", ObjectKey: "key", SSECustomerKey: key})
+			return err
+		}},
+		{"UploadPart", func() error {
+			_, err := s3.UploadPart(UploadPartInput{
+				Bucket: "bucket", ObjectKey: "key", UploadID: "upload", PartNumber: 1,
+				Body: strings.NewReader("content"), Size: 7, SSECustomerKey: key,
+			})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			if err := tt.call(); err != nil {
+				t.Fatal(err)
+			}
+			checkHeaders(t, "x-amz-", keyMD5)
+		})
+	}
+
+	t.Run("CopyObject", func(t *testing.T) {
+		_, err := s3.CopyObject(CopyObjectInput{
+			SourceBucket: "bucket", SourceKey: "source",
+			DestBucket: "bucket", DestKey: "dest",
+			SSECustomerKey:           key,
+			CopySourceSSECustomerKey: sourceKey,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkHeaders(t, "x-amz-", keyMD5)
+		checkHeaders(t, "x-amz-copy-source-", base64.StdEncoding.EncodeToString(sourceSum[:]))
+	})
+
+	t.Run("UploadPartCopy", func(t *testing.T) {
+		_, err := s3.UploadPartCopy(UploadPartCopyInput{
+			Bucket: "bucket", ObjectKey: "dest", UploadID: "upload", PartNumber: 1,
+			SourceBucket: "bucket", SourceKey: "source",
+			SSECustomerKey:           key,
+			CopySourceSSECustomerKey: sourceKey,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkHeaders(t, "x-amz-", keyMD5)
+		checkHeaders(t, "x-amz-copy-source-", base64.StdEncoding.EncodeToString(sourceSum[:]))
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		got = nil
+		_, err := s3.FilePut(UploadInput{Bucket: "bucket", ObjectKey: "key", Body: strings.NewReader("content"), SSECustomerKey: key[:16]})
+		if err == nil || got != nil {
+			t.Errorf("expected a short key to be rejected before sending the request")
+		}
+	})
+}
+
+func TestS3_GeneratePresignedURL_SSECustomerKey(t *testing.T) {
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	key := bytes.Repeat([]byte{0x42}, SSECustomerKeySize)
+
+	u, err := url.Parse(s3.GeneratePresignedURL(PresignedInput{
+		Bucket:         "bucket",
+		ObjectKey:      "key",
+		Method:         "GET",
+		ExpirySeconds:  3600,
+		SSECustomerKey: key,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The signed headers are separated by raw semicolons, which
+	// url.Values doesn't parse.
+	var signed []string
+	for _, param := range strings.Split(u.RawQuery, "&") {
+		if v, ok := strings.CutPrefix(param, HdrXAmzSignedHeaders+"="); ok {
+			signed = strings.Split(v, ";")
+		}
+	}
+	for h := range SSECustomerKeyHeaders(key) {
+		found := false
+		for _, s := range signed {
+			found = found || s == h
+		}
+		if !found {
+			t.Errorf("%s is not signed: %v", h, signed)
+		}
+	}
+	if strings.Contains(u.RawQuery, "customer-key=") {
+		t.Errorf("the key must not be part of the URL: %s", u)
+	}
+
+	if got := s3.GeneratePresignedURL(PresignedInput{Bucket: "bucket", ObjectKey: "key", Method: "GET", SSECustomerKey: key[:16]}); got != "" {
+		t.Errorf("expected no URL for an invalid key, got %s", got)
+	}
+}
@@ -0,0 +1,203 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Storage classes that can be set as StorageClass when writing objects.
+// Objects in the GLACIER and DEEP_ARCHIVE classes must be restored
+// with RestoreObject before they can be downloaded.
+const (
+	StorageClassStandard           = "STANDARD"
+	StorageClassReducedRedundancy  = "REDUCED_REDUNDANCY"
+	StorageClassStandardIA         = "STANDARD_IA"
+	StorageClassOneZoneIA          = "ONEZONE_IA"
+	StorageClassIntelligentTiering = "INTELLIGENT_TIERING"
+	StorageClassGlacierIR          = "GLACIER_IR"
+	StorageClassGlacier            = "GLACIER"
+	StorageClassDeepArchive        = "DEEP_ARCHIVE"
+)
+
+// Retrieval tiers of RestoreObject, from the fastest and most expensive
+// to the slowest and cheapest.
+const (
+	RestoreTierExpedited = "Expedited"
+	RestoreTierStandard  = "Standard"
+	RestoreTierBulk      = "Bulk"
+)
+
+// RestoreStatus is the status of the restore of an archived object,
+// reported by FileDetails.
+type RestoreStatus struct {
+	// OngoingRequest is true while the object is being restored
+	OngoingRequest bool
+	// ExpiryDate is when the restored copy expires, once it is restored
+	ExpiryDate time.Time
+}
+
+// parseRestoreStatus parses the x-amz-restore header, e.g.
+// `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`.
+func parseRestoreStatus(v string) *RestoreStatus {
+	status := &RestoreStatus{
+		OngoingRequest: strings.Contains(v, `ongoing-request="true"`),
+	}
+	if _, rest, ok := strings.Cut(v, `expiry-date="`); ok {
+		date, _, _ := strings.Cut(rest, `"`)
+		if t, err := http.ParseTime(date); err == nil {
+			status.ExpiryDate = t
+		}
+	}
+	return status
+}
+
+// RestoreObjectInput is passed to RestoreObject as a parameter.
+type RestoreObjectInput struct {
+	// Required: The name of the bucket
+	Bucket string
+
+	// Required: The object key
+	ObjectKey string
+
+	// Optional: Version ID of the object, defaults to the latest one
+	VersionId string
+
+	// Required: Number of days the restored copy is kept
+	Days int
+
+	// Optional: Retrieval tier (Expedited, Standard or Bulk), defaults
+	// to Standard
+	Tier string
+}
+
+// RestoreObjectOutput is returned by RestoreObject.
+type RestoreObjectOutput struct {
+	// AlreadyRestored is true if the object was already restored, in
+	// which case the expiry of its restored copy is updated.
+	AlreadyRestored bool
+}
+
+// restoreRequest is the internal type for XML marshaling.
+type restoreRequest struct {
+	XMLName              xml.Name              `xml:"RestoreRequest"`
+	XMLNS                string                `xml:"xmlns,attr"`
+	Days                 int                   `xml:"Days"`
+	GlacierJobParameters *glacierJobParameters `xml:"GlacierJobParameters,omitempty"`
+}
+
+type glacierJobParameters struct {
+	Tier string `xml:"Tier"`
+}
+
+// RestoreObject restores a temporary copy of an object archived in the
+// GLACIER or DEEP_ARCHIVE storage classes, for Days days. The restore
+// runs in the background: FileDetails reports its progress in Restore,
+// and the object can be downloaded once OngoingRequest is false. If a
+// restore of the object is already in progress, the error returned is
+// one for which IsRestoreInProgress is true.
+func (s3 *S3) RestoreObject(input RestoreObjectInput) (RestoreObjectOutput, error) {
+	return s3.RestoreObjectWithContext(context.Background(), input)
+}
+
+// RestoreObjectWithContext is like RestoreObject but uses ctx for the request.
+func (s3 *S3) RestoreObjectWithContext(ctx context.Context, input RestoreObjectInput) (RestoreObjectOutput, error) {
+	// Validate input
+	if input.Bucket == "" {
+		return RestoreObjectOutput{}, fmt.Errorf("bucket name is required")
+	}
+	if input.ObjectKey == "" {
+		return RestoreObjectOutput{}, fmt.Errorf("object key is required")
+	}
+	if input.Days <= 0 {
+		return RestoreObjectOutput{}, fmt.Errorf("days must be greater than 0")
+	}
+
+	// Build XML request body
+	restoreReq := restoreRequest{
+		XMLNS: "http://s3.amazonaws.com/doc/2006-03-01/",
+		Days:  input.Days,
+	}
+	if input.Tier != "" {
+		restoreReq.GlacierJobParameters = &glacierJobParameters{Tier: input.Tier}
+	}
+
+	xmlBody, err := xml.Marshal(restoreReq)
+	if err != nil {
+		return RestoreObjectOutput{}, err
+	}
+
+	// Calculate Content-MD5
+	md5Hash := md5.Sum(xmlBody)
+	contentMD5 := base64.StdEncoding.EncodeToString(md5Hash[:])
+
+	// Build URL with ?restore query parameter
+	baseURL := s3.getURL(input.Bucket, input.ObjectKey)
+	parsedURL, err := url.Parse(baseURL)
+	if err != nil {
+		return RestoreObjectOutput{}, err
+	}
+	query := url.Values{}
+	query.Set("restore", "")
+	if input.VersionId != "" {
+		query.Set("versionId", input.VersionId)
+	}
+	parsedURL.RawQuery = query.Encode()
+
+	// Create POST request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, parsedURL.String(), bytes.NewReader(xmlBody))
+	if err != nil {
+		return RestoreObjectOutput{}, err
+	}
+
+	// Set headers
+	req.ContentLength = int64(len(xmlBody))
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Content-MD5", contentMD5)
+
+	// Calculate SHA256
+	h := sha256.New()
+	h.Write(xmlBody)
+	req.Header.Set("x-amz-content-sha256", fmt.Sprintf("%x", h.Sum(nil)))
+	req.Header.Set("Host", req.URL.Host)
+
+	// Execute request
+	res, err := s3.do(req)
+	if err != nil {
+		return RestoreObjectOutput{}, err
+	}
+
+	defer func() {
+		res.Body.Close()
+		io.Copy(io.Discard, res.Body)
+	}()
+
+	// Read response body
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return RestoreObjectOutput{}, err
+	}
+
+	// S3 returns 202 Accepted when the restore starts, and 200 OK if
+	// the object is already restored.
+	switch res.StatusCode {
+	case http.StatusAccepted:
+		return RestoreObjectOutput{}, nil
+	case http.StatusOK:
+		return RestoreObjectOutput{AlreadyRestored: true}, nil
+	default:
+		return RestoreObjectOutput{}, newResponseError("RestoreObject", input.Bucket, input.ObjectKey, res, body)
+	}
+}
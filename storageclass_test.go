@@ -0,0 +1,175 @@
+package simples3
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorageClass(t *testing.T) {
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifySigV4(r, "SuperSecretKey"); err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		got = r.Header.Clone()
+
+		switch {
+		case r.Header.Get("x-amz-copy-source") != "":
+			io.WriteString(w, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`)
+		case r.Method == http.MethodPost && r.URL.Query().Has("uploads"):
+			io.WriteString(w, `<InitiateMultipartUploadResult><UploadId>upload</UploadId></InitiateMultipartUploadResult>`)
+		case r.Method == http.MethodHead:
+			w.Header().Set("x-amz-storage-class", StorageClassGlacier)
+			w.Header().Set("x-amz-restore", `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`)
+		}
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"FilePut", func() error {
+			_, err := s3.FilePut(UploadInput{Bucket: "bucket", ObjectKey: "key", Body: strings.NewReader("content"), StorageClass: StorageClassStandardIA})
+			return err
+		}},
+		{"InitiateMultipartUpload", func() error {
+			_, err := s3.InitiateMultipartUpload(InitiateMultipartUploadInput{Bucket: "bucket", ObjectKey: "key", StorageClass: StorageClassStandardIA})
+			return err
+		}},
+		{"CopyObject", func() error {
+			_, err := s3.CopyObject(CopyObjectInput{
+				SourceBucket: "bucket", SourceKey: "key",
+				DestBucket: "bucket", DestKey: "key",
+				StorageClass: StorageClassStandardIA,
+			})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); err != nil {
+				t.Fatal(err)
+			}
+			if v := got.Get("x-amz-storage-class"); v != StorageClassStandardIA {
+				t.Errorf("storage class = %q", v)
+			}
+		})
+	}
+
+	t.Run("FileDetails", func(t *testing.T) {
+		details, err := s3.FileDetails(DetailsInput{Bucket: "bucket", ObjectKey: "key"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if details.StorageClass != StorageClassGlacier {
+			t.Errorf("StorageClass = %q", details.StorageClass)
+		}
+		want := time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC)
+		if details.Restore == nil || details.Restore.OngoingRequest || !details.Restore.ExpiryDate.Equal(want) {
+			t.Errorf("unexpected restore status %+v", details.Restore)
+		}
+	})
+}
+
+func TestParseRestoreStatus(t *testing.T) {
+	status := parseRestoreStatus(`ongoing-request="true"`)
+	if !status.OngoingRequest || !status.ExpiryDate.IsZero() {
+		t.Errorf("unexpected restore status %+v", status)
+	}
+}
+
+func TestRestoreObject(t *testing.T) {
+	var (
+		gotReq  *http.Request
+		gotBody []byte
+		status  int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := verifySigV4(r, "SuperSecretKey")
+		if err != nil {
+			t.Error(err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		gotReq, gotBody = r, body
+		w.WriteHeader(status)
+		if status == http.StatusConflict {
+			fmt.Fprint(w, `<Error><Code>RestoreAlreadyInProgress</Code><Message>Object restore is already in progress</Message></Error>`)
+		}
+	}))
+	defer ts.Close()
+
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+	input := RestoreObjectInput{Bucket: "bucket", ObjectKey: "key", VersionId: "v1", Days: 3, Tier: RestoreTierBulk}
+
+	status = http.StatusAccepted
+	out, err := s3.RestoreObject(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.AlreadyRestored {
+		t.Errorf("expected a new restore")
+	}
+	q := gotReq.URL.Query()
+	if gotReq.Method != http.MethodPost || !q.Has("restore") || q.Get("versionId") != "v1" {
+		t.Errorf("unexpected request %s %s", gotReq.Method, gotReq.URL)
+	}
+	var sent restoreRequest
+	if err := xml.Unmarshal(gotBody, &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Days != 3 || sent.GlacierJobParameters == nil || sent.GlacierJobParameters.Tier != RestoreTierBulk {
+		t.Errorf("unexpected restore request %s", gotBody)
+	}
+
+	status = http.StatusOK
+	if out, err := s3.RestoreObject(input); err != nil || !out.AlreadyRestored {
+		t.Errorf("expected the object to be already restored: %+v, %v", out, err)
+	}
+
+	status = http.StatusConflict
+	if _, err := s3.RestoreObject(input); !IsRestoreInProgress(err) {
+		t.Errorf("expected a restore in progress error, got %v", err)
+	}
+
+	if _, err := s3.RestoreObject(RestoreObjectInput{Bucket: "bucket", ObjectKey: "key"}); err == nil {
+		t.Errorf("expected missing days to be rejected")
+	}
+}
+
+func TestStorageClass_CopyObjectMultipart(t *testing.T) {
+	var init http.Header
+	ts := newCopyStub(t, map[string]string{"x-amz-storage-class": StorageClassStandardIA}, &init)
+	s3 := New("us-east-1", "AccessKey", "SuperSecretKey")
+	s3.SetEndpoint(ts.URL)
+
+	for _, tt := range []struct{ storageClass, want string }{
+		{"", ""},
+		{StorageClassGlacierIR, StorageClassGlacierIR},
+	} {
+		_, err := s3.CopyObjectMultipart(CopyObjectMultipartInput{CopyObjectInput: CopyObjectInput{
+			SourceBucket: "src", SourceKey: "big.bin",
+			DestBucket: "dst", DestKey: "copy.bin",
+			StorageClass: tt.storageClass,
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := init.Get("x-amz-storage-class"); got != tt.want {
+			t.Errorf("storage class %q: got %q, want %q", tt.storageClass, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+// LICENSE BSD-2-Clause-FreeBSD
+// Copyright (c) 2018, Rohan Verma <hello@rohanverma.net>
+
+package simples3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	stsServiceName = "sts"
+	stsAPIVersion  = "2011-06-15"
+
+	defaultSTSRegion       = "us-east-1"
+	defaultSTSExpiryWindow = 1 * time.Minute
+)
+
+// AssumeRoleProvider provides temporary credentials for a role,
+// obtained by calling STS AssumeRole using the credentials of Source.
+//
+// The credentials are cached and retrieved again ExpiryWindow before
+// they expire. An AssumeRoleProvider must not be copied after first use.
+type AssumeRoleProvider struct {
+	// Required: Source provides the credentials used to call STS.
+	Source CredentialsProvider
+
+	// Required: RoleARN is the ARN of the role to assume.
+	RoleARN string
+
+	// RoleSessionName identifies the session.
+	// Defaults to "simples3-" followed by the current time.
+	RoleSessionName string
+
+	// ExternalID is passed to STS if the role's trust policy requires one.
+	ExternalID string
+
+	// Duration of the session. Defaults to the role's setting (usually 1 hour).
+	Duration time.Duration
+
+	// Region is used to sign requests and to pick the regional STS
+	// endpoint. Defaults to $AWS_REGION, or the global endpoint.
+	Region string
+
+	// Endpoint overrides the URL of the STS endpoint.
+	Endpoint string
+
+	// Client is used for STS requests. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// ExpiryWindow is how long before their expiry the credentials
+	// are refreshed. Defaults to 1 minute.
+	ExpiryWindow time.Duration
+
+	cache stsCache
+}
+
+// Retrieve returns the cached credentials of the role, calling
+// AssumeRole if they are missing or about to expire.
+func (p *AssumeRoleProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	return p.cache.get(p.ExpiryWindow, func() (Credentials, error) {
+		if p.Source == nil || p.RoleARN == "" {
+			return Credentials{}, fmt.Errorf("assume role: source credentials and role ARN are required")
+		}
+
+		source, err := p.Source.Retrieve(ctx)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("assume role: error retrieving source credentials: %w", err)
+		}
+
+		form := url.Values{}
+		form.Set("Action", "AssumeRole")
+		form.Set("RoleArn", p.RoleARN)
+		form.Set("RoleSessionName", roleSessionName(p.RoleSessionName))
+		if p.ExternalID != "" {
+			form.Set("ExternalId", p.ExternalID)
+		}
+		setSTSDuration(form, p.Duration)
+
+		region, endpoint := stsEndpoint(p.Region, p.Endpoint)
+		signer := &S3{Region: region}
+
+		return callSTS(ctx, p.Client, endpoint, form, func(req *http.Request) error {
+			return signer.signRequestForService(req, source, stsServiceName)
+		})
+	})
+}
+
+// WebIdentityProvider provides temporary credentials for a role, obtained
+// by calling STS AssumeRoleWithWebIdentity with an OIDC token read from
+// a file. This is how EKS IAM Roles for Service Accounts (IRSA) work.
+//
+// Fields left empty are read from AWS_ROLE_ARN, AWS_WEB_IDENTITY_TOKEN_FILE
+// and AWS_ROLE_SESSION_NAME. The credentials are cached and retrieved again
+// ExpiryWindow before they expire. The token file is read again every time,
+// as it is rotated. A WebIdentityProvider must not be copied after first use.
+type WebIdentityProvider struct {
+	RoleARN         string
+	TokenFile       string
+	RoleSessionName string
+
+	// Duration of the session. Defaults to the role's setting (usually 1 hour).
+	Duration time.Duration
+
+	// Region is used to pick the regional STS endpoint.
+	// Defaults to $AWS_REGION, or the global endpoint.
+	Region string
+
+	// Endpoint overrides the URL of the STS endpoint.
+	Endpoint string
+
+	// Client is used for STS requests. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// ExpiryWindow is how long before their expiry the credentials
+	// are refreshed. Defaults to 1 minute.
+	ExpiryWindow time.Duration
+
+	cache stsCache
+}
+
+// Retrieve returns the cached credentials of the role, calling
+// AssumeRoleWithWebIdentity if they are missing or about to expire.
+func (p *WebIdentityProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	return p.cache.get(p.ExpiryWindow, func() (Credentials, error) {
+		roleARN := p.RoleARN
+		if roleARN == "" {
+			roleARN = os.Getenv("AWS_ROLE_ARN")
+		}
+		tokenFile := p.TokenFile
+		if tokenFile == "" {
+			tokenFile = os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+		}
+		if roleARN == "" || tokenFile == "" {
+			return Credentials{}, fmt.Errorf("web identity: %w", ErrNoCredentials)
+		}
+
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("web identity: error reading token: %w", err)
+		}
+
+		sessionName := p.RoleSessionName
+		if sessionName == "" {
+			sessionName = os.Getenv("AWS_ROLE_SESSION_NAME")
+		}
+
+		form := url.Values{}
+		form.Set("Action", "AssumeRoleWithWebIdentity")
+		form.Set("RoleArn", roleARN)
+		form.Set("RoleSessionName", roleSessionName(sessionName))
+		form.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+		setSTSDuration(form, p.Duration)
+
+		// AssumeRoleWithWebIdentity is authenticated by the token,
+		// the request is not signed.
+		_, endpoint := stsEndpoint(p.Region, p.Endpoint)
+		return callSTS(ctx, p.Client, endpoint, form, nil)
+	})
+}
+
+// stsCache holds the credentials last returned by an STS provider.
+type stsCache struct {
+	mu    sync.Mutex
+	creds Credentials
+}
+
+// get returns the cached credentials, unless they expire within
+// window, in which case it replaces them with the result of fetch.
+// The returned credentials expire window early, so that clients
+// caching them retrieve new ones in time as well.
+func (c *stsCache) get(window time.Duration, fetch func() (Credentials, error)) (Credentials, error) {
+	if window <= 0 {
+		window = defaultSTSExpiryWindow
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.creds.AccessKeyID != "" && !c.creds.expired() {
+		return c.creds, nil
+	}
+
+	creds, err := fetch()
+	if err != nil {
+		return Credentials{}, err
+	}
+	if !creds.Expires.IsZero() {
+		creds.Expires = creds.Expires.Add(-window)
+	}
+
+	c.creds = creds
+	return creds, nil
+}
+
+// stsEndpoint returns the region to sign STS requests for and the
+// URL of the STS endpoint to send them to.
+func stsEndpoint(region, endpoint string) (string, string) {
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+
+	if endpoint == "" {
+		if region == "" {
+			endpoint = "https://sts.amazonaws.com"
+		} else {
+			endpoint = "https://sts." + region + ".amazonaws.com"
+		}
+	}
+	if region == "" {
+		region = defaultSTSRegion
+	}
+
+	return region, endpoint
+}
+
+// roleSessionName returns name, or a generated session name if it is empty.
+func roleSessionName(name string) string {
+	if name != "" {
+		return name
+	}
+	return "simples3-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+func setSTSDuration(form url.Values, d time.Duration) {
+	if d > 0 {
+		form.Set("DurationSeconds", strconv.Itoa(int(d.Seconds())))
+	}
+}
+
+// stsResponse is the part of the AssumeRole and AssumeRoleWithWebIdentity
+// responses holding the credentials.
+type stsResponse struct {
+	// Result is the AssumeRoleResult or AssumeRoleWithWebIdentityResult.
+	Result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"Credentials"`
+	} `xml:",any"`
+}
+
+// stsErrorResponse is the error document returned by STS.
+type stsErrorResponse struct {
+	Error struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+	RequestID string `xml:"RequestId"`
+}
+
+// callSTS posts form to the STS endpoint, signing the request using
+// sign if it is not nil, and returns the credentials in the response.
+func callSTS(ctx context.Context, cl *http.Client, endpoint string, form url.Values, sign func(*http.Request) error) (Credentials, error) {
+	if cl == nil {
+		cl = http.DefaultClient
+	}
+
+	form.Set("Version", stsAPIVersion)
+	body := form.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(body))
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	if sign != nil {
+		h := sha256.Sum256([]byte(body))
+		req.Header.Set("x-amz-content-sha256", hex.EncodeToString(h[:]))
+		if err := sign(req); err != nil {
+			return Credentials{}, err
+		}
+	}
+
+	res, err := cl.Do(req)
+	if err != nil {
+		return Credentials{}, err
+	}
+	defer func() {
+		res.Body.Close()
+		io.Copy(io.Discard, res.Body)
+	}()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	action := form.Get("Action")
+	if res.StatusCode != http.StatusOK {
+		var errResp stsErrorResponse
+		if err := xml.Unmarshal(data, &errResp); err != nil || errResp.Error.Code == "" {
+			return Credentials{}, newResponseError(action, "", "", res, data)
+		}
+		return Credentials{}, &S3Error{
+			Code:       errResp.Error.Code,
+			Message:    errResp.Error.Message,
+			RequestID:  errResp.RequestID,
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Operation:  action,
+		}
+	}
+
+	var stsResp stsResponse
+	if err := xml.Unmarshal(data, &stsResp); err != nil {
+		return Credentials{}, fmt.Errorf("error unmarshalling %s response: %w", action, err)
+	}
+	if stsResp.Result.Credentials.AccessKeyID == "" {
+		return Credentials{}, fmt.Errorf("%s response has no credentials", action)
+	}
+
+	return Credentials{
+		AccessKeyID:     stsResp.Result.Credentials.AccessKeyID,
+		SecretAccessKey: stsResp.Result.Credentials.SecretAccessKey,
+		SessionToken:    stsResp.Result.Credentials.SessionToken,
+		Expires:         stsResp.Result.Credentials.Expiration,
+	}, nil
+}
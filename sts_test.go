@@ -0,0 +1,139 @@
+package simples3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSTSStub returns a server answering STS actions with credentials
+// expiring at expiry, and the number of requests it has served.
+func newSTSStub(t *testing.T, expiry time.Time, check func(r *http.Request)) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		check(r)
+
+		action := r.PostForm.Get("Action")
+		if r.PostForm.Get("RoleArn") == "arn:aws:iam::123456789012:role/denied" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized</Message></Error><RequestId>REQ</RequestId></ErrorResponse>`)
+			return
+		}
+		fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>ASIA%[1]s</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>%[2]s</Expiration>
+    </Credentials>
+  </%[1]sResult>
+</%[1]sResponse>`, action, expiry.UTC().Format(time.RFC3339))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &calls
+}
+
+func TestAssumeRoleProvider(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	ts, calls := newSTSStub(t, expiry, func(r *http.Request) {
+		if strings.HasSuffix(r.PostForm.Get("RoleArn"), "/denied") {
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.Contains(auth, "Credential=source-key/") || !strings.Contains(auth, "/eu-west-1/sts/aws4_request") {
+			t.Errorf("request not signed for STS: %q", auth)
+		}
+		if r.PostForm.Get("Version") != stsAPIVersion || r.PostForm.Get("ExternalId") != "ext" ||
+			r.PostForm.Get("DurationSeconds") != "900" || r.PostForm.Get("RoleSessionName") == "" {
+			t.Errorf("unexpected form: %v", r.PostForm)
+		}
+	})
+
+	p := &AssumeRoleProvider{
+		Source:     StaticProvider{Credentials{AccessKeyID: "source-key", SecretAccessKey: "source-secret"}},
+		RoleARN:    "arn:aws:iam::123456789012:role/test",
+		ExternalID: "ext",
+		Duration:   15 * time.Minute,
+		Region:     "eu-west-1",
+		Endpoint:   ts.URL,
+	}
+
+	for i := 0; i < 2; i++ {
+		creds, err := p.Retrieve(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds.AccessKeyID != "ASIAAssumeRole" || creds.SessionToken != "token" {
+			t.Errorf("unexpected credentials: %+v", creds)
+		}
+		if !creds.Expires.Equal(expiry.Add(-defaultSTSExpiryWindow)) {
+			t.Errorf("expected credentials to expire one window early, got %v", creds.Expires)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected cached credentials to be reused, got %d STS calls", n)
+	}
+
+	// Credentials within the expiry window are refreshed.
+	p.ExpiryWindow = 2 * time.Hour
+	p.cache.creds = Credentials{}
+	p.Retrieve(context.Background())
+	p.Retrieve(context.Background())
+	if n := calls.Load(); n != 3 {
+		t.Errorf("expected credentials within the window to be refreshed, got %d STS calls", n)
+	}
+
+	p = &AssumeRoleProvider{
+		Source:   p.Source,
+		RoleARN:  "arn:aws:iam::123456789012:role/denied",
+		Endpoint: ts.URL,
+	}
+	if _, err := p.Retrieve(context.Background()); !IsAccessDenied(err) {
+		t.Errorf("expected access denied, got %v", err)
+	}
+}
+
+func TestWebIdentityProvider(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("oidc-token\n"), 0o600)
+
+	ts, _ := newSTSStub(t, time.Now().Add(time.Hour), func(r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("AssumeRoleWithWebIdentity must not be signed")
+		}
+		if r.PostForm.Get("WebIdentityToken") != "oidc-token" || r.PostForm.Get("RoleSessionName") != "session" {
+			t.Errorf("unexpected form: %v", r.PostForm)
+		}
+	})
+
+	t.Setenv("AWS_ROLE_ARN", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	if _, err := (&WebIdentityProvider{Endpoint: ts.URL}).Retrieve(context.Background()); err == nil {
+		t.Fatal("expected an error without role and token file")
+	}
+
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/irsa")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	t.Setenv("AWS_ROLE_SESSION_NAME", "session")
+
+	s3 := NewWithProvider("us-east-1", &WebIdentityProvider{Endpoint: ts.URL})
+	creds, err := s3.credentials(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "ASIAAssumeRoleWithWebIdentity" {
+		t.Errorf("unexpected credentials: %+v", creds)
+	}
+}
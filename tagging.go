@@ -5,6 +5,7 @@ package simples3
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/sha256"
 	"encoding/base64"
@@ -72,6 +73,11 @@ type DeleteObjectTaggingInput struct {
 // Replaces all existing tags with the provided tags.
 // S3 allows up to 10 tags per object.
 func (s3 *S3) PutObjectTagging(input PutObjectTaggingInput) error {
+	return s3.PutObjectTaggingWithContext(context.Background(), input)
+}
+
+// PutObjectTaggingWithContext is like PutObjectTagging but uses ctx for the request.
+func (s3 *S3) PutObjectTaggingWithContext(ctx context.Context, input PutObjectTaggingInput) error {
 	// Validate required fields
 	if input.Bucket == "" {
 		return fmt.Errorf("bucket name is required")
@@ -86,11 +92,6 @@ func (s3 *S3) PutObjectTagging(input PutObjectTaggingInput) error {
 		return fmt.Errorf("cannot set more than 10 tags per object")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return err
-	}
-
 	// Build XML request body
 	tagsXML := tagging{
 		XMLNS: "http://s3.amazonaws.com/doc/2006-03-01/",
@@ -122,7 +123,7 @@ func (s3 *S3) PutObjectTagging(input PutObjectTaggingInput) error {
 	parsedURL.RawQuery = query.Encode()
 
 	// Create PUT request
-	req, err := http.NewRequest(http.MethodPut, parsedURL.String(), bytes.NewReader(xmlBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, parsedURL.String(), bytes.NewReader(xmlBody))
 	if err != nil {
 		return err
 	}
@@ -140,14 +141,8 @@ func (s3 *S3) PutObjectTagging(input PutObjectTaggingInput) error {
 	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(xmlBody)))
 	req.Header.Set("Host", req.URL.Host)
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return err
 	}
@@ -165,7 +160,7 @@ func (s3 *S3) PutObjectTagging(input PutObjectTaggingInput) error {
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return newResponseError("PutObjectTagging", input.Bucket, input.ObjectKey, res, body)
 	}
 
 	return nil
@@ -173,6 +168,11 @@ func (s3 *S3) PutObjectTagging(input PutObjectTaggingInput) error {
 
 // GetObjectTagging retrieves the tags associated with an S3 object.
 func (s3 *S3) GetObjectTagging(input GetObjectTaggingInput) (GetObjectTaggingOutput, error) {
+	return s3.GetObjectTaggingWithContext(context.Background(), input)
+}
+
+// GetObjectTaggingWithContext is like GetObjectTagging but uses ctx for the request.
+func (s3 *S3) GetObjectTaggingWithContext(ctx context.Context, input GetObjectTaggingInput) (GetObjectTaggingOutput, error) {
 	// Validate required fields
 	if input.Bucket == "" {
 		return GetObjectTaggingOutput{}, fmt.Errorf("bucket name is required")
@@ -181,11 +181,6 @@ func (s3 *S3) GetObjectTagging(input GetObjectTaggingInput) (GetObjectTaggingOut
 		return GetObjectTaggingOutput{}, fmt.Errorf("object key is required")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return GetObjectTaggingOutput{}, err
-	}
-
 	// Build URL with ?tagging query parameter
 	baseURL := s3.getURL(input.Bucket, input.ObjectKey)
 	parsedURL, err := url.Parse(baseURL)
@@ -195,19 +190,13 @@ func (s3 *S3) GetObjectTagging(input GetObjectTaggingInput) (GetObjectTaggingOut
 	parsedURL.RawQuery = "tagging"
 
 	// Create GET request
-	req, err := http.NewRequest(http.MethodGet, parsedURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
 	if err != nil {
 		return GetObjectTaggingOutput{}, err
 	}
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return GetObjectTaggingOutput{}, err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return GetObjectTaggingOutput{}, err
 	}
@@ -225,7 +214,7 @@ func (s3 *S3) GetObjectTagging(input GetObjectTaggingInput) (GetObjectTaggingOut
 
 	// Handle non-OK status codes
 	if res.StatusCode != http.StatusOK {
-		return GetObjectTaggingOutput{}, fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return GetObjectTaggingOutput{}, newResponseError("GetObjectTagging", input.Bucket, input.ObjectKey, res, body)
 	}
 
 	// Parse XML response
@@ -247,6 +236,11 @@ func (s3 *S3) GetObjectTagging(input GetObjectTaggingInput) (GetObjectTaggingOut
 
 // DeleteObjectTagging removes all tags from an S3 object.
 func (s3 *S3) DeleteObjectTagging(input DeleteObjectTaggingInput) error {
+	return s3.DeleteObjectTaggingWithContext(context.Background(), input)
+}
+
+// DeleteObjectTaggingWithContext is like DeleteObjectTagging but uses ctx for the request.
+func (s3 *S3) DeleteObjectTaggingWithContext(ctx context.Context, input DeleteObjectTaggingInput) error {
 	// Validate required fields
 	if input.Bucket == "" {
 		return fmt.Errorf("bucket name is required")
@@ -255,11 +249,6 @@ func (s3 *S3) DeleteObjectTagging(input DeleteObjectTaggingInput) error {
 		return fmt.Errorf("object key is required")
 	}
 
-	// Renew IAM token if needed
-	if err := s3.renewIAMToken(); err != nil {
-		return err
-	}
-
 	// Build URL with ?tagging query parameter
 	baseURL := s3.getURL(input.Bucket, input.ObjectKey)
 	parsedURL, err := url.Parse(baseURL)
@@ -271,19 +260,13 @@ func (s3 *S3) DeleteObjectTagging(input DeleteObjectTaggingInput) error {
 	parsedURL.RawQuery = query.Encode()
 
 	// Create DELETE request
-	req, err := http.NewRequest(http.MethodDelete, parsedURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, parsedURL.String(), nil)
 	if err != nil {
 		return err
 	}
 
-	// Sign the request
-	if err := s3.signRequest(req); err != nil {
-		return err
-	}
-
 	// Execute request
-	client := s3.getClient()
-	res, err := client.Do(req)
+	res, err := s3.do(req)
 	if err != nil {
 		return err
 	}
@@ -302,7 +285,7 @@ func (s3 *S3) DeleteObjectTagging(input DeleteObjectTaggingInput) error {
 	// Handle non-success status codes
 	// AWS returns 204 No Content on successful deletion
 	if res.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("status code: %s: %s", res.Status, string(body))
+		return newResponseError("DeleteObjectTagging", input.Bucket, input.ObjectKey, res, body)
 	}
 
 	return nil